@@ -12,11 +12,31 @@ import (
 func (svc *Service) AdminRouter(base string) *chi.Mux {
 	r := chi.NewRouter()
 
+	r.Use(svc.logRequests)
+	r.Use(svc.negotiateErrors)
+
+	if svc.tracer != nil {
+		r.Use(svc.traceRequests)
+	}
+
+	if svc.metrics != nil {
+		r.Use(svc.metrics.middleware(true))
+	}
+
 	r.Route(base, func(r chi.Router) {
+		// applied per route, so the route is known when limiting.
+		r = r.With(svc.limitRequests)
+
+		r.Get("/", svc.List)
 		r.Get("/{id}", svc.GetWithID)
-		r.Post("/", svc.CreateWithoutSession)
+		r.With(svc.adminIdempotent(CreateOp)).Post("/", svc.CreateWithoutSession)
 		r.Put("/{id}", svc.UpdateWithID)
 		r.Delete("/{id}", svc.DeleteWithID)
+		r.Post("/{id}/assign", svc.AssignWithID)
+		r.Post("/jobs", svc.CreateJob)
+		r.Get("/jobs/{id}", svc.GetJob)
+		r.With(svc.adminIdempotent(UpdateOp)).Post("/{id}/conversion", svc.ConvertWithID)
+		r.Get("/analytics", svc.Analytics)
 	})
 
 	return r
@@ -44,14 +64,14 @@ func (svc *Service) GetWithID(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	cart, err := svc.db.LookupCart(cartID)
+	cart, err := svc.LookupCart(req.Context(), cartID)
 	if errors.Is(err, ErrCartNotFound) {
-		svc.json(writeError(w, http.StatusNotFound, err))
+		svc.json(svc.writeError(w, http.StatusNotFound, err))
 		return
 	}
 
 	if err != nil {
-		svc.json(writeError(w, http.StatusInternalServerError, err))
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
 		return
 	}
 
@@ -79,9 +99,9 @@ func (svc *Service) CreateWithoutSession(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
-	cart, err := svc.db.CreateCart()
+	cart, err := svc.CreateCart(req.Context())
 	if err != nil {
-		svc.json(writeError(w, http.StatusInternalServerError, fmt.Errorf("could not decode request: %w", err)))
+		svc.json(svc.writeError(w, http.StatusInternalServerError, fmt.Errorf("could not decode request: %w", err)))
 		return
 	}
 
@@ -89,11 +109,14 @@ func (svc *Service) CreateWithoutSession(w http.ResponseWriter, req *http.Reques
 }
 
 // Update will update the Cart. It will override the Cart ID to ensure no accidental
-// changes.
+// changes, and reject the Cart if it fails validation. The owner, kind and
+// name are kept unless set, and changing the owner is also authorized as a
+// TransferOp.
 //
 // Status codes:
 //   - 200: Updated successfully
-//   - 400: No session found for request
+//   - 400: No session found for request, or the Cart is invalid
+//   - 403: Forbidden
 //   - 404: No cart found
 //   - 500: unexpected error
 func (svc *Service) UpdateWithID(w http.ResponseWriter, req *http.Request) {
@@ -109,30 +132,34 @@ func (svc *Service) UpdateWithID(w http.ResponseWriter, req *http.Request) {
 
 	payload := UpdateCartRequest{}
 	if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
-		svc.json(writeError(w, http.StatusBadRequest, err))
+		svc.json(svc.writeError(w, http.StatusBadRequest, err))
 		return
 	}
 
-	foundCart, err := svc.db.LookupCart(cartID)
-	if errors.Is(err, ErrCartNotFound) {
-		svc.json(writeError(w, http.StatusNotFound, err))
+	// NOTE: we still overwrite the payload's cart ID
+	payload.Data.ID = cartID
+
+	if !svc.authorizeOwnerChange(w, req, payload.Data) {
 		return
 	}
 
-	if err != nil {
-		svc.json(writeError(w, http.StatusInternalServerError, err))
+	cart, err := svc.UpdateCart(req.Context(), payload.Data)
+	if errors.As(err, &ValidationError{}) {
+		svc.json(svc.writeError(w, http.StatusBadRequest, err))
 		return
 	}
 
-	// NOTE: we still overwrite the payload's cart ID
-	payload.Data.ID = foundCart.ID
+	if errors.Is(err, ErrCartNotFound) {
+		svc.json(svc.writeError(w, http.StatusNotFound, err))
+		return
+	}
 
-	if err := svc.db.UpdateCart(payload.Data); err != nil {
-		svc.json(writeError(w, http.StatusInternalServerError, err))
+	if err != nil {
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
 		return
 	}
 
-	svc.json(writeResponse(w, http.StatusOK, UpdateCartResponse{Data: payload.Data}))
+	svc.json(writeResponse(w, http.StatusOK, UpdateCartResponse{Data: cart}))
 }
 
 // Delete will delete the Cart with the supploed ID.
@@ -153,23 +180,101 @@ func (svc *Service) DeleteWithID(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if err := svc.db.DeleteCart(cartID); err != nil {
-		svc.json(writeError(w, http.StatusInternalServerError, err))
+	if err := svc.DeleteCart(req.Context(), cartID); err != nil {
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// AssignWithID will assign the Cart with the supplied ID to the session
+// specified in the request body. Items saved for later in the session's
+// previous Cart are moved to it.
+//
+// Status codes:
+//   - 204: Assigned successfully
+//   - 400: Invalid request or no matching session found
+//   - 403: Forbidden
+//   - 404: No cart found
+//   - 500: unexpected error
+func (svc *Service) AssignWithID(w http.ResponseWriter, req *http.Request) {
+	op := Operation{
+		Type:     UpdateOp,
+		Resource: "cart",
+	}
+
+	cartID := chi.URLParam(req, "id")
+	if !checkAndReportAuthorized(svc, w, req, op, cartID) {
+		return
+	}
+
+	payload := AssignCartRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+		svc.json(svc.writeError(w, http.StatusBadRequest, fmt.Errorf("could not decode request: %w", err)))
+		return
+	}
+
+	err := svc.AssignCart(req.Context(), cartID, payload.Data.SessionToken)
+	if errors.Is(err, ErrSessionNotFound) {
+		svc.json(svc.writeError(w, http.StatusBadRequest, err))
+		return
+	}
+
+	if errors.Is(err, ErrCartNotFound) {
+		svc.json(svc.writeError(w, http.StatusNotFound, err))
+		return
+	}
+
+	if err != nil {
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizeOwnerChange authorizes a TransferOp if the Cart's owner is set
+// to another user, writing the error response if it fails.
+func (svc *Service) authorizeOwnerChange(w http.ResponseWriter, req *http.Request, cart Cart) bool {
+	if cart.UserID == "" {
+		return true
+	}
+
+	found, err := svc.LookupCart(req.Context(), cart.ID)
+	if errors.Is(err, ErrCartNotFound) {
+		svc.json(svc.writeError(w, http.StatusNotFound, err))
+		return false
+	}
+
+	if err != nil {
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
+		return false
+	}
+
+	if found.UserID == cart.UserID {
+		return true
+	}
+
+	op := Operation{
+		Type:     TransferOp,
+		Resource: "cart",
+	}
+
+	return checkAndReportAuthorized(svc, w, req, op, cart.ID)
+}
+
 func checkAndReportAuthorized(svc *Service, w http.ResponseWriter, req *http.Request, op Operation, id string) bool {
+	labelOperation(req.Context(), op.Type)
+
 	err := svc.authorizer.AuthorizeUser(req, op, id)
 	if errors.As(err, &NotAuthorizedError{}) {
-		svc.json(writeError(w, http.StatusForbidden, err))
+		svc.json(svc.writeError(w, http.StatusForbidden, err))
 		return false
 	}
 
 	if err != nil {
-		svc.json(writeError(w, http.StatusInternalServerError, err))
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
 		return false
 	}
 
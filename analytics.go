@@ -0,0 +1,502 @@
+package kaimono
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultAnalyticsBucket    = time.Hour
+	defaultAnalyticsRetention = 30 * 24 * time.Hour
+	defaultReportRange        = 24 * time.Hour
+	topItemsLimit             = 10
+	csvValuePrecision         = 2
+	csvRatePrecision          = 4
+	analyticsSweepInterval    = time.Minute
+)
+
+var ErrAnalyticsDisabled = errors.New("analytics are not enabled")
+
+// AnalyticsConfig configures the aggregation of cart analytics. Zero values
+// use the defaults.
+type AnalyticsConfig struct {
+	// Bucket is the size of the time buckets, defaults to 1 hour.
+	Bucket time.Duration
+
+	// Retention is how long buckets, and the carts not updated since, are
+	// kept, defaults to 30 days.
+	Retention time.Duration
+
+	// IdleAfter is how long an active Cart must go without updates, or a
+	// conversion, to be counted as abandoned. It defaults to
+	// RecoveryConfig.IdleAfter if recovery is enabled, or to 24 hours.
+	IdleAfter time.Duration
+}
+
+// AnalyticsBucket holds the aggregates of a time bucket, or of a whole
+// report for its summary.
+//
+// Active counts the carts which got their first item, Converted the carts
+// reported through Service.RecordConversion and Abandoned the active carts
+// which went idle for AnalyticsConfig.IdleAfter without converting, in the
+// bucket they became idle. The rates are relative to Active.
+// AverageValue is the average total of the non-empty carts updated in the
+// bucket, by currency, and TopItems the most added items by quantity.
+type AnalyticsBucket struct {
+	Start           time.Time          `json:"start"`
+	Created         int                `json:"created"`
+	Active          int                `json:"active"`
+	Converted       int                `json:"converted"`
+	Abandoned       int                `json:"abandoned"`
+	ConversionRate  float64            `json:"conversion-rate"`
+	AbandonmentRate float64            `json:"abandonment-rate"`
+	AverageValue    map[string]float64 `json:"average-value"`
+	TopItems        []ItemCount        `json:"top-items"`
+}
+
+// ItemCount is the quantity of an item added to carts.
+type ItemCount struct {
+	ItemID   string `json:"item-id"`
+	Quantity int    `json:"quantity"`
+}
+
+// AnalyticsReport holds the buckets between From and To.
+type AnalyticsReport struct {
+	From    time.Time         `json:"from"`
+	To      time.Time         `json:"to"`
+	Bucket  string            `json:"bucket"`
+	Buckets []AnalyticsBucket `json:"buckets"`
+	Summary AnalyticsBucket   `json:"summary"`
+}
+
+type AnalyticsReportResponse = Response[AnalyticsReport]
+
+type cartStats struct {
+	quantities map[string]int
+	updated    time.Time
+	wishlist   bool
+	active     bool
+	converted  bool
+	abandoned  bool
+}
+
+type bucketStats struct {
+	created, active, converted, abandoned int
+
+	totals     map[string]Totals
+	itemsAdded map[string]int
+}
+
+func newBucketStats() *bucketStats {
+	return &bucketStats{totals: make(map[string]Totals), itemsAdded: make(map[string]int)}
+}
+
+// analytics aggregates the cart events in memory.
+type analytics struct {
+	cfg AnalyticsConfig
+
+	mu        sync.Mutex
+	buckets   map[time.Time]*bucketStats
+	carts     map[string]*cartStats
+	lastSweep time.Time
+}
+
+// WithAnalytics enables the aggregation of cart analytics, reported on the
+// AdminRouter.
+func WithAnalytics(cfg AnalyticsConfig) Option {
+	return func(svc *Service) {
+		if cfg.Bucket <= 0 {
+			cfg.Bucket = defaultAnalyticsBucket
+		}
+
+		if cfg.Retention <= 0 {
+			cfg.Retention = defaultAnalyticsRetention
+		}
+
+		svc.analytics = &analytics{
+			cfg:     cfg,
+			buckets: make(map[time.Time]*bucketStats),
+			carts:   make(map[string]*cartStats),
+		}
+
+		svc.events.observe(svc.analytics.record)
+	}
+}
+
+// bucket returns the bucket for t, pruning the expired buckets when a new
+// one is created. It must be called with the lock held.
+func (a *analytics) bucket(t time.Time) *bucketStats {
+	start := t.UTC().Truncate(a.cfg.Bucket)
+
+	if b, found := a.buckets[start]; found {
+		return b
+	}
+
+	for key := range a.buckets {
+		if key.Before(start.Add(-a.cfg.Retention)) {
+			delete(a.buckets, key)
+		}
+	}
+
+	b := newBucketStats()
+	a.buckets[start] = b
+
+	return b
+}
+
+// idle counts the Cart as abandoned if it went idle by now with items, as
+// RecoverAbandoned does, wishlists aren't. It must be called with the lock
+// held.
+func (a *analytics) idle(stats *cartStats, now time.Time) {
+	if len(stats.quantities) == 0 || stats.wishlist || stats.converted || stats.abandoned || now.Sub(stats.updated) < a.cfg.IdleAfter {
+		return
+	}
+
+	stats.abandoned = true
+	a.bucket(stats.updated.Add(a.cfg.IdleAfter)).abandoned++
+}
+
+// sweep counts the carts which went idle and forgets the ones not updated
+// within the retention, at most once per analyticsSweepInterval. It must be
+// called with the lock held.
+func (a *analytics) sweep(now time.Time) {
+	if now.Sub(a.lastSweep) < analyticsSweepInterval {
+		return
+	}
+
+	for cartID, stats := range a.carts {
+		if now.Sub(stats.updated) >= a.cfg.Retention {
+			delete(a.carts, cartID)
+			continue
+		}
+
+		a.idle(stats, now)
+	}
+
+	a.lastSweep = now
+}
+
+// stats must be called with the lock held.
+func (a *analytics) stats(cartID string) *cartStats {
+	stats, found := a.carts[cartID]
+	if !found {
+		stats = &cartStats{quantities: make(map[string]int)}
+		a.carts[cartID] = stats
+	}
+
+	return stats
+}
+
+func (a *analytics) record(event CartEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.sweep(event.Time)
+	b := a.bucket(event.Time)
+
+	switch event.Type {
+	case CartCreated:
+		b.created++
+	case CartDeleted:
+		if stats := a.carts[event.CartID]; stats != nil {
+			a.idle(stats, event.Time)
+		}
+
+		delete(a.carts, event.CartID)
+		delete(b.totals, event.CartID)
+
+		return
+	}
+
+	stats := a.stats(event.CartID)
+	quantities := make(map[string]int, len(event.Cart.Items))
+
+	for _, item := range event.Cart.Items {
+		quantities[item.ID] += item.Quantity
+	}
+
+	for itemID, quantity := range quantities {
+		if added := quantity - stats.quantities[itemID]; added > 0 {
+			b.itemsAdded[itemID] += added
+		}
+	}
+
+	stats.quantities = quantities
+	stats.updated = event.Time
+	stats.wishlist = event.Cart.Kind == WishlistCart
+
+	if len(quantities) > 0 && !stats.active {
+		stats.active = true
+		b.active++
+	}
+
+	totals, err := event.Cart.Totals()
+	if err != nil || len(quantities) == 0 {
+		delete(b.totals, event.CartID)
+		return
+	}
+
+	b.totals[event.CartID] = totals
+}
+
+func (a *analytics) recordConversion(cartID string, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	stats := a.stats(cartID)
+	if stats.converted {
+		return
+	}
+
+	stats.converted = true
+	a.bucket(now).converted++
+}
+
+// report aggregates the buckets starting in [from, to), as of now.
+func (a *analytics) report(from, to, now time.Time) AnalyticsReport {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.sweep(now)
+
+	report := AnalyticsReport{From: from, To: to, Bucket: a.cfg.Bucket.String(), Buckets: []AnalyticsBucket{}}
+	summary := newBucketStats()
+
+	starts := slices.SortedFunc(maps.Keys(a.buckets), func(x, y time.Time) int { return x.Compare(y) })
+
+	for _, start := range starts {
+		if start.Before(from) || !start.Before(to) {
+			continue
+		}
+
+		b := a.buckets[start]
+		report.Buckets = append(report.Buckets, b.summarize(start))
+
+		summary.created += b.created
+		summary.active += b.active
+		summary.converted += b.converted
+		summary.abandoned += b.abandoned
+
+		for cartID, totals := range b.totals {
+			summary.totals[start.String()+cartID] = totals
+		}
+
+		for itemID, quantity := range b.itemsAdded {
+			summary.itemsAdded[itemID] += quantity
+		}
+	}
+
+	report.Summary = summary.summarize(from)
+
+	return report
+}
+
+func (b *bucketStats) summarize(start time.Time) AnalyticsBucket {
+	out := AnalyticsBucket{
+		Start:        start,
+		Created:      b.created,
+		Active:       b.active,
+		Converted:    b.converted,
+		Abandoned:    b.abandoned,
+		AverageValue: map[string]float64{},
+		TopItems:     []ItemCount{},
+	}
+
+	if b.active > 0 {
+		out.ConversionRate = float64(b.converted) / float64(b.active)
+		out.AbandonmentRate = float64(b.abandoned) / float64(b.active)
+	}
+
+	counts := map[string]int{}
+
+	for _, totals := range b.totals {
+		out.AverageValue[totals.Currency] += totals.Total
+		counts[totals.Currency]++
+	}
+
+	for currency, count := range counts {
+		out.AverageValue[currency] /= float64(count)
+	}
+
+	for itemID, quantity := range b.itemsAdded {
+		out.TopItems = append(out.TopItems, ItemCount{ItemID: itemID, Quantity: quantity})
+	}
+
+	slices.SortFunc(out.TopItems, func(x, y ItemCount) int {
+		return cmp.Or(cmp.Compare(y.Quantity, x.Quantity), strings.Compare(x.ItemID, y.ItemID))
+	})
+
+	if len(out.TopItems) > topItemsLimit {
+		out.TopItems = out.TopItems[:topItemsLimit]
+	}
+
+	return out
+}
+
+// RecordConversion reports that the Cart was converted (e.g: checked out)
+// to the analytics. kaimono doesn't handle checkouts, so this should be
+// called from the checkout flow. Converting a Cart more than once has no
+// effect.
+func (svc *Service) RecordConversion(ctx context.Context, cartID string) error {
+	if svc.analytics == nil {
+		return ErrAnalyticsDisabled
+	}
+
+	if _, err := svc.store(ctx).LookupCart(cartID); err != nil {
+		return err
+	}
+
+	svc.analytics.recordConversion(cartID, time.Now())
+
+	return nil
+}
+
+// ConvertWithID reports the Cart with the supplied ID as converted, see
+// Service.RecordConversion.
+//
+// Status codes:
+//   - 204: Recorded successfully
+//   - 403: Forbidden
+//   - 404: No cart found
+//   - 500: unexpected error
+//   - 501: Analytics not enabled
+func (svc *Service) ConvertWithID(w http.ResponseWriter, req *http.Request) {
+	op := Operation{
+		Type:     UpdateOp,
+		Resource: "cart",
+	}
+
+	cartID := chi.URLParam(req, "id")
+	if !checkAndReportAuthorized(svc, w, req, op, cartID) {
+		return
+	}
+
+	err := svc.RecordConversion(req.Context(), cartID)
+
+	switch {
+	case errors.Is(err, ErrCartNotFound):
+		svc.json(svc.writeError(w, http.StatusNotFound, err))
+	case errors.Is(err, ErrAnalyticsDisabled):
+		svc.json(svc.writeError(w, http.StatusNotImplemented, err))
+	case err != nil:
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Analytics will return the analytics aggregated between the from and to
+// query parameters (RFC 3339 times), defaulting to the last 24 hours. The
+// report is returned as CSV, one row per bucket followed by a summary row,
+// if the format query parameter is "csv".
+//
+// Status codes:
+//   - 200: OK
+//   - 400: Invalid query parameters
+//   - 403: Forbidden
+//   - 500: unexpected error
+//   - 501: Analytics not enabled
+func (svc *Service) Analytics(w http.ResponseWriter, req *http.Request) {
+	op := Operation{
+		Type:     ReadOp,
+		Resource: "analytics",
+	}
+
+	if !checkAndReportAuthorized(svc, w, req, op, "") {
+		return
+	}
+
+	if svc.analytics == nil {
+		svc.json(svc.writeError(w, http.StatusNotImplemented, ErrAnalyticsDisabled))
+		return
+	}
+
+	values := req.URL.Query()
+	to := time.Now().UTC()
+	from := to.Add(-defaultReportRange)
+
+	for key, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := values.Get(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				svc.json(svc.writeError(w, http.StatusBadRequest, fmt.Errorf("%w: '%s' must be an RFC 3339 time", ErrInvalidQuery, key)))
+				return
+			}
+
+			*dst = t
+		}
+	}
+
+	// include the bucket containing from.
+	report := svc.analytics.report(from.UTC().Truncate(svc.analytics.cfg.Bucket), to, time.Now())
+
+	if values.Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusOK)
+		logIfError(svc.loggerFor(req.Context()), "could not write csv", writeAnalyticsCSV(w, report))
+
+		return
+	}
+
+	svc.json(writeResponse(w, http.StatusOK, AnalyticsReportResponse{Data: report}))
+}
+
+func writeAnalyticsCSV(w http.ResponseWriter, report AnalyticsReport) error {
+	out := csv.NewWriter(w)
+
+	header := []string{
+		"start", "created", "active", "converted", "abandoned",
+		"conversion-rate", "abandonment-rate", "average-value", "top-items",
+	}
+
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	rows := append(slices.Clone(report.Buckets), report.Summary)
+
+	for k, b := range rows {
+		start := b.Start.Format(time.RFC3339)
+		if k == len(rows)-1 {
+			start = "total"
+		}
+
+		values := []string{}
+		for _, currency := range slices.Sorted(maps.Keys(b.AverageValue)) {
+			values = append(values, currency+"="+strconv.FormatFloat(b.AverageValue[currency], 'f', csvValuePrecision, 64))
+		}
+
+		items := []string{}
+		for _, item := range b.TopItems {
+			items = append(items, item.ItemID+"="+strconv.Itoa(item.Quantity))
+		}
+
+		record := []string{
+			start,
+			strconv.Itoa(b.Created), strconv.Itoa(b.Active), strconv.Itoa(b.Converted), strconv.Itoa(b.Abandoned),
+			strconv.FormatFloat(b.ConversionRate, 'f', csvRatePrecision, 64),
+			strconv.FormatFloat(b.AbandonmentRate, 'f', csvRatePrecision, 64),
+			strings.Join(values, ";"), strings.Join(items, ";"),
+		}
+
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+
+	return out.Error()
+}
@@ -0,0 +1,210 @@
+package kaimono
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAnalytics(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil, WithAnalytics(AnalyticsConfig{}))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	carts := make([]Cart, 3)
+	for k := range carts {
+		if carts[k], err = svc.createCart(context.Background()); err != nil {
+			t.Fatalf("could not create cart: %v", err)
+		}
+	}
+
+	setItems := func(cart *Cart, items ...CartItem) {
+		cart.Items = items
+		if err := svc.updateCart(context.Background(), cart); err != nil {
+			t.Fatalf("could not update cart: %v", err)
+		}
+	}
+
+	eur := func(value float64) Price { return Price{Currency: "EUR", Value: value} }
+
+	setItems(&carts[0], CartItem{ID: "apple", Quantity: 2, Price: eur(1)})
+	setItems(&carts[0], CartItem{ID: "apple", Quantity: 3, Price: eur(1)})
+	setItems(&carts[1], CartItem{ID: "pear", Quantity: 1, Price: eur(5)})
+
+	if err := svc.RecordConversion(context.Background(), carts[0].ID); err != nil {
+		t.Fatalf("could not record conversion: %v", err)
+	}
+
+	if err := svc.RecordConversion(context.Background(), carts[0].ID); err != nil {
+		t.Fatalf("could not record conversion: %v", err)
+	}
+
+	if err := svc.deleteCart(context.Background(), carts[1].ID); err != nil {
+		t.Fatalf("could not delete cart: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.AdminRouter("/admin"))
+	t.Cleanup(srv.Close)
+
+	admin := mock.sessions[1]
+
+	resp := AnalyticsReportResponse{}
+	if code := doJSONRequest(t, http.MethodGet, srv.URL+"/admin/analytics", admin, nil, &resp); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	summary := resp.Data.Summary
+	// the deleted cart wasn't idle, so it isn't abandoned.
+	if summary.Created != 3 || summary.Active != 2 || summary.Converted != 1 || summary.Abandoned != 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	if !closeTo(summary.ConversionRate, 0.5) || !closeTo(summary.AbandonmentRate, 0) {
+		t.Fatalf("unexpected rates: %+v", summary)
+	}
+
+	if !closeTo(summary.AverageValue["EUR"], 3) {
+		t.Fatalf("got average value %v, want 3 EUR", summary.AverageValue)
+	}
+
+	if len(summary.TopItems) != 2 || summary.TopItems[0] != (ItemCount{ItemID: "apple", Quantity: 3}) {
+		t.Fatalf("unexpected top items: %+v", summary.TopItems)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/admin/analytics?format=csv", nil)
+	if err != nil {
+		t.Fatalf("could not make request: %v", err)
+	}
+
+	setTestCookie(req, admin)
+
+	csvResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("could not do request: %v", err)
+	}
+
+	defer csvResp.Body.Close()
+
+	records, err := csv.NewReader(csvResp.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("could not read csv: %v", err)
+	}
+
+	// header, the buckets and the summary.
+	last := records[len(records)-1]
+	if len(records) < 3 || last[0] != "total" || last[1] != "3" || last[8] != "apple=3;pear=1" {
+		t.Fatalf("unexpected csv: %v", records)
+	}
+}
+
+func TestAnalyticsBuckets(t *testing.T) {
+	a := &analytics{
+		cfg:     AnalyticsConfig{Bucket: time.Hour, Retention: 2 * time.Hour},
+		buckets: map[time.Time]*bucketStats{},
+		carts:   map[string]*cartStats{},
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for k := range 4 {
+		a.record(CartEvent{Type: CartCreated, CartID: "cart", Time: start.Add(time.Duration(k) * time.Hour)})
+	}
+
+	report := a.report(start, start.Add(4*time.Hour), start.Add(4*time.Hour))
+	if len(report.Buckets) != 3 || !report.Buckets[0].Start.Equal(start.Add(time.Hour)) {
+		t.Fatalf("expected the oldest bucket to be pruned, got %+v", report.Buckets)
+	}
+
+	report = a.report(start.Add(2*time.Hour), start.Add(3*time.Hour), start.Add(4*time.Hour))
+	if len(report.Buckets) != 1 || report.Summary.Created != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestAnalyticsAbandonment(t *testing.T) {
+	a := &analytics{
+		cfg:     AnalyticsConfig{Bucket: time.Hour, Retention: 48 * time.Hour, IdleAfter: 24 * time.Hour},
+		buckets: map[time.Time]*bucketStats{},
+		carts:   map[string]*cartStats{},
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := []CartItem{{ID: "apple", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}}}
+
+	for _, cart := range []Cart{
+		{ID: "idle", Items: items},
+		{ID: "converted", Items: items},
+		{ID: "wishlist", Kind: WishlistCart, Items: items},
+		{ID: "empty"},
+	} {
+		a.record(CartEvent{Type: CartUpdated, CartID: cart.ID, Cart: cart, Time: start})
+	}
+
+	a.recordConversion("converted", start.Add(time.Hour))
+
+	report := a.report(start, start.Add(48*time.Hour), start.Add(23*time.Hour))
+	if report.Summary.Abandoned != 0 {
+		t.Fatalf("got %d abandoned carts before IdleAfter, want 0", report.Summary.Abandoned)
+	}
+
+	report = a.report(start, start.Add(48*time.Hour), start.Add(25*time.Hour))
+	if report.Summary.Abandoned != 1 || !closeTo(report.Summary.AbandonmentRate, 1.0/3) {
+		t.Fatalf("unexpected summary: %+v", report.Summary)
+	}
+
+	// counted in the bucket the cart went idle.
+	idle := start.Add(24 * time.Hour)
+	if b := a.buckets[idle]; b == nil || b.abandoned != 1 {
+		t.Fatalf("expected the abandoned cart in the %v bucket", idle)
+	}
+
+	a.report(start, start.Add(48*time.Hour), start.Add(49*time.Hour))
+
+	if len(a.carts) != 0 {
+		t.Fatalf("expected the carts past the retention to be forgotten, got %d", len(a.carts))
+	}
+}
+
+func TestAnalyticsIdleAfterDefault(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(
+		mock, mock, mock, nil,
+		WithAnalytics(AnalyticsConfig{}),
+		WithRecovery(&recordingNotifier{}, RecoveryConfig{IdleAfter: time.Hour}),
+	)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if got := svc.analytics.cfg.IdleAfter; got != time.Hour {
+		t.Fatalf("got IdleAfter %v, want the recovery's %v", got, time.Hour)
+	}
+}
+
+func TestAnalyticsDisabled(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if err := svc.RecordConversion(context.Background(), "cart"); !errors.Is(err, ErrAnalyticsDisabled) {
+		t.Fatalf("got %v, want %v", err, ErrAnalyticsDisabled)
+	}
+
+	srv := httptest.NewServer(svc.AdminRouter("/admin"))
+	t.Cleanup(srv.Close)
+
+	if code := doJSONRequest(t, http.MethodGet, srv.URL+"/admin/analytics", mock.sessions[1], nil, nil); code != http.StatusNotImplemented {
+		t.Fatalf("got code %d, want %d", code, http.StatusNotImplemented)
+	}
+}
@@ -1,11 +1,31 @@
 package kaimono
 
+import "time"
+
 type Cart struct {
 	ID        string     `json:"id"`
+	Name      string     `json:"name,omitempty"`
+	Kind      CartKind   `json:"kind,omitempty"`
+	UserID    string     `json:"user-id,omitempty"`
 	Items     []CartItem `json:"items"`
 	Discounts []Discount `json:"discounts"`
+	CreatedAt time.Time  `json:"created-at"`
+	UpdatedAt time.Time  `json:"updated-at"`
+
+	// Saved holds the items saved for later, which don't count towards
+	// the Cart's totals.
+	Saved []CartItem `json:"saved,omitempty"`
 }
 
+// CartKind distinguishes the carts a user owns. An empty kind is a
+// regular cart.
+type CartKind string
+
+const (
+	ShoppingCart CartKind = "cart"
+	WishlistCart CartKind = "wishlist"
+)
+
 type CartItem struct {
 	ID        string     `json:"id"`
 	Quantity  int        `json:"quantity"`
@@ -30,3 +50,278 @@ type Discount struct {
 	Type  DiscountType `json:"type"`
 	Value float64      `json:"value"`
 }
+
+// Totals summarizes the prices of a Cart.
+type Totals struct {
+	Currency string  `json:"currency"`
+	Subtotal float64 `json:"subtotal"`
+	Discount float64 `json:"discount"`
+	Total    float64 `json:"total"`
+}
+
+// Subtotal is the item's price times its quantity, before discounts.
+func (item CartItem) Subtotal() float64 {
+	return item.Price.Value * float64(item.Quantity)
+}
+
+// Total is the item's subtotal after applying its discounts.
+func (item CartItem) Total() float64 {
+	subtotal := item.Subtotal()
+	return subtotal - discountAmount(subtotal, item.Discounts)
+}
+
+// Totals adds up the item totals and applies the Cart's discounts on top.
+// All items must share the same currency, otherwise it will return
+// ErrCurrencyMismatch.
+func (c Cart) Totals() (Totals, error) {
+	totals := Totals{}
+
+	for _, item := range c.Items {
+		if totals.Currency == "" {
+			totals.Currency = item.Price.Currency
+		}
+
+		if item.Price.Currency != totals.Currency {
+			return Totals{}, ErrCurrencyMismatch
+		}
+
+		totals.Subtotal += item.Subtotal()
+		totals.Discount += item.Subtotal() - item.Total()
+	}
+
+	itemsTotal := totals.Subtotal - totals.Discount
+	cartDiscount := discountAmount(itemsTotal, c.Discounts)
+
+	totals.Discount += cartDiscount
+	totals.Total = itemsTotal - cartDiscount
+
+	return totals, nil
+}
+
+const percent = 100
+
+// discountAmount computes the discounts on amount. Each discount is computed
+// on the full amount, and the result never exceeds it.
+func discountAmount(amount float64, discounts []Discount) float64 {
+	total := 0.0
+
+	for _, d := range discounts {
+		switch d.Type {
+		case PercentageDiscount:
+			total += amount * d.Value / percent
+		case FixedAmountDiscount:
+			total += d.Value
+		}
+	}
+
+	return max(0, min(total, amount))
+}
+
+// Clone returns a deep copy of the Cart, so it can be modified without
+// affecting the original.
+func (c Cart) Clone() Cart {
+	out := c
+	out.Discounts = append([]Discount{}, c.Discounts...)
+	out.Items = cloneItems(c.Items)
+
+	if c.Saved != nil {
+		out.Saved = cloneItems(c.Saved)
+	}
+
+	return out
+}
+
+func cloneItems(items []CartItem) []CartItem {
+	out := make([]CartItem, len(items))
+
+	for k, item := range items {
+		item.Discounts = append([]Discount{}, item.Discounts...)
+		out[k] = item
+	}
+
+	return out
+}
+
+// Touch sets the Cart's UpdatedAt, and its CreatedAt if it isn't set yet.
+func (c *Cart) Touch(now time.Time) {
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = now
+	}
+
+	c.UpdatedAt = now
+}
+
+// Item returns the item matching the ID, if present.
+func (c Cart) Item(itemID string) (CartItem, bool) {
+	for _, item := range c.Items {
+		if item.ID == itemID {
+			return item, true
+		}
+	}
+
+	return CartItem{}, false
+}
+
+// AddItem adds the item to the Cart. If an item with the same ID is already
+// present, its quantity is increased instead.
+func (c *Cart) AddItem(item CartItem) error {
+	if item.Quantity <= 0 {
+		return ErrInvalidQuantity
+	}
+
+	for k := range c.Items {
+		if c.Items[k].ID == item.ID {
+			c.Items[k].Quantity += item.Quantity
+			return nil
+		}
+	}
+
+	if item.Discounts == nil {
+		item.Discounts = []Discount{}
+	}
+
+	c.Items = append(c.Items, item)
+
+	return nil
+}
+
+// SetQuantity sets the quantity of the item, removing it if quantity is 0.
+//
+// If no item could be found, it will return ErrItemNotFound.
+func (c *Cart) SetQuantity(itemID string, quantity int) error {
+	if quantity < 0 {
+		return ErrInvalidQuantity
+	}
+
+	if quantity == 0 {
+		return c.RemoveItem(itemID)
+	}
+
+	for k := range c.Items {
+		if c.Items[k].ID == itemID {
+			c.Items[k].Quantity = quantity
+			return nil
+		}
+	}
+
+	return ErrItemNotFound
+}
+
+// RemoveItem removes the item from the Cart.
+//
+// If no item could be found, it will return ErrItemNotFound.
+func (c *Cart) RemoveItem(itemID string) error {
+	for k := range c.Items {
+		if c.Items[k].ID == itemID {
+			c.Items = append(c.Items[:k], c.Items[k+1:]...)
+			return nil
+		}
+	}
+
+	return ErrItemNotFound
+}
+
+// TakeItem removes quantity units of the item from the Cart and returns
+// them, taking the whole item if quantity is 0.
+//
+// If no item could be found, it will return ErrItemNotFound.
+func (c *Cart) TakeItem(itemID string, quantity int) (CartItem, error) {
+	item, found := c.Item(itemID)
+	if !found {
+		return item, ErrItemNotFound
+	}
+
+	if quantity < 0 || quantity > item.Quantity {
+		return item, ErrInvalidQuantity
+	}
+
+	if quantity == 0 {
+		quantity = item.Quantity
+	}
+
+	remaining := item.Quantity - quantity
+	item.Quantity = quantity
+	item.Discounts = append([]Discount{}, item.Discounts...)
+
+	return item, c.SetQuantity(itemID, remaining)
+}
+
+// SetPrice sets the price of the item.
+//
+// If no item could be found, it will return ErrItemNotFound.
+func (c *Cart) SetPrice(itemID string, price Price) error {
+	for k := range c.Items {
+		if c.Items[k].ID == itemID {
+			c.Items[k].Price = price
+			return nil
+		}
+	}
+
+	return ErrItemNotFound
+}
+
+// SaveForLater moves the item to the saved items. If an item with the same
+// ID was already saved, its quantity is increased instead.
+//
+// If no item could be found, it will return ErrItemNotFound.
+func (c *Cart) SaveForLater(itemID string) error {
+	item, err := c.TakeItem(itemID, 0)
+	if err != nil {
+		return err
+	}
+
+	c.saveItem(item)
+
+	return nil
+}
+
+func (c *Cart) saveItem(item CartItem) {
+	for k := range c.Saved {
+		if c.Saved[k].ID == item.ID {
+			c.Saved[k].Quantity += item.Quantity
+			return
+		}
+	}
+
+	c.Saved = append(c.Saved, item)
+}
+
+// TakeSaved removes the saved item from the Cart and returns it.
+//
+// If no saved item could be found, it will return ErrItemNotFound.
+func (c *Cart) TakeSaved(itemID string) (CartItem, error) {
+	for k, item := range c.Saved {
+		if item.ID == itemID {
+			c.Saved = append(c.Saved[:k], c.Saved[k+1:]...)
+			return item, nil
+		}
+	}
+
+	return CartItem{}, ErrItemNotFound
+}
+
+// ApplyDiscount adds the discount to the Cart, replacing any discount
+// with the same ID.
+func (c *Cart) ApplyDiscount(discount Discount) {
+	for k := range c.Discounts {
+		if c.Discounts[k].ID == discount.ID {
+			c.Discounts[k] = discount
+			return
+		}
+	}
+
+	c.Discounts = append(c.Discounts, discount)
+}
+
+// RemoveDiscount removes the discount matching the ID from the Cart,
+// reporting whether it was present.
+func (c *Cart) RemoveDiscount(discountID string) bool {
+	for k := range c.Discounts {
+		if c.Discounts[k].ID == discountID {
+			c.Discounts = append(c.Discounts[:k], c.Discounts[k+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
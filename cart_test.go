@@ -0,0 +1,129 @@
+package kaimono
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestCartTotals(t *testing.T) {
+	cart := Cart{
+		Items: []CartItem{
+			{
+				ID:       "apple",
+				Quantity: 4,
+				Price:    Price{Currency: "EUR", Value: 2.5},
+				Discounts: []Discount{
+					{ID: "apple-promo", Type: PercentageDiscount, Value: 10},
+				},
+			},
+			{
+				ID:       "pear",
+				Quantity: 1,
+				Price:    Price{Currency: "EUR", Value: 3},
+			},
+		},
+		Discounts: []Discount{
+			{ID: "welcome", Type: FixedAmountDiscount, Value: 2},
+		},
+	}
+
+	totals, err := cart.Totals()
+	if err != nil {
+		t.Fatalf("could not compute totals: %v", err)
+	}
+
+	want := Totals{Currency: "EUR", Subtotal: 13, Discount: 3, Total: 10}
+	if !closeTo(totals.Subtotal, want.Subtotal) || !closeTo(totals.Discount, want.Discount) ||
+		!closeTo(totals.Total, want.Total) || totals.Currency != want.Currency {
+		t.Fatalf("got %+v, want %+v", totals, want)
+	}
+
+	cart.Discounts = append(cart.Discounts, Discount{ID: "huge", Type: FixedAmountDiscount, Value: 100})
+
+	totals, err = cart.Totals()
+	if err != nil {
+		t.Fatalf("could not compute totals: %v", err)
+	}
+
+	if totals.Total != 0 {
+		t.Fatalf("discounts should never make the total negative, got %v", totals.Total)
+	}
+
+	cart.Items = append(cart.Items, CartItem{ID: "plum", Quantity: 1, Price: Price{Currency: "USD", Value: 1}})
+	if _, err := cart.Totals(); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Fatalf("got %v, want %v", err, ErrCurrencyMismatch)
+	}
+}
+
+func TestCartItemOperations(t *testing.T) {
+	cart := mkEmptyTestCart()
+
+	if err := cart.AddItem(CartItem{ID: "apple", Quantity: 0}); !errors.Is(err, ErrInvalidQuantity) {
+		t.Fatalf("got %v, want %v", err, ErrInvalidQuantity)
+	}
+
+	for range 2 {
+		if err := cart.AddItem(CartItem{ID: "apple", Quantity: 2}); err != nil {
+			t.Fatalf("could not add item: %v", err)
+		}
+	}
+
+	if len(cart.Items) != 1 || cart.Items[0].Quantity != 4 {
+		t.Fatalf("expected quantities to be merged, got %+v", cart.Items)
+	}
+
+	if err := cart.SetQuantity("pear", 1); !errors.Is(err, ErrItemNotFound) {
+		t.Fatalf("got %v, want %v", err, ErrItemNotFound)
+	}
+
+	if err := cart.SetQuantity("apple", 0); err != nil {
+		t.Fatalf("could not set quantity: %v", err)
+	}
+
+	if len(cart.Items) != 0 {
+		t.Fatalf("expected item to be removed, got %+v", cart.Items)
+	}
+
+	cart.ApplyDiscount(Discount{ID: "promo", Type: PercentageDiscount, Value: 5})
+	cart.ApplyDiscount(Discount{ID: "promo", Type: PercentageDiscount, Value: 10})
+
+	if len(cart.Discounts) != 1 || cart.Discounts[0].Value != 10 {
+		t.Fatalf("expected discount to be replaced, got %+v", cart.Discounts)
+	}
+}
+
+func closeTo(a, b float64) bool {
+	const epsilon = 1e-9
+	return math.Abs(a-b) < epsilon
+}
+
+func TestCartTakeItem(t *testing.T) {
+	cart := mkEmptyTestCart()
+
+	if err := cart.AddItem(CartItem{ID: "apple", Quantity: 3}); err != nil {
+		t.Fatalf("could not add item: %v", err)
+	}
+
+	if _, err := cart.TakeItem("apple", 4); !errors.Is(err, ErrInvalidQuantity) {
+		t.Fatalf("got %v, want %v", err, ErrInvalidQuantity)
+	}
+
+	taken, err := cart.TakeItem("apple", 1)
+	if err != nil {
+		t.Fatalf("could not take item: %v", err)
+	}
+
+	if taken.Quantity != 1 || cart.Items[0].Quantity != 2 {
+		t.Fatalf("got %d taken and %d left, want 1 and 2", taken.Quantity, cart.Items[0].Quantity)
+	}
+
+	taken, err = cart.TakeItem("apple", 0)
+	if err != nil {
+		t.Fatalf("could not take item: %v", err)
+	}
+
+	if taken.Quantity != 2 || len(cart.Items) != 0 {
+		t.Fatalf("expected the whole item to be taken, got %d and %+v", taken.Quantity, cart.Items)
+	}
+}
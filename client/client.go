@@ -0,0 +1,292 @@
+// Package client provides a typed HTTP client for kaimono's standard and
+// admin routes.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+const (
+	DefaultBase      = "/cart"
+	DefaultAdminBase = "/admin/cart"
+)
+
+// Client calls a kaimono server. Standard methods (Get, Create, Update,
+// Delete) act on the cart of the session set by the Credentials, while
+// admin methods act on the cart matching the supplied ID.
+type Client struct {
+	serverURL   string
+	base        string
+	adminBase   string
+	httpClient  *http.Client
+	credentials Credentials
+	retry       RetryPolicy
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the underlying http.Client, http.DefaultClient is
+// used otherwise.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *Client) {
+		cl.httpClient = c
+	}
+}
+
+// WithBasePaths sets the paths the standard and admin routers are
+// mounted at, DefaultBase and DefaultAdminBase are used otherwise.
+func WithBasePaths(base, adminBase string) Option {
+	return func(cl *Client) {
+		cl.base = base
+		cl.adminBase = adminBase
+	}
+}
+
+// WithCredentials sets the credentials applied to every request, unless
+// overridden with ContextWithCredentials.
+func WithCredentials(creds Credentials) Option {
+	return func(cl *Client) {
+		cl.credentials = creds
+	}
+}
+
+// WithRetryPolicy sets the retry policy, requests aren't retried otherwise.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(cl *Client) {
+		cl.retry = policy
+	}
+}
+
+func New(serverURL string, opts ...Option) *Client {
+	cl := &Client{
+		serverURL:  strings.TrimSuffix(serverURL, "/"),
+		base:       DefaultBase,
+		adminBase:  DefaultAdminBase,
+		httpClient: http.DefaultClient,
+		retry:      NoRetry{},
+	}
+
+	for _, opt := range opts {
+		opt(cl)
+	}
+
+	return cl
+}
+
+// Get returns the Cart associated to the session.
+func (cl *Client) Get(ctx context.Context) (kaimono.Cart, error) {
+	resp := kaimono.GetCartResponse{}
+	err := cl.do(ctx, call{method: http.MethodGet, path: cl.base + "/", out: &resp})
+
+	return resp.Data, err
+}
+
+// Create creates a new Cart for the session.
+func (cl *Client) Create(ctx context.Context) (kaimono.Cart, error) {
+	resp := kaimono.CreateCartResponse{}
+	err := cl.do(ctx, call{method: http.MethodPost, path: cl.base + "/", out: &resp})
+
+	return resp.Data, err
+}
+
+// Update updates the Cart associated to the session, cart.ID must match it.
+func (cl *Client) Update(ctx context.Context, cart kaimono.Cart) (kaimono.Cart, error) {
+	resp := kaimono.UpdateCartResponse{}
+	err := cl.do(ctx, call{
+		method:  http.MethodPut,
+		path:    cl.base + "/",
+		payload: kaimono.UpdateCartRequest{Data: cart},
+		out:     &resp,
+	})
+
+	return resp.Data, err
+}
+
+// Delete deletes the Cart associated to the session.
+func (cl *Client) Delete(ctx context.Context) error {
+	return cl.do(ctx, call{method: http.MethodDelete, path: cl.base + "/"})
+}
+
+// GetWithID returns the Cart matching the ID.
+func (cl *Client) GetWithID(ctx context.Context, cartID string) (kaimono.Cart, error) {
+	resp := kaimono.GetCartByIDResponse{}
+	err := cl.do(ctx, call{
+		method: http.MethodGet,
+		path:   cl.adminPath(cartID),
+		op:     kaimono.ReadOp,
+		id:     cartID,
+		out:    &resp,
+	})
+
+	return resp.Data, err
+}
+
+// CreateWithoutSession creates a Cart that isn't assigned to any session.
+func (cl *Client) CreateWithoutSession(ctx context.Context) (kaimono.Cart, error) {
+	resp := kaimono.CreateCartResponse{}
+	err := cl.do(ctx, call{method: http.MethodPost, path: cl.adminPath(""), op: kaimono.CreateOp, out: &resp})
+
+	return resp.Data, err
+}
+
+// UpdateWithID updates the Cart matching cart.ID.
+func (cl *Client) UpdateWithID(ctx context.Context, cart kaimono.Cart) (kaimono.Cart, error) {
+	resp := kaimono.UpdateCartResponse{}
+	err := cl.do(ctx, call{
+		method:  http.MethodPut,
+		path:    cl.adminPath(cart.ID),
+		op:      kaimono.UpdateOp,
+		id:      cart.ID,
+		payload: kaimono.UpdateCartRequest{Data: cart},
+		out:     &resp,
+	})
+
+	return resp.Data, err
+}
+
+// DeleteWithID deletes the Cart matching the ID.
+func (cl *Client) DeleteWithID(ctx context.Context, cartID string) error {
+	return cl.do(ctx, call{method: http.MethodDelete, path: cl.adminPath(cartID), op: kaimono.DeleteOp, id: cartID})
+}
+
+// AssignWithID assigns the Cart matching the ID to the session.
+func (cl *Client) AssignWithID(ctx context.Context, cartID, sessionToken string) error {
+	return cl.do(ctx, call{
+		method:  http.MethodPost,
+		path:    cl.adminPath(cartID) + "/assign",
+		op:      kaimono.UpdateOp,
+		id:      cartID,
+		payload: kaimono.AssignCartRequest{Data: kaimono.CartAssignment{SessionToken: sessionToken}},
+	})
+}
+
+func (cl *Client) adminPath(cartID string) string {
+	return strings.TrimSuffix(cl.adminBase, "/") + "/" + cartID
+}
+
+// call describes a single API call. The op and id are used to build a
+// NotAuthorizedError when an admin route returns 403.
+type call struct {
+	method  string
+	path    string
+	op      kaimono.OperationType
+	id      string
+	payload any
+	out     any
+}
+
+func (cl *Client) do(ctx context.Context, c call) error {
+	var body []byte
+
+	if c.payload != nil {
+		data, err := json.Marshal(c.payload)
+		if err != nil {
+			return fmt.Errorf("could not encode request: %w", err)
+		}
+
+		body = data
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := cl.send(ctx, c.method, c.path, body)
+
+		delay, retry := cl.retry.Retry(c.method, attempt, resp, err)
+		if !retry {
+			if err != nil {
+				return err
+			}
+
+			return decodeResponse(resp, c)
+		}
+
+		if resp != nil {
+			drain(resp)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up retrying: %w", ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (cl *Client) send(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, cl.serverURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	creds := cl.credentials
+	if fromCtx, ok := credentialsFromContext(ctx); ok {
+		creds = fromCtx
+	}
+
+	if creds != nil {
+		if err := creds.Apply(req); err != nil {
+			return nil, fmt.Errorf("could not apply credentials: %w", err)
+		}
+	}
+
+	resp, err := cl.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	return resp, nil
+}
+
+func decodeResponse(resp *http.Response, c call) error {
+	defer drain(resp)
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		// either a kaimono.ErrorResponse or, for services created
+		// WithProblemDetails, a kaimono.Problem.
+		errResp := struct {
+			Detail string            `json:"detail"`
+			Error  string            `json:"error"`
+			Code   kaimono.ErrorCode `json:"code"`
+		}{}
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+
+		msg := errResp.Detail
+		if msg == "" {
+			msg = errResp.Error
+		}
+
+		return newAPIError(resp.StatusCode, errResp.Code, msg, c)
+	}
+
+	if c.out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(c.out); err != nil {
+		return fmt.Errorf("could not decode response: %w", err)
+	}
+
+	return nil
+}
+
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
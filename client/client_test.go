@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/aalbacetef/kaimono"
+	"github.com/aalbacetef/kaimono/memstore"
+)
+
+const testSessionHeader = "X-Session"
+
+type testBackend struct{}
+
+func (testBackend) GetUserContext(req *http.Request) (kaimono.UserContext, error) {
+	token := req.Header.Get(testSessionHeader)
+	if token == "" {
+		return kaimono.UserContext{}, kaimono.ErrSessionNotFound
+	}
+
+	return kaimono.UserContext{SessionToken: token}, nil
+}
+
+func (testBackend) AuthorizeUser(req *http.Request, op kaimono.Operation, resourceID string) error {
+	if req.Header.Get("Authorization") == "Bearer admin" {
+		return nil
+	}
+
+	return kaimono.NotAuthorizedError{Operation: op, ID: resourceID}
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	svc, err := kaimono.NewService(memstore.New(), testBackend{}, testBackend{}, nil)
+	if err != nil {
+		t.Fatalf("could not create service: %v", err)
+	}
+
+	mux := chi.NewRouter()
+	mux.Mount(DefaultBase, svc.Router("/"))
+	mux.Mount(DefaultAdminBase, svc.AdminRouter("/"))
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestStandardMethods(t *testing.T) {
+	srv := newTestServer(t)
+	cl := New(srv.URL, WithCredentials(Header(testSessionHeader, "session-a")))
+	ctx := context.Background()
+
+	if _, err := cl.Get(ctx); !errors.Is(err, kaimono.ErrCartNotFound) {
+		t.Fatalf("got %v, want %v", err, kaimono.ErrCartNotFound)
+	}
+
+	cart, err := cl.Create(ctx)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	if _, err := cl.Create(ctx); !errors.Is(err, kaimono.ErrAlreadyExists) {
+		t.Fatalf("got %v, want %v", err, kaimono.ErrAlreadyExists)
+	}
+
+	cart.Items = append(cart.Items, kaimono.CartItem{ID: "apple", Quantity: 2, Price: kaimono.Price{Currency: "EUR", Value: 1}})
+	if _, err := cl.Update(ctx, cart); err != nil {
+		t.Fatalf("could not update cart: %v", err)
+	}
+
+	found, err := cl.Get(ctx)
+	if err != nil {
+		t.Fatalf("could not get cart: %v", err)
+	}
+
+	if len(found.Items) != 1 || found.Items[0].ID != "apple" {
+		t.Fatalf("unexpected cart: %+v", found)
+	}
+
+	noSession := ContextWithCredentials(ctx, Header("X-Other", "value"))
+	if _, err := cl.Get(noSession); !errors.Is(err, kaimono.ErrSessionNotFound) {
+		t.Fatalf("got %v, want %v", err, kaimono.ErrSessionNotFound)
+	}
+
+	if err := cl.Delete(ctx); err != nil {
+		t.Fatalf("could not delete cart: %v", err)
+	}
+}
+
+func TestAdminMethods(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+
+	anonymous := New(srv.URL)
+	if _, err := anonymous.GetWithID(ctx, "some-id"); !errors.As(err, &kaimono.NotAuthorizedError{}) {
+		t.Fatalf("got %v, want a NotAuthorizedError", err)
+	}
+
+	admin := New(srv.URL, WithCredentials(BearerToken("admin")))
+
+	cart, err := admin.CreateWithoutSession(ctx)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	if err := admin.AssignWithID(ctx, cart.ID, "session-b"); err != nil {
+		t.Fatalf("could not assign cart: %v", err)
+	}
+
+	session := New(srv.URL, WithCredentials(Header(testSessionHeader, "session-b")))
+	if found, err := session.Get(ctx); err != nil || found.ID != cart.ID {
+		t.Fatalf("got (%+v, %v), want the assigned cart", found, err)
+	}
+
+	if err := admin.DeleteWithID(ctx, cart.ID); err != nil {
+		t.Fatalf("could not delete cart: %v", err)
+	}
+
+	if _, err := admin.GetWithID(ctx, cart.ID); !errors.Is(err, kaimono.ErrCartNotFound) {
+		t.Fatalf("got %v, want %v", err, kaimono.ErrCartNotFound)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {"id": "cart-id"}, "error": ""}`))
+	}))
+	defer srv.Close()
+
+	policy := Backoff{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	cl := New(srv.URL, WithRetryPolicy(policy))
+
+	cart, err := cl.GetWithID(context.Background(), "cart-id")
+	if err != nil {
+		t.Fatalf("could not get cart: %v", err)
+	}
+
+	if cart.ID != "cart-id" || calls.Load() != 3 {
+		t.Fatalf("got cart %q after %d calls, want cart-id after 3", cart.ID, calls.Load())
+	}
+
+	calls.Store(0)
+
+	if _, err := cl.CreateWithoutSession(context.Background()); err == nil {
+		t.Fatalf("expected POST not to be retried")
+	}
+
+	if calls.Load() != 1 {
+		t.Fatalf("got %d calls, want 1", calls.Load())
+	}
+}
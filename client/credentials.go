@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// Credentials add the session (or admin) credentials to a request.
+type Credentials interface {
+	Apply(req *http.Request) error
+}
+
+// CredentialsFunc adapts a function to the Credentials interface.
+type CredentialsFunc func(req *http.Request) error
+
+func (f CredentialsFunc) Apply(req *http.Request) error {
+	return f(req)
+}
+
+// SessionCookie sends the session token in the cookie called name.
+func SessionCookie(name, sessionToken string) Credentials {
+	return CredentialsFunc(func(req *http.Request) error {
+		req.AddCookie(&http.Cookie{Name: name, Value: sessionToken})
+		return nil
+	})
+}
+
+// Header sets the header to the given value, e.g: a session token header.
+func Header(name, value string) Credentials {
+	return CredentialsFunc(func(req *http.Request) error {
+		req.Header.Set(name, value)
+		return nil
+	})
+}
+
+// BearerToken sets the Authorization header.
+func BearerToken(token string) Credentials {
+	return Header("Authorization", "Bearer "+token)
+}
+
+// Chain applies all the credentials in order.
+func Chain(creds ...Credentials) Credentials {
+	return CredentialsFunc(func(req *http.Request) error {
+		for _, c := range creds {
+			if err := c.Apply(req); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+type credentialsKey struct{}
+
+// ContextWithCredentials returns a context whose requests use creds instead
+// of the Client's credentials. This is useful when acting on behalf of
+// several sessions with the same Client.
+func ContextWithCredentials(ctx context.Context, creds Credentials) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, creds)
+}
+
+func credentialsFromContext(ctx context.Context) (Credentials, bool) {
+	creds, ok := ctx.Value(credentialsKey{}).(Credentials)
+	return creds, ok
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+// APIError is returned for every non-2xx response. It unwraps to the
+// matching kaimono error (e.g: kaimono.ErrCartNotFound), if any, so
+// callers can use errors.Is and errors.As.
+type APIError struct {
+	StatusCode int
+	Code       kaimono.ErrorCode
+	Message    string
+	err        error
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("server returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("server returned %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// codeErrors maps the error codes back to the errors returned by the handlers.
+var codeErrors = map[kaimono.ErrorCode]error{
+	kaimono.CodeCartNotFound:       kaimono.ErrCartNotFound,
+	kaimono.CodeSessionNotFound:    kaimono.ErrSessionNotFound,
+	kaimono.CodeInvalidCredentials: kaimono.ErrInvalidCredentials,
+	kaimono.CodeAlreadyExists:      kaimono.ErrAlreadyExists,
+	kaimono.CodeCartIDMismatch:     kaimono.ErrInvalidID,
+	kaimono.CodeItemNotFound:       kaimono.ErrItemNotFound,
+
+	kaimono.CodeIdempotencyKeyReused: kaimono.ErrIdempotencyKeyReused,
+	kaimono.CodeIdempotencyKeyInUse:  kaimono.ErrIdempotencyKeyInUse,
+	kaimono.CodeRateLimited:          kaimono.ErrRateLimited,
+}
+
+// newAPIError maps the error code back to the error returned by the
+// handler. Responses without a code are mapped from their status code,
+// following the status codes documented on the Service methods.
+func newAPIError(status int, code kaimono.ErrorCode, msg string, c call) *APIError {
+	apiErr := &APIError{StatusCode: status, Code: code, Message: msg}
+
+	if err, found := codeErrors[code]; found {
+		apiErr.err = err
+		return apiErr
+	}
+
+	notAuthorized := kaimono.NotAuthorizedError{
+		Operation: kaimono.Operation{Resource: "cart", Type: c.op},
+		ID:        c.id,
+	}
+
+	if code == kaimono.CodeNotAuthorized {
+		apiErr.err = notAuthorized
+		return apiErr
+	}
+
+	if code != "" {
+		return apiErr
+	}
+
+	switch status {
+	case http.StatusNotFound:
+		apiErr.err = kaimono.ErrCartNotFound
+	case http.StatusConflict:
+		apiErr.err = kaimono.ErrAlreadyExists
+	case http.StatusBadRequest:
+		if msg == kaimono.ErrSessionNotFound.Error() {
+			apiErr.err = kaimono.ErrSessionNotFound
+		}
+	case http.StatusForbidden:
+		if msg == kaimono.ErrInvalidID.Error() {
+			apiErr.err = kaimono.ErrInvalidID
+			break
+		}
+
+		apiErr.err = notAuthorized
+	}
+
+	return apiErr
+}
@@ -0,0 +1,49 @@
+package client
+
+import (
+	"net/http"
+	"time"
+)
+
+// RetryPolicy decides whether a request should be retried, and after how
+// long. It is called after every attempt (starting at 1) with either the
+// response or the error from sending the request.
+type RetryPolicy interface {
+	Retry(method string, attempt int, resp *http.Response, err error) (time.Duration, bool)
+}
+
+// NoRetry never retries.
+type NoRetry struct{}
+
+func (NoRetry) Retry(string, int, *http.Response, error) (time.Duration, bool) {
+	return 0, false
+}
+
+// Backoff retries idempotent requests (all but POST) on transport errors,
+// 429 and 5xx responses, doubling the delay after every attempt.
+type Backoff struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func (b Backoff) Retry(method string, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= b.MaxAttempts || method == http.MethodPost {
+		return 0, false
+	}
+
+	if err == nil && !retryableStatus(resp.StatusCode) {
+		return 0, false
+	}
+
+	delay := b.BaseDelay << (attempt - 1)
+	if b.MaxDelay > 0 && delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+
+	return delay, true
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/aalbacetef/kaimono"
+	"github.com/aalbacetef/kaimono/memstore"
+	"github.com/aalbacetef/kaimono/policy"
+	"github.com/aalbacetef/kaimono/session"
+)
+
+func newDB(cfg StorageConfig) (kaimono.DB, error) {
+	switch cfg.Backend {
+	case "file":
+		store, err := memstore.Open(cfg.Path)
+		if err != nil {
+			return nil, fmt.Errorf("could not open storage: %w", err)
+		}
+
+		return store, nil
+	default:
+		return memstore.New(), nil
+	}
+}
+
+func newUserContextFetcher(cfg SessionConfig) kaimono.UserContextFetcher {
+	switch cfg.Strategy {
+	case "header":
+		return session.Header{Name: cfg.Name, UserHeader: cfg.UserHeader}
+	case "bearer":
+		return session.Bearer{UserHeader: cfg.UserHeader}
+	case "signed":
+		return session.Signed{Key: []byte(cfg.SigningKey), Cookie: cfg.Name}
+	default:
+		return session.Cookie{Name: cfg.Name, UserHeader: cfg.UserHeader}
+	}
+}
+
+func newAuthorizer(
+	cfg AdminConfig, fetcher kaimono.UserContextFetcher, db kaimono.DB, logger *slog.Logger,
+) (kaimono.Authorizer, error) {
+	if cfg.Policy != "file" {
+		return policyAuthorizer{cfg: cfg}, nil
+	}
+
+	opts := []policy.Option{}
+	if logger != nil {
+		opts = append(opts, policy.WithLogger(logger))
+	}
+
+	authorizer, err := policy.Load(cfg.PolicyFile, fetcher, db, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not load admin policy: %w", err)
+	}
+
+	return authorizer, nil
+}
+
+// policyAuthorizer implements the simple admin policies available from
+// the config.
+type policyAuthorizer struct {
+	cfg AdminConfig
+}
+
+func (a policyAuthorizer) AuthorizeUser(req *http.Request, op kaimono.Operation, resourceID string) error {
+	switch a.cfg.Policy {
+	case "allow-all":
+		return nil
+	case "token":
+		token, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if found && subtle.ConstantTimeCompare([]byte(token), []byte(a.cfg.Token)) == 1 {
+			return nil
+		}
+	}
+
+	return kaimono.NotAuthorizedError{Operation: op, ID: resourceID}
+}
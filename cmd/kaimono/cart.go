@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/aalbacetef/kaimono"
+	"github.com/aalbacetef/kaimono/client"
+)
+
+const cartUsage = `usage: kaimono cart <command> [flags]
+
+commands:
+  get <id>                          print the cart
+  create                            create a cart without a session
+  update <id> -from <file.json|->   replace the cart's contents
+  delete <id>                       delete the cart
+  assign <id> -session <token>      assign the cart to a session
+
+flags:
+  -config <path>     client config file (default: $KAIMONO_CLI_CONFIG or ~/.config/kaimono/cli.json)
+  -server <url>      server URL, overrides the config
+  -o <table|json>    output format (default: table)
+`
+
+var (
+	errUsage       = errors.New("invalid usage")
+	errInvalidCart = errors.New("could not decode cart")
+)
+
+// cartCommand holds the parsed flags shared by every cart subcommand.
+type cartCommand struct {
+	flags      *flag.FlagSet
+	configPath string
+	server     string
+	output     string
+	from       string
+	session    string
+	stdin      io.Reader
+	stdout     io.Writer
+}
+
+func runCart(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing cart command\n\n%s", errUsage, cartUsage)
+	}
+
+	cmd := &cartCommand{stdin: stdin, stdout: stdout}
+	cmd.flags = flag.NewFlagSet("cart "+args[0], flag.ContinueOnError)
+	cmd.flags.SetOutput(io.Discard)
+	cmd.flags.StringVar(&cmd.configPath, "config", os.Getenv("KAIMONO_CLI_CONFIG"), "")
+	cmd.flags.StringVar(&cmd.server, "server", "", "")
+	cmd.flags.StringVar(&cmd.output, "o", "table", "")
+	cmd.flags.StringVar(&cmd.from, "from", "", "")
+	cmd.flags.StringVar(&cmd.session, "session", "", "")
+
+	positional, err := parseInterspersed(cmd.flags, args[1:])
+	if err != nil {
+		return fmt.Errorf("%w: %w\n\n%s", errUsage, err, cartUsage)
+	}
+
+	if cmd.output != "table" && cmd.output != "json" {
+		return fmt.Errorf("%w: unknown output format '%s'", errUsage, cmd.output)
+	}
+
+	cfg, err := LoadClientConfig(cmd.configPath, os.Getenv)
+	if err != nil {
+		return err
+	}
+
+	if cmd.server != "" {
+		cfg.Server = cmd.server
+	}
+
+	return cmd.run(ctx, args[0], positional, newAdminClient(cfg))
+}
+
+// newAdminClient returns a client sending the headers and cookies from cfg.
+func newAdminClient(cfg ClientConfig) *client.Client {
+	creds := make([]client.Credentials, 0, len(cfg.Headers)+len(cfg.Cookies))
+
+	for k, v := range cfg.Headers {
+		creds = append(creds, client.Header(k, v))
+	}
+
+	for name, value := range cfg.Cookies {
+		creds = append(creds, client.SessionCookie(name, value))
+	}
+
+	return client.New(
+		cfg.Server,
+		client.WithBasePaths(client.DefaultBase, cfg.AdminBase),
+		client.WithCredentials(client.Chain(creds...)),
+	)
+}
+
+func (cmd *cartCommand) run(ctx context.Context, name string, args []string, cl *client.Client) error {
+	if name == "create" {
+		cart, err := cl.CreateWithoutSession(ctx)
+		if err != nil {
+			return err
+		}
+
+		return cmd.printCart(cart)
+	}
+
+	if len(args) != 1 {
+		return fmt.Errorf("%w: cart %s expects exactly one cart ID\n\n%s", errUsage, name, cartUsage)
+	}
+
+	cartID := args[0]
+
+	switch name {
+	case "get":
+		cart, err := cl.GetWithID(ctx, cartID)
+		if err != nil {
+			return err
+		}
+
+		return cmd.printCart(cart)
+	case "update":
+		cart, err := cmd.readCart()
+		if err != nil {
+			return err
+		}
+
+		cart.ID = cartID
+
+		updated, err := cl.UpdateWithID(ctx, cart)
+		if err != nil {
+			return err
+		}
+
+		return cmd.printCart(updated)
+	case "delete":
+		return cl.DeleteWithID(ctx, cartID)
+	case "assign":
+		if cmd.session == "" {
+			return fmt.Errorf("%w: cart assign requires -session", errUsage)
+		}
+
+		return cl.AssignWithID(ctx, cartID, cmd.session)
+	default:
+		return fmt.Errorf("%w: unknown cart command '%s'\n\n%s", errUsage, name, cartUsage)
+	}
+}
+
+// readCart reads the cart from the -from file, or stdin if it is "-". Both
+// a bare Cart and the {"data": Cart} request envelope are accepted, other
+// shapes and unknown fields are rejected.
+func (cmd *cartCommand) readCart() (kaimono.Cart, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	switch cmd.from {
+	case "":
+		return kaimono.Cart{}, fmt.Errorf("%w: cart update requires -from", errUsage)
+	case "-":
+		data, err = io.ReadAll(cmd.stdin)
+	default:
+		data, err = os.ReadFile(cmd.from)
+	}
+
+	if err != nil {
+		return kaimono.Cart{}, fmt.Errorf("could not read cart: %w", err)
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return kaimono.Cart{}, fmt.Errorf("%w: expected a JSON object", errInvalidCart)
+	}
+
+	// the envelope is told apart by its key, as the cart's ID is optional.
+	if envelope, found := fields["data"]; found {
+		if len(fields) != 1 || !bytes.HasPrefix(bytes.TrimSpace(envelope), []byte("{")) {
+			return kaimono.Cart{}, fmt.Errorf("%w: the envelope must only have a 'data' object", errInvalidCart)
+		}
+
+		data = envelope
+	}
+
+	cart := kaimono.Cart{}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&cart); err != nil {
+		return cart, fmt.Errorf("%w: %w", errInvalidCart, err)
+	}
+
+	return cart, nil
+}
+
+func (cmd *cartCommand) printCart(cart kaimono.Cart) error {
+	if cmd.output == "json" {
+		enc := json.NewEncoder(cmd.stdout)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(cart); err != nil {
+			return fmt.Errorf("could not encode cart: %w", err)
+		}
+
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(cmd.stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "CART\t%s\n", cart.ID)
+	fmt.Fprintf(tw, "DISCOUNTS\t%s\n\n", formatDiscounts(cart.Discounts))
+	fmt.Fprintln(tw, "ITEM\tQUANTITY\tPRICE\tDISCOUNTS")
+
+	for _, item := range cart.Items {
+		fmt.Fprintf(
+			tw, "%s\t%d\t%s %s\t%s\n",
+			item.ID, item.Quantity,
+			strconv.FormatFloat(item.Price.Value, 'f', -1, 64), item.Price.Currency,
+			formatDiscounts(item.Discounts),
+		)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("could not write output: %w", err)
+	}
+
+	return nil
+}
+
+func formatDiscounts(discounts []kaimono.Discount) string {
+	if len(discounts) == 0 {
+		return "-"
+	}
+
+	parts := make([]string, 0, len(discounts))
+	for _, d := range discounts {
+		parts = append(parts, fmt.Sprintf("%s(%s %s)", d.ID, d.Type, strconv.FormatFloat(d.Value, 'f', -1, 64)))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// parseInterspersed parses flags that may appear before or after the
+// positional arguments.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, fmt.Errorf("could not parse flags: %w", err)
+		}
+
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
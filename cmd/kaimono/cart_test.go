@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	cfg := defaultConfig()
+	cfg.Session = SessionConfig{Strategy: "header", Name: "X-Session"}
+	cfg.Admin = AdminConfig{Policy: "token", Token: "secret"}
+
+	svc, err := newService(cfg, nil)
+	if err != nil {
+		t.Fatalf("could not create service: %v", err)
+	}
+
+	servers, err := newServers(cfg, svc)
+	if err != nil {
+		t.Fatalf("could not create servers: %v", err)
+	}
+
+	srv := httptest.NewServer(servers[0].Handler)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func writeClientConfig(t *testing.T, cfg ClientConfig) string {
+	t.Helper()
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("could not encode config: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "cli.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("could not write config: %v", err)
+	}
+
+	return path
+}
+
+func TestCartCommands(t *testing.T) {
+	srv := newTestServer(t)
+	configPath := writeClientConfig(t, ClientConfig{
+		Server:    srv.URL,
+		AdminBase: "/admin/cart",
+		Headers:   map[string]string{"Authorization": "Bearer secret"},
+	})
+
+	ctx := context.Background()
+
+	run := func(t *testing.T, stdin string, args ...string) string {
+		t.Helper()
+
+		stdout := &bytes.Buffer{}
+		args = append(args, "-config", configPath)
+
+		if err := runCart(ctx, args, strings.NewReader(stdin), stdout); err != nil {
+			t.Fatalf("cart %v: %v", args, err)
+		}
+
+		return stdout.String()
+	}
+
+	created := kaimono.Cart{}
+	if err := json.Unmarshal([]byte(run(t, "", "create", "-o", "json")), &created); err != nil {
+		t.Fatalf("could not decode created cart: %v", err)
+	}
+
+	update := `{"items": [{"id": "apple", "quantity": 3, "price": {"currency": "EUR", "value": 1.5}}]}`
+	if out := run(t, update, "update", created.ID, "-from", "-"); !strings.Contains(out, "apple") {
+		t.Fatalf("updated cart is missing the item:\n%s", out)
+	}
+
+	out := run(t, "", "get", created.ID)
+	if !strings.Contains(out, created.ID) || !strings.Contains(out, "1.5 EUR") {
+		t.Fatalf("unexpected table output:\n%s", out)
+	}
+
+	run(t, "", "assign", created.ID, "-session", "some-session")
+	run(t, "", "delete", created.ID)
+
+	err := runCart(ctx, []string{"get", created.ID, "-config", configPath}, nil, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), kaimono.ErrCartNotFound.Error()) {
+		t.Fatalf("got %v, want a cart not found error", err)
+	}
+}
+
+func TestCartCommandsUnauthorized(t *testing.T) {
+	srv := newTestServer(t)
+	configPath := writeClientConfig(t, ClientConfig{Server: srv.URL, AdminBase: "/admin/cart"})
+
+	err := runCart(context.Background(), []string{"create", "-config", configPath}, nil, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("got %v, want a 403 error", err)
+	}
+}
+
+func TestCartCommandsUsage(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"get"},
+		{"update", "some-id"},
+		{"assign", "some-id"},
+		{"get", "some-id", "-o", "yaml"},
+	}
+
+	configPath := writeClientConfig(t, defaultClientConfig())
+
+	for _, args := range tests {
+		if len(args) > 0 {
+			args = append(args, "-config", configPath)
+		}
+
+		err := runCart(context.Background(), args, nil, &bytes.Buffer{})
+		if !errors.Is(err, errUsage) {
+			t.Fatalf("cart %v: got %v, want %v", args, err, errUsage)
+		}
+	}
+}
+
+func TestReadCart(t *testing.T) {
+	items := `"items": [{"id": "apple", "quantity": 3, "price": {"currency": "EUR", "value": 1.5}}]`
+
+	tests := []struct {
+		label string
+		input string
+		id    string
+	}{
+		{"bare cart", `{` + items + `}`, ""},
+		{"bare cart with ID", `{"id": "cart-id", ` + items + `}`, "cart-id"},
+		{"envelope", `{"data": {` + items + `}}`, ""},
+		{"envelope with ID", `{"data": {"id": "cart-id", ` + items + `}}`, "cart-id"},
+	}
+
+	for _, c := range tests {
+		cmd := &cartCommand{from: "-", stdin: strings.NewReader(c.input)}
+
+		cart, err := cmd.readCart()
+		if err != nil {
+			t.Fatalf("(%s) could not read cart: %v", c.label, err)
+		}
+
+		if cart.ID != c.id || len(cart.Items) != 1 || cart.Items[0].Quantity != 3 {
+			t.Fatalf("(%s) unexpected cart: %+v", c.label, cart)
+		}
+	}
+
+	invalid := []struct {
+		label string
+		input string
+	}{
+		{"not an object", `[]`},
+		{"null", `null`},
+		{"unknown field", `{"itemz": []}`},
+		{"envelope with other keys", `{"data": {` + items + `}, "meta": {}}`},
+		{"null envelope", `{"data": null}`},
+		{"unknown field in envelope", `{"data": {"itemz": []}}`},
+	}
+
+	for _, c := range invalid {
+		cmd := &cartCommand{from: "-", stdin: strings.NewReader(c.input)}
+
+		if _, err := cmd.readCart(); !errors.Is(err, errInvalidCart) {
+			t.Fatalf("(%s) got %v, want %v", c.label, err, errInvalidCart)
+		}
+	}
+}
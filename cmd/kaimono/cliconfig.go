@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ClientConfig configures the admin client used by the cart commands. It
+// is read from $KAIMONO_CLI_CONFIG, falling back to
+// $XDG_CONFIG_HOME/kaimono/cli.json.
+type ClientConfig struct {
+	// Server is the base URL of the kaimono server, e.g: http://localhost:8080.
+	Server string `json:"server"`
+
+	// AdminBase is the path the admin router is mounted at.
+	AdminBase string `json:"admin-base"`
+
+	// Headers are added to every request, e.g: Authorization.
+	Headers map[string]string `json:"headers"`
+
+	// Cookies are added to every request.
+	Cookies map[string]string `json:"cookies"`
+}
+
+func defaultClientConfig() ClientConfig {
+	return ClientConfig{
+		Server:    "http://localhost:8080",
+		AdminBase: "/admin/cart",
+		Headers:   map[string]string{},
+		Cookies:   map[string]string{},
+	}
+}
+
+// LoadClientConfig reads the client config at path. If path is empty,
+// the default location is used and it is fine for the file to not exist.
+func LoadClientConfig(path string, getenv func(string) string) (ClientConfig, error) {
+	cfg := defaultClientConfig()
+
+	optional := path == ""
+	if optional {
+		path = defaultClientConfigPath()
+	}
+
+	data, err := os.ReadFile(path)
+
+	switch {
+	case optional && errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return cfg, fmt.Errorf("could not read config: %w", err)
+	default:
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("could not decode config: %w", err)
+		}
+	}
+
+	if v := getenv("KAIMONO_SERVER"); v != "" {
+		cfg.Server = v
+	}
+
+	if v := getenv("KAIMONO_ADMIN_TOKEN"); v != "" {
+		if cfg.Headers == nil {
+			cfg.Headers = map[string]string{}
+		}
+
+		cfg.Headers["Authorization"] = "Bearer " + v
+	}
+
+	return cfg, nil
+}
+
+func defaultClientConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "kaimono", "cli.json")
+}
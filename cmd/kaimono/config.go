@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+// Config is the server configuration. It is read from an optional JSON
+// file and then overridden by KAIMONO_* environment variables.
+type Config struct {
+	Storage         StorageConfig `json:"storage"`
+	Session         SessionConfig `json:"session"`
+	Admin           AdminConfig   `json:"admin"`
+	Listen          ListenConfig  `json:"listen"`
+	TLS             TLSConfig     `json:"tls"`
+	ShutdownTimeout Duration      `json:"shutdown-timeout"`
+}
+
+// StorageConfig selects the DB backend: "memory" or "file".
+type StorageConfig struct {
+	Backend string `json:"backend"`
+	Path    string `json:"path"`
+}
+
+// SessionConfig selects how the session token is extracted from requests:
+// "cookie" reads the cookie called Name, "header" reads the header called Name,
+// "bearer" reads an "Authorization: Bearer" header. If UserHeader is set, the
+// user ID is read from that header. "signed" reads a token signed with
+// SigningKey holding both, from the cookie called Name if set or from an
+// "Authorization: Bearer" header otherwise.
+type SessionConfig struct {
+	Strategy   string `json:"strategy"`
+	Name       string `json:"name"`
+	UserHeader string `json:"user-header"`
+	SigningKey string `json:"signing-key"`
+}
+
+// AdminConfig selects the policy guarding the admin routes: "deny-all",
+// "allow-all", "token", requiring an "Authorization: Bearer" header matching
+// Token, or "file", evaluating the policy at PolicyFile (see the policy
+// package) which is reloaded when it changes.
+type AdminConfig struct {
+	Policy     string `json:"policy"`
+	Token      string `json:"token"`
+	PolicyFile string `json:"policy-file"`
+}
+
+// ListenConfig holds the listen addresses and the base paths the routers
+// are mounted at. If AdminAddr is empty, the admin routes are served on Addr.
+// The OpenAPI document and the GraphQL API are served on Addr at OpenAPIPath
+// and GraphQLPath, and the metrics alongside the admin routes at
+// MetricsPath, unless they are empty.
+type ListenConfig struct {
+	Addr        string `json:"addr"`
+	AdminAddr   string `json:"admin-addr"`
+	Base        string `json:"base"`
+	AdminBase   string `json:"admin-base"`
+	OpenAPIPath string `json:"openapi-path"`
+	GraphQLPath string `json:"graphql-path"`
+	MetricsPath string `json:"metrics-path"`
+}
+
+// TLSConfig enables TLS when both files are set.
+type TLSConfig struct {
+	CertFile string `json:"cert-file"`
+	KeyFile  string `json:"key-file"`
+}
+
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// Duration is a time.Duration that is encoded as a string, e.g: "10s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
+
+func defaultConfig() Config {
+	return Config{
+		Storage: StorageConfig{Backend: "memory"},
+		Session: SessionConfig{Strategy: "cookie", Name: "kaimono-session"},
+		Admin:   AdminConfig{Policy: "deny-all"},
+		Listen: ListenConfig{
+			Addr:        ":8080",
+			Base:        "/cart",
+			AdminBase:   "/admin/cart",
+			OpenAPIPath: "/openapi.json",
+			GraphQLPath: "/graphql",
+			MetricsPath: "/metrics",
+		},
+		ShutdownTimeout: Duration(defaultShutdownTimeout),
+	}
+}
+
+// LoadConfig reads the configuration file at path (skipped if empty) on top
+// of the defaults, applies the environment overrides and validates the result.
+func LoadConfig(path string, getenv func(string) string) (Config, error) {
+	cfg := defaultConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("could not read config: %w", err)
+		}
+
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("could not decode config: %w", err)
+		}
+	}
+
+	if err := applyEnv(&cfg, getenv); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+func applyEnv(cfg *Config, getenv func(string) string) error {
+	vars := map[string]*string{
+		"KAIMONO_STORAGE_BACKEND":     &cfg.Storage.Backend,
+		"KAIMONO_STORAGE_PATH":        &cfg.Storage.Path,
+		"KAIMONO_SESSION_STRATEGY":    &cfg.Session.Strategy,
+		"KAIMONO_SESSION_NAME":        &cfg.Session.Name,
+		"KAIMONO_SESSION_USER_HEADER": &cfg.Session.UserHeader,
+		"KAIMONO_SESSION_SIGNING_KEY": &cfg.Session.SigningKey,
+		"KAIMONO_ADMIN_POLICY":        &cfg.Admin.Policy,
+		"KAIMONO_ADMIN_TOKEN":         &cfg.Admin.Token,
+		"KAIMONO_ADMIN_POLICY_FILE":   &cfg.Admin.PolicyFile,
+		"KAIMONO_ADDR":                &cfg.Listen.Addr,
+		"KAIMONO_ADMIN_ADDR":          &cfg.Listen.AdminAddr,
+		"KAIMONO_BASE":                &cfg.Listen.Base,
+		"KAIMONO_ADMIN_BASE":          &cfg.Listen.AdminBase,
+		"KAIMONO_OPENAPI_PATH":        &cfg.Listen.OpenAPIPath,
+		"KAIMONO_GRAPHQL_PATH":        &cfg.Listen.GraphQLPath,
+		"KAIMONO_METRICS_PATH":        &cfg.Listen.MetricsPath,
+		"KAIMONO_TLS_CERT_FILE":       &cfg.TLS.CertFile,
+		"KAIMONO_TLS_KEY_FILE":        &cfg.TLS.KeyFile,
+	}
+
+	for name, field := range vars {
+		if v := getenv(name); v != "" {
+			*field = v
+		}
+	}
+
+	if v := getenv("KAIMONO_SHUTDOWN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid KAIMONO_SHUTDOWN_TIMEOUT: %w", err)
+		}
+
+		cfg.ShutdownTimeout = Duration(d)
+	}
+
+	return nil
+}
+
+var errInvalidConfig = errors.New("invalid config")
+
+func (cfg Config) Validate() error {
+	switch cfg.Storage.Backend {
+	case "memory":
+	case "file":
+		if cfg.Storage.Path == "" {
+			return fmt.Errorf("%w: storage path is required for the file backend", errInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown storage backend '%s'", errInvalidConfig, cfg.Storage.Backend)
+	}
+
+	switch cfg.Session.Strategy {
+	case "cookie", "header":
+		if cfg.Session.Name == "" {
+			return fmt.Errorf("%w: session name is required", errInvalidConfig)
+		}
+	case "bearer":
+	case "signed":
+		if cfg.Session.SigningKey == "" {
+			return fmt.Errorf("%w: a signing key is required for the signed strategy", errInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown session strategy '%s'", errInvalidConfig, cfg.Session.Strategy)
+	}
+
+	switch cfg.Admin.Policy {
+	case "deny-all", "allow-all":
+	case "token":
+		if cfg.Admin.Token == "" {
+			return fmt.Errorf("%w: admin token is required for the token policy", errInvalidConfig)
+		}
+	case "file":
+		if cfg.Admin.PolicyFile == "" {
+			return fmt.Errorf("%w: a policy file is required for the file policy", errInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown admin policy '%s'", errInvalidConfig, cfg.Admin.Policy)
+	}
+
+	if cfg.Listen.Addr == "" {
+		return fmt.Errorf("%w: listen address is required", errInvalidConfig)
+	}
+
+	if (cfg.TLS.CertFile == "") != (cfg.TLS.KeyFile == "") {
+		return fmt.Errorf("%w: both TLS cert and key files must be set", errInvalidConfig)
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+// Command kaimono runs kaimono as a standalone cart microservice.
+//
+// Usage:
+//
+//	kaimono serve [-config path]
+//	kaimono cart <get|create|update|delete|assign> [flags]
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+const usage = `usage: kaimono <command> [flags]
+
+commands:
+  serve    run the cart server
+  cart     manage carts through the admin routes
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "serve":
+		err = serve(args)
+	case "cart":
+		err = runCart(context.Background(), args, os.Stdin, os.Stdout)
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
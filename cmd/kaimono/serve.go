@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+const readHeaderTimeout = 10 * time.Second
+
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := flags.String("config", os.Getenv("KAIMONO_CONFIG"), "path to the JSON config file")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("could not parse flags: %w", err)
+	}
+
+	cfg, err := LoadConfig(*configPath, os.Getenv)
+	if err != nil {
+		return err
+	}
+
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, nil))
+
+	svc, err := newService(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	servers, err := newServers(cfg, svc)
+	if err != nil {
+		return err
+	}
+
+	return run(ctx, cfg, logger, servers)
+}
+
+func newService(cfg Config, logger *slog.Logger) (*kaimono.Service, error) {
+	db, err := newDB(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []kaimono.Option{}
+
+	if cfg.Listen.MetricsPath != "" {
+		metrics := kaimono.NewMetrics()
+		db = metrics.InstrumentDB(db)
+		opts = append(opts, kaimono.WithMetrics(metrics))
+	}
+
+	fetcher := newUserContextFetcher(cfg.Session)
+
+	authorizer, err := newAuthorizer(cfg.Admin, fetcher, db, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	svc, err := kaimono.NewService(db, fetcher, authorizer, logger, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create service: %w", err)
+	}
+
+	return svc, nil
+}
+
+// newServers returns one server for both routers, or two if the admin
+// routes have their own listen address.
+func newServers(cfg Config, svc *kaimono.Service) ([]*http.Server, error) {
+	standard := chi.NewRouter()
+	standard.Get("/healthz", healthz)
+	standard.Mount(cfg.Listen.Base, svc.Router("/"))
+
+	if cfg.Listen.OpenAPIPath != "" {
+		standard.Get(cfg.Listen.OpenAPIPath, svc.OpenAPIHandler(cfg.Listen.Base, cfg.Listen.AdminBase))
+	}
+
+	if cfg.Listen.GraphQLPath != "" {
+		handler, err := svc.GraphQLHandler()
+		if err != nil {
+			return nil, fmt.Errorf("could not create GraphQL handler: %w", err)
+		}
+
+		standard.Post(cfg.Listen.GraphQLPath, handler.ServeHTTP)
+	}
+
+	admin := standard
+	if cfg.Listen.AdminAddr != "" {
+		admin = chi.NewRouter()
+		admin.Get("/healthz", healthz)
+	}
+
+	admin.Mount(cfg.Listen.AdminBase, svc.AdminRouter("/"))
+
+	if metrics := svc.Metrics(); metrics != nil {
+		admin.Get(cfg.Listen.MetricsPath, metrics.ServeHTTP)
+	}
+
+	servers := []*http.Server{
+		{Addr: cfg.Listen.Addr, Handler: standard, ReadHeaderTimeout: readHeaderTimeout},
+	}
+
+	if cfg.Listen.AdminAddr != "" {
+		servers = append(servers, &http.Server{
+			Addr:              cfg.Listen.AdminAddr,
+			Handler:           admin,
+			ReadHeaderTimeout: readHeaderTimeout,
+		})
+	}
+
+	return servers, nil
+}
+
+// run starts the servers and shuts them down gracefully once ctx is done
+// or any of them fails.
+func run(ctx context.Context, cfg Config, logger *slog.Logger, servers []*http.Server) error {
+	errs := make(chan error, len(servers))
+
+	// long-lived requests (e.g: event streams) are cancelled on shutdown,
+	// otherwise the server would wait for them until the timeout.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	for _, srv := range servers {
+		srv.BaseContext = func(net.Listener) context.Context { return baseCtx }
+		srv.RegisterOnShutdown(cancelRequests)
+
+		go func() {
+			logger.Info("listening", "addr", srv.Addr, "tls", cfg.TLS.Enabled())
+
+			var err error
+			if cfg.TLS.Enabled() {
+				err = srv.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+			} else {
+				err = srv.ListenAndServe()
+			}
+
+			if errors.Is(err, http.ErrServerClosed) {
+				err = nil
+			}
+
+			errs <- err
+		}()
+	}
+
+	var runErr error
+
+	select {
+	case <-ctx.Done():
+	case runErr = <-errs:
+	}
+
+	logger.Info("shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			runErr = errors.Join(runErr, fmt.Errorf("could not shutdown %s: %w", srv.Addr, err))
+		}
+	}
+
+	return runErr
+}
+
+func healthz(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{
+		"storage": {"backend": "file", "path": "/tmp/carts.json"},
+		"session": {"strategy": "header", "name": "X-Session"},
+		"shutdown-timeout": "3s"
+	}`
+
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("could not write config: %v", err)
+	}
+
+	env := map[string]string{
+		"KAIMONO_ADMIN_POLICY": "token",
+		"KAIMONO_ADMIN_TOKEN":  "secret",
+		"KAIMONO_ADDR":         ":9000",
+	}
+
+	cfg, err := LoadConfig(path, func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatalf("could not load config: %v", err)
+	}
+
+	if cfg.Storage.Backend != "file" || cfg.Session.Name != "X-Session" {
+		t.Fatalf("file values not applied: %+v", cfg)
+	}
+
+	if cfg.Admin.Policy != "token" || cfg.Listen.Addr != ":9000" {
+		t.Fatalf("env values not applied: %+v", cfg)
+	}
+
+	if cfg.Listen.Base != "/cart" {
+		t.Fatalf("defaults not applied: %+v", cfg)
+	}
+
+	if time.Duration(cfg.ShutdownTimeout) != 3*time.Second {
+		t.Fatalf("got shutdown timeout %v, want 3s", time.Duration(cfg.ShutdownTimeout))
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	tests := []struct {
+		label string
+		env   map[string]string
+	}{
+		{"token policy without a token", map[string]string{"KAIMONO_ADMIN_POLICY": "token"}},
+		{"signed strategy without a key", map[string]string{"KAIMONO_SESSION_STRATEGY": "signed"}},
+		{"file policy without a file", map[string]string{"KAIMONO_ADMIN_POLICY": "file"}},
+	}
+
+	for _, c := range tests {
+		if _, err := LoadConfig("", func(k string) string { return c.env[k] }); err == nil {
+			t.Fatalf("expected an error for the %s", c.label)
+		}
+	}
+}
+
+func TestServerRoutes(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Session = SessionConfig{Strategy: "header", Name: "X-Session"}
+	cfg.Admin = AdminConfig{Policy: "token", Token: "secret"}
+
+	svc, err := newService(cfg, nil)
+	if err != nil {
+		t.Fatalf("could not create service: %v", err)
+	}
+
+	servers, err := newServers(cfg, svc)
+	if err != nil {
+		t.Fatalf("could not create servers: %v", err)
+	}
+
+	handler := servers[0].Handler
+
+	tests := []struct {
+		label    string
+		method   string
+		path     string
+		headers  map[string]string
+		wantCode int
+	}{
+		{"health check", http.MethodGet, "/healthz", nil, http.StatusNoContent},
+		{"openapi document", http.MethodGet, "/openapi.json", nil, http.StatusOK},
+		{"create cart", http.MethodPost, "/cart", map[string]string{"X-Session": "abc"}, http.StatusCreated},
+		{"get cart", http.MethodGet, "/cart", map[string]string{"X-Session": "abc"}, http.StatusOK},
+		{"missing session", http.MethodGet, "/cart", nil, http.StatusBadRequest},
+		{"admin without token", http.MethodPost, "/admin/cart", nil, http.StatusForbidden},
+		{"admin with token", http.MethodPost, "/admin/cart", map[string]string{"Authorization": "Bearer secret"}, http.StatusCreated},
+		{"metrics", http.MethodGet, "/metrics", nil, http.StatusOK},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.path, nil)
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != c.wantCode {
+				t.Fatalf("got code %d, want %d", w.Code, c.wantCode)
+			}
+		})
+	}
+}
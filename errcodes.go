@@ -0,0 +1,250 @@
+package kaimono
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrorCode is a stable, machine-readable identifier of an error, returned
+// with every error response.
+type ErrorCode string
+
+const (
+	CodeCartNotFound         ErrorCode = "CART_NOT_FOUND"
+	CodeSessionNotFound      ErrorCode = "SESSION_NOT_FOUND"
+	CodeInvalidCredentials   ErrorCode = "INVALID_CREDENTIALS"
+	CodeAlreadyExists        ErrorCode = "ALREADY_EXISTS"
+	CodeCartIDMismatch       ErrorCode = "CART_ID_MISMATCH"
+	CodeNotAuthorized        ErrorCode = "NOT_AUTHORIZED"
+	CodeValidationFailed     ErrorCode = "VALIDATION_FAILED"
+	CodeMalformedRequest     ErrorCode = "MALFORMED_REQUEST"
+	CodeItemNotFound         ErrorCode = "ITEM_NOT_FOUND"
+	CodeCurrencyMismatch     ErrorCode = "CURRENCY_MISMATCH"
+	CodeUserRequired         ErrorCode = "USER_REQUIRED"
+	CodeShareNotFound        ErrorCode = "SHARE_NOT_FOUND"
+	CodeShareExpired         ErrorCode = "SHARE_EXPIRED"
+	CodeJobNotFound          ErrorCode = "JOB_NOT_FOUND"
+	CodeRestoreNotFound      ErrorCode = "RESTORE_LINK_NOT_FOUND"
+	CodeRestoreExpired       ErrorCode = "RESTORE_LINK_EXPIRED"
+	CodeNotSupported         ErrorCode = "NOT_SUPPORTED"
+	CodeIdempotencyKeyReused ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyKeyInUse  ErrorCode = "IDEMPOTENCY_KEY_IN_USE"
+	CodeRateLimited          ErrorCode = "RATE_LIMITED"
+	CodeNotFound             ErrorCode = "NOT_FOUND"
+	CodeBadRequest           ErrorCode = "BAD_REQUEST"
+	CodeMethodNotAllowed     ErrorCode = "METHOD_NOT_ALLOWED"
+	CodeInternal             ErrorCode = "INTERNAL"
+)
+
+// errorCodes maps the sentinel errors to their codes, the first match wins.
+var errorCodes = []struct {
+	err  error
+	code ErrorCode
+}{
+	{ErrCartNotFound, CodeCartNotFound},
+	{ErrSessionNotFound, CodeSessionNotFound},
+	{ErrInvalidCredentials, CodeInvalidCredentials},
+	{ErrAlreadyExists, CodeAlreadyExists},
+	{ErrInvalidID, CodeCartIDMismatch},
+	{ErrReadOnly, CodeNotAuthorized},
+	{ErrAccessRevoked, CodeNotAuthorized},
+	{ErrItemNotFound, CodeItemNotFound},
+	{ErrCurrencyMismatch, CodeCurrencyMismatch},
+	{ErrUserRequired, CodeUserRequired},
+	{ErrShareNotFound, CodeShareNotFound},
+	{ErrShareExpired, CodeShareExpired},
+	{ErrJobNotFound, CodeJobNotFound},
+	{ErrRestoreNotFound, CodeRestoreNotFound},
+	{ErrRestoreExpired, CodeRestoreExpired},
+	{ErrNotSupported, CodeNotSupported},
+	{ErrAnalyticsDisabled, CodeNotSupported},
+	{ErrRecoveryDisabled, CodeNotSupported},
+	{ErrIdempotencyKeyReused, CodeIdempotencyKeyReused},
+	{ErrIdempotencyKeyInUse, CodeIdempotencyKeyInUse},
+	{ErrInvalidIdempotencyKey, CodeBadRequest},
+	{ErrRateLimited, CodeRateLimited},
+	{ErrTooManyCarts, CodeRateLimited},
+	{ErrInvalidCart, CodeValidationFailed},
+	{ErrInvalidQuantity, CodeValidationFailed},
+	{ErrInvalidKind, CodeValidationFailed},
+	{ErrInvalidQuery, CodeValidationFailed},
+	{ErrInvalidPermission, CodeValidationFailed},
+	{ErrInvalidJob, CodeValidationFailed},
+	{ErrUnknownJobType, CodeValidationFailed},
+	{ErrUnknownOp, CodeValidationFailed},
+	{io.EOF, CodeMalformedRequest},
+	{io.ErrUnexpectedEOF, CodeMalformedRequest},
+}
+
+// ErrorCodeOf returns the code of the error, or an empty code if it has
+// none.
+func ErrorCodeOf(err error) ErrorCode {
+	switch {
+	case errors.As(err, &NotAuthorizedError{}):
+		return CodeNotAuthorized
+	case errors.As(err, &ValidationError{}):
+		return CodeValidationFailed
+	case errors.As(err, new(*json.SyntaxError)), errors.As(err, new(*json.UnmarshalTypeError)):
+		return CodeMalformedRequest
+	}
+
+	for _, m := range errorCodes {
+		if errors.Is(err, m.err) {
+			return m.code
+		}
+	}
+
+	return ""
+}
+
+// statusErrorCode is the code of the errors without one, by status code.
+func statusErrorCode(status int) ErrorCode {
+	switch {
+	case status == http.StatusNotFound:
+		return CodeNotFound
+	case status == http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case status == http.StatusForbidden:
+		return CodeNotAuthorized
+	case status == http.StatusTooManyRequests:
+		return CodeRateLimited
+	case status >= http.StatusInternalServerError:
+		return CodeInternal
+	default:
+		return CodeBadRequest
+	}
+}
+
+// FieldError describes why a field of the request is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists the invalid fields of a request. It unwraps to
+// Err, e.g: ErrInvalidQuery, if set.
+type ValidationError struct {
+	Err    error
+	Fields []FieldError
+}
+
+func (e ValidationError) Error() string {
+	msg := "validation failed"
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+
+	fields := make([]string, len(e.Fields))
+	for k, f := range e.Fields {
+		fields[k] = fmt.Sprintf("'%s' %s", f.Field, f.Message)
+	}
+
+	return msg + ": " + strings.Join(fields, "; ")
+}
+
+func (e ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// fieldErrors returns the invalid fields of a ValidationError.
+func fieldErrors(err error) []FieldError {
+	validationErr := ValidationError{}
+	if errors.As(err, &validationErr) {
+		return validationErr.Fields
+	}
+
+	return nil
+}
+
+// ProblemContentType is the content type of the Problem responses.
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix prefixes the error code to build the problem's type.
+const problemTypePrefix = "urn:kaimono:error:"
+
+// Problem is an RFC 7807 problem details response, extended with the
+// error's code and, for validation errors, the invalid fields.
+type Problem struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+	Code   ErrorCode    `json:"code"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// WithProblemDetails returns every error as a Problem instead of in the
+// {data, error} envelope. Otherwise, only requests accepting
+// ProblemContentType get a Problem.
+func WithProblemDetails() Option {
+	return func(svc *Service) {
+		svc.problemDetails = true
+	}
+}
+
+// negotiateErrors marks the responses to requests accepting
+// ProblemContentType, so their errors are written as a Problem.
+func (svc *Service) negotiateErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.Contains(strings.Join(req.Header.Values("Accept"), ","), ProblemContentType) {
+			w = problemWriter{w}
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// problemWriter marks a response whose errors are written as a Problem.
+type problemWriter struct {
+	http.ResponseWriter
+}
+
+func (pw problemWriter) Unwrap() http.ResponseWriter {
+	return pw.ResponseWriter
+}
+
+// acceptsProblem reports whether w, or a writer it wraps, is a
+// problemWriter.
+func acceptsProblem(w http.ResponseWriter) bool {
+	for {
+		switch rw := w.(type) {
+		case problemWriter:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// writeError writes the error response, in the {data, error} envelope as
+// an ErrorResponse, or as a Problem if the Service was created
+// WithProblemDetails or the request accepts it.
+func (svc *Service) writeError(w http.ResponseWriter, status int, err error) error {
+	code := ErrorCodeOf(err)
+	if code == "" {
+		code = statusErrorCode(status)
+	}
+
+	if !svc.problemDetails && !acceptsProblem(w) {
+		resp := ErrorResponse{Data: nil, Error: err.Error(), Code: code, Details: fieldErrors(err)}
+
+		return writeJSON(w, status, "application/json", resp)
+	}
+
+	problem := Problem{
+		Type:   problemTypePrefix + string(code),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: err.Error(),
+		Code:   code,
+		Errors: fieldErrors(err),
+	}
+
+	return writeJSON(w, status, ProblemContentType, problem)
+}
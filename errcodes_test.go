@@ -0,0 +1,126 @@
+package kaimono
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorCode
+	}{
+		{ErrCartNotFound, CodeCartNotFound},
+		{fmt.Errorf("lookup failed: %w", ErrSessionNotFound), CodeSessionNotFound},
+		{ErrInvalidID, CodeCartIDMismatch},
+		{NotAuthorizedError{ID: "cart"}, CodeNotAuthorized},
+		{ErrReadOnly, CodeNotAuthorized},
+		{ValidationError{Err: ErrInvalidQuery}, CodeValidationFailed},
+		{ErrInvalidQuantity, CodeValidationFailed},
+		{fmt.Errorf("could not decode request: %w", io.ErrUnexpectedEOF), CodeMalformedRequest},
+		{fmt.Errorf("could not decode request: %w", &json.SyntaxError{}), CodeMalformedRequest},
+		{ErrAnalyticsDisabled, CodeNotSupported},
+		{io.ErrClosedPipe, ""},
+	}
+
+	for _, c := range tests {
+		if got := ErrorCodeOf(c.err); got != c.want {
+			t.Fatalf("ErrorCodeOf(%v) = %q, want %q", c.err, got, c.want)
+		}
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	tests := []struct {
+		label       string
+		opts        []Option
+		accept      string
+		contentType string
+	}{
+		{"error envelope", nil, "", "application/json"},
+		{"problem details", []Option{WithProblemDetails()}, "", ProblemContentType},
+		{"accepted problem details", nil, ProblemContentType, ProblemContentType},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			mock := newMockBackend()
+
+			svc, err := NewService(mock, mock, mock, nil, c.opts...)
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+
+			srv := httptest.NewServer(svc.AdminRouter("/admin"))
+			t.Cleanup(srv.Close)
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/admin/?limit=0&unknown=1", nil)
+			if err != nil {
+				t.Fatalf("could not make request: %v", err)
+			}
+
+			setTestCookie(req, mock.sessions[1])
+
+			if c.accept != "" {
+				req.Header.Set("Accept", c.accept)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("could not do request: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("got code %d, want %d", resp.StatusCode, http.StatusBadRequest)
+			}
+
+			if got := resp.Header.Get("Content-Type"); got != c.contentType {
+				t.Fatalf("got content type %q, want %q", got, c.contentType)
+			}
+
+			data, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("could not read response: %v", err)
+			}
+
+			problem, envelope := Problem{}, ErrorResponse{}
+			if err := json.Unmarshal(data, &problem); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+
+			if err := json.Unmarshal(data, &envelope); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+
+			fields, code := problem.Errors, problem.Code
+
+			if c.contentType != ProblemContentType {
+				fields, code = envelope.Details, envelope.Code
+
+				if envelope.Error == "" {
+					t.Fatalf("expected the error message to be set")
+				}
+			} else if problem.Status != http.StatusBadRequest || problem.Type != problemTypePrefix+string(CodeValidationFailed) {
+				t.Fatalf("unexpected problem: %+v", problem)
+			}
+
+			if code != CodeValidationFailed {
+				t.Fatalf("got code %q, want %q", code, CodeValidationFailed)
+			}
+
+			want := []FieldError{
+				{Field: "limit", Message: "must be between 1 and 500"},
+				{Field: "unknown", Message: "is not a known parameter"},
+			}
+
+			if fmt.Sprint(fields) != fmt.Sprint(want) {
+				t.Fatalf("got fields %+v, want %+v", fields, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package kaimono
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+type CartEventType string
+
+const (
+	CartCreated CartEventType = "created"
+	CartUpdated CartEventType = "updated"
+	CartDeleted CartEventType = "deleted"
+)
+
+// CartEvent is published after every change made to a Cart through the
+// Service. Version is incremented on every change to the Cart.
+type CartEvent struct {
+	Type    CartEventType `json:"type"`
+	CartID  string        `json:"cart-id"`
+	Version uint64        `json:"version"`
+	Cart    Cart          `json:"cart"`
+	Time    time.Time     `json:"time"`
+}
+
+// subscriberBuffer is the number of events buffered per subscriber, once
+// full the oldest event is dropped.
+const subscriberBuffer = 16
+
+// eventBroker fans out CartEvents to subscribers, either of a single Cart
+// or of all carts. Versions are kept in memory, so they start over when the
+// process restarts, under a new epoch.
+type eventBroker struct {
+	epoch string
+
+	mu       sync.Mutex
+	versions map[string]uint64
+	subs     map[string]map[chan CartEvent]struct{}
+
+	// observers are called synchronously, in order, for every event.
+	observers []func(CartEvent)
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		epoch:    strconv.FormatInt(time.Now().UnixNano(), 36),
+		versions: make(map[string]uint64),
+		subs:     make(map[string]map[chan CartEvent]struct{}),
+	}
+}
+
+// version returns the current version of the Cart.
+func (b *eventBroker) version(cartID string) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.versions[cartID]
+}
+
+// eventID identifies the version within the broker's epoch, so the IDs
+// seen before a restart don't match the new versions.
+func (b *eventBroker) eventID(version uint64) string {
+	return b.epoch + "-" + strconv.FormatUint(version, 10)
+}
+
+func (b *eventBroker) publish(typ CartEventType, cart Cart) CartEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.versions[cart.ID]++
+
+	event := CartEvent{
+		Type:    typ,
+		CartID:  cart.ID,
+		Version: b.versions[cart.ID],
+		Cart:    cart,
+		Time:    time.Now(),
+	}
+
+	if typ == CartDeleted {
+		delete(b.versions, cart.ID)
+	}
+
+	for _, observe := range b.observers {
+		observe(event)
+	}
+
+	for _, key := range []string{cart.ID, ""} {
+		for ch := range b.subs[key] {
+			send(ch, event)
+		}
+	}
+
+	return event
+}
+
+// send never blocks, dropping the oldest event if the subscriber is behind.
+func send(ch chan CartEvent, event CartEvent) {
+	for {
+		select {
+		case ch <- event:
+			return
+		default:
+		}
+
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+// observe registers fn to be called for every event. Unlike subscribers,
+// observers never miss an event, so they must not block.
+func (b *eventBroker) observe(fn func(CartEvent)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.observers = append(b.observers, fn)
+}
+
+func (b *eventBroker) subscribe(cartID string) (<-chan CartEvent, func()) {
+	ch := make(chan CartEvent, subscriberBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subs[cartID] == nil {
+		b.subs[cartID] = make(map[chan CartEvent]struct{})
+	}
+
+	b.subs[cartID][ch] = struct{}{}
+
+	var once sync.Once
+
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+
+			delete(b.subs[cartID], ch)
+
+			if len(b.subs[cartID]) == 0 {
+				delete(b.subs, cartID)
+			}
+		})
+	}
+}
+
+// Subscribe returns a channel receiving the events of the Cart, or of all
+// carts if cartID is empty. The returned function must be called to
+// unsubscribe. Slow subscribers miss the oldest events.
+func (svc *Service) Subscribe(cartID string) (<-chan CartEvent, func()) {
+	return svc.events.subscribe(cartID)
+}
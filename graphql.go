@@ -0,0 +1,317 @@
+package kaimono
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+)
+
+// GraphQLHandler returns a handler serving the GraphQL API over POST. It
+// exposes the same operations as Router, scoped to the request's session,
+// plus a cartByID query guarded by the Authorizer:
+//
+//	type Query {
+//	  cart: Cart
+//	  cartByID(id: ID!): Cart
+//	}
+//
+//	type Mutation {
+//	  addItem(id: ID!, quantity: Int!, price: PriceInput!): Cart
+//	  updateQuantity(id: ID!, quantity: Int!): Cart
+//	  removeItem(id: ID!): Cart
+//	  applyDiscount(discount: DiscountInput!): Cart
+//	}
+//
+// Besides the Cart fields, carts expose their computed totals and items
+// their subtotal and total. The handler goes through the same middlewares
+// as Router: requests are logged, rate limited and, with an Idempotency-Key
+// header, replayed, and carts are kept in cookies WithStatelessCarts.
+// Requests with invalid credentials are rejected with a 401.
+func (svc *Service) GraphQLHandler() (http.Handler, error) {
+	schema, err := svc.graphqlSchema()
+	if err != nil {
+		return nil, fmt.Errorf("could not build schema: %w", err)
+	}
+
+	handler := svc.limitRequests(svc.idempotent(svc.graphqlHandler(schema)))
+
+	if svc.stateless != nil {
+		handler = svc.withStatelessCart(handler)
+	}
+
+	if svc.metrics != nil {
+		handler = svc.metrics.middleware(false)(handler)
+	}
+
+	if svc.tracer != nil {
+		handler = svc.traceRequests(handler)
+	}
+
+	return svc.logRequests(svc.negotiateErrors(handler)), nil
+}
+
+func (svc *Service) graphqlHandler(schema graphql.Schema) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			svc.json(svc.writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed")))
+
+			return
+		}
+
+		// as on the standard router, bad credentials aren't treated as a
+		// missing session.
+		if _, err := svc.usrCtxFetcher.GetUserContext(req); errors.Is(err, ErrInvalidCredentials) {
+			svc.json(svc.writeError(w, http.StatusUnauthorized, err))
+			return
+		}
+
+		payload := graphqlRequest{}
+		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+			svc.json(svc.writeError(w, http.StatusBadRequest, fmt.Errorf("could not decode request: %w", err)))
+			return
+		}
+
+		result := graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  payload.Query,
+			VariableValues: payload.Variables,
+			OperationName:  payload.OperationName,
+			Context:        context.WithValue(req.Context(), graphqlRequestKey{}, req),
+		})
+
+		svc.json(writeResponse(w, http.StatusOK, result))
+	})
+}
+
+type graphqlRequest struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables"`
+	OperationName string         `json:"operationName"` //nolint:tagliatelle // defined by GraphQL over HTTP.
+}
+
+type graphqlRequestKey struct{}
+
+// graphqlError exposes the error's code in its extensions.
+type graphqlError struct {
+	err  error
+	code ErrorCode
+}
+
+func (e graphqlError) Error() string {
+	return e.err.Error()
+}
+
+func (e graphqlError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+func newGraphQLError(err error) error {
+	code := ErrorCodeOf(err)
+	if code == "" {
+		code = CodeInternal
+	}
+
+	return graphqlError{err: err, code: code}
+}
+
+func graphqlHTTPRequest(ctx context.Context) (*http.Request, error) {
+	req, ok := ctx.Value(graphqlRequestKey{}).(*http.Request)
+	if !ok {
+		return nil, errors.New("no request in context")
+	}
+
+	return req, nil
+}
+
+// sessionCart looks up the Cart of the request's session, if the session
+// has the needed permission on it.
+func (svc *Service) sessionCart(ctx context.Context, needed SharePermission) (Cart, error) {
+	req, err := graphqlHTTPRequest(ctx)
+	if err != nil {
+		return Cart{}, err
+	}
+
+	usrCtx, err := svc.usrCtxFetcher.GetUserContext(req)
+	if err != nil {
+		return Cart{}, err
+	}
+
+	cart, err := svc.store(ctx).LookupCartForSession(usrCtx.SessionToken)
+	if err != nil {
+		return cart, err
+	}
+
+	return cart, svc.checkAccess(usrCtx.SessionToken, cart.ID, needed)
+}
+
+// mutateSessionCart applies fn to the Cart of the request's session and
+// persists the result.
+func (svc *Service) mutateSessionCart(ctx context.Context, fn func(cart *Cart) error) (Cart, error) {
+	found, err := svc.sessionCart(ctx, EditPermission)
+	if err != nil {
+		return Cart{}, newGraphQLError(err)
+	}
+
+	cart := found.Clone()
+	if err := fn(&cart); err != nil {
+		return Cart{}, newGraphQLError(err)
+	}
+
+	if err := svc.updateCart(ctx, &cart); err != nil {
+		return Cart{}, newGraphQLError(err)
+	}
+
+	return cart, nil
+}
+
+func (svc *Service) graphqlSchema() (graphql.Schema, error) {
+	types := newGraphQLTypes()
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"cart": &graphql.Field{
+				Type:        types.cart,
+				Description: "The Cart associated to the current session.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					cart, err := svc.sessionCart(p.Context, ViewPermission)
+					if err != nil {
+						return nil, newGraphQLError(err)
+					}
+
+					return cart, nil
+				},
+			},
+			"cartByID": &graphql.Field{
+				Type:        types.cart,
+				Description: "The Cart matching the ID, requires authorization.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: svc.resolveCartByID,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name:   "Mutation",
+		Fields: svc.graphqlMutations(types),
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func (svc *Service) resolveCartByID(p graphql.ResolveParams) (any, error) {
+	req, err := graphqlHTTPRequest(p.Context)
+	if err != nil {
+		return nil, err
+	}
+
+	cartID, _ := p.Args["id"].(string)
+	op := Operation{Type: ReadOp, Resource: "cart"}
+
+	if err := svc.authorizer.AuthorizeUser(req, op, cartID); err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	cart, err := svc.store(p.Context).LookupCart(cartID)
+	if err != nil {
+		return nil, newGraphQLError(err)
+	}
+
+	return cart, nil
+}
+
+func (svc *Service) graphqlMutations(types graphqlTypes) graphql.Fields {
+	nonNull := graphql.NewNonNull
+
+	return graphql.Fields{
+		"addItem": &graphql.Field{
+			Type:        types.cart,
+			Description: "Add an item to the session's Cart, increasing its quantity if already present.",
+			Args: graphql.FieldConfigArgument{
+				"id":       &graphql.ArgumentConfig{Type: nonNull(graphql.ID)},
+				"quantity": &graphql.ArgumentConfig{Type: nonNull(graphql.Int)},
+				"price":    &graphql.ArgumentConfig{Type: nonNull(types.priceInput)},
+			},
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				item := CartItem{Discounts: []Discount{}}
+				if err := decodeArgs(p.Args, &item); err != nil {
+					return nil, err
+				}
+
+				return svc.mutateSessionCart(p.Context, func(cart *Cart) error {
+					return cart.AddItem(item)
+				})
+			},
+		},
+		"updateQuantity": &graphql.Field{
+			Type:        types.cart,
+			Description: "Set the quantity of an item in the session's Cart, 0 removes it.",
+			Args: graphql.FieldConfigArgument{
+				"id":       &graphql.ArgumentConfig{Type: nonNull(graphql.ID)},
+				"quantity": &graphql.ArgumentConfig{Type: nonNull(graphql.Int)},
+			},
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				itemID, _ := p.Args["id"].(string)
+				quantity, _ := p.Args["quantity"].(int)
+
+				return svc.mutateSessionCart(p.Context, func(cart *Cart) error {
+					return cart.SetQuantity(itemID, quantity)
+				})
+			},
+		},
+		"removeItem": &graphql.Field{
+			Type:        types.cart,
+			Description: "Remove an item from the session's Cart.",
+			Args: graphql.FieldConfigArgument{
+				"id": &graphql.ArgumentConfig{Type: nonNull(graphql.ID)},
+			},
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				itemID, _ := p.Args["id"].(string)
+
+				return svc.mutateSessionCart(p.Context, func(cart *Cart) error {
+					return cart.RemoveItem(itemID)
+				})
+			},
+		},
+		"applyDiscount": &graphql.Field{
+			Type:        types.cart,
+			Description: "Apply a discount to the session's Cart, replacing any discount with the same ID.",
+			Args: graphql.FieldConfigArgument{
+				"discount": &graphql.ArgumentConfig{Type: nonNull(types.discountInput)},
+			},
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				discount := Discount{}
+				if err := decodeArgs(p.Args["discount"], &discount); err != nil {
+					return nil, err
+				}
+
+				return svc.mutateSessionCart(p.Context, func(cart *Cart) error {
+					cart.ApplyDiscount(discount)
+					return nil
+				})
+			},
+		},
+	}
+}
+
+// decodeArgs converts the resolved arguments into v, going through JSON
+// so the field names follow the json tags.
+func decodeArgs(args any, v any) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("could not encode arguments: %w", err)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("could not decode arguments: %w", err)
+	}
+
+	return nil
+}
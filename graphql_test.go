@@ -0,0 +1,207 @@
+package kaimono
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type graphqlTestResponse struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func doGraphQL(t *testing.T, handler http.Handler, sessionToken, query string) graphqlTestResponse {
+	t.Helper()
+
+	body, err := json.Marshal(graphqlRequest{Query: query})
+	if err != nil {
+		t.Fatalf("could not encode request: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	if sessionToken != "" {
+		setTestCookie(req, sessionToken)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", w.Code, http.StatusOK)
+	}
+
+	resp := graphqlTestResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+
+	return resp
+}
+
+func TestGraphQLSessionCart(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	handler, err := svc.GraphQLHandler()
+	if err != nil {
+		t.Fatalf("could not create handler: %v", err)
+	}
+
+	session := mock.sessions[0]
+	if _, err := mock.CreateCartForSession(session); err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	resp := doGraphQL(t, handler, session, `mutation {
+		addItem(id: "apple", quantity: 2, price: {currency: "EUR", value: 5}) { id }
+	}`)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("unexpected errors: %+v", resp.Errors)
+	}
+
+	resp = doGraphQL(t, handler, session, `mutation {
+		applyDiscount(discount: {id: "promo", type: PERCENTAGE, value: 10}) {
+			items { id subtotal }
+			totals { currency total }
+		}
+	}`)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("unexpected errors: %+v", resp.Errors)
+	}
+
+	want := `{"items":[{"id":"apple","subtotal":10}],"totals":{"currency":"EUR","total":9}}`
+	if got := string(resp.Data["applyDiscount"]); got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	resp = doGraphQL(t, handler, session, `mutation { removeItem(id: "pear") { id } }`)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != "ITEM_NOT_FOUND" {
+		t.Fatalf("expected an ITEM_NOT_FOUND error, got %+v", resp.Errors)
+	}
+
+	resp = doGraphQL(t, handler, mock.sessions[1], `{ cart { id } }`)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != "CART_NOT_FOUND" {
+		t.Fatalf("expected a CART_NOT_FOUND error, got %+v", resp.Errors)
+	}
+}
+
+func TestGraphQLCartByID(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	handler, err := svc.GraphQLHandler()
+	if err != nil {
+		t.Fatalf("could not create handler: %v", err)
+	}
+
+	cart, err := mock.CreateCart()
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	query := `{ cartByID(id: "` + cart.ID + `") { id } }`
+
+	resp := doGraphQL(t, handler, mock.sessions[0], query)
+	if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != "NOT_AUTHORIZED" {
+		t.Fatalf("expected a NOT_AUTHORIZED error, got %+v", resp.Errors)
+	}
+
+	resp = doGraphQL(t, handler, mock.sessions[1], query)
+	if len(resp.Errors) > 0 {
+		t.Fatalf("unexpected errors: %+v", resp.Errors)
+	}
+
+	if got, want := string(resp.Data["cartByID"]), `{"id":"`+cart.ID+`"}`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestGraphQLIdempotentAddItem(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	handler, err := svc.GraphQLHandler()
+	if err != nil {
+		t.Fatalf("could not create handler: %v", err)
+	}
+
+	session := mock.sessions[0]
+
+	cart, err := mock.CreateCartForSession(session)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	body, err := json.Marshal(graphqlRequest{Query: `mutation {
+		addItem(id: "apple", quantity: 1, price: {currency: "EUR", value: 5}) { id }
+	}`})
+	if err != nil {
+		t.Fatalf("could not encode request: %v", err)
+	}
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+		setTestCookie(req, session)
+		req.Header.Set(IdempotencyKeyHeader, "add-apple")
+
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	found, err := mock.LookupCart(cart.ID)
+	if item, _ := found.Item("apple"); err != nil || item.Quantity != 1 {
+		t.Fatalf("expected the item to be added once, got %+v (%v)", found.Items, err)
+	}
+}
+
+// expiredFetcher rejects every request's credentials.
+type expiredFetcher struct{}
+
+func (expiredFetcher) GetUserContext(*http.Request) (UserContext, error) {
+	return UserContext{}, fmt.Errorf("%w: token expired", ErrInvalidCredentials)
+}
+
+func TestGraphQLInvalidCredentials(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, expiredFetcher{}, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	handler, err := svc.GraphQLHandler()
+	if err != nil {
+		t.Fatalf("could not create handler: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "{ cart { id } }"}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	errResp := ErrorResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&errResp); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+
+	if w.Code != http.StatusUnauthorized || errResp.Code != CodeInvalidCredentials {
+		t.Fatalf("got code %d and %+v, want %d", w.Code, errResp, http.StatusUnauthorized)
+	}
+}
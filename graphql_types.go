@@ -0,0 +1,117 @@
+package kaimono
+
+import (
+	"github.com/graphql-go/graphql"
+)
+
+type graphqlTypes struct {
+	cart          *graphql.Object
+	priceInput    *graphql.InputObject
+	discountInput *graphql.InputObject
+}
+
+func newGraphQLTypes() graphqlTypes {
+	nonNull := graphql.NewNonNull
+
+	discountType := graphql.NewEnum(graphql.EnumConfig{
+		Name: "DiscountType",
+		Values: graphql.EnumValueConfigMap{
+			"PERCENTAGE":   &graphql.EnumValueConfig{Value: PercentageDiscount},
+			"FIXED_AMOUNT": &graphql.EnumValueConfig{Value: FixedAmountDiscount},
+		},
+	})
+
+	price := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Price",
+		Fields: graphql.Fields{
+			"currency": &graphql.Field{Type: nonNull(graphql.String)},
+			"value":    &graphql.Field{Type: nonNull(graphql.Float)},
+		},
+	})
+
+	discount := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Discount",
+		Fields: graphql.Fields{
+			"id":    &graphql.Field{Type: nonNull(graphql.ID)},
+			"type":  &graphql.Field{Type: nonNull(discountType)},
+			"value": &graphql.Field{Type: nonNull(graphql.Float)},
+		},
+	})
+
+	item := graphql.NewObject(graphql.ObjectConfig{
+		Name: "CartItem",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: nonNull(graphql.ID)},
+			"quantity":  &graphql.Field{Type: nonNull(graphql.Int)},
+			"price":     &graphql.Field{Type: nonNull(price)},
+			"discounts": &graphql.Field{Type: nonNull(graphql.NewList(nonNull(discount)))},
+			"subtotal": &graphql.Field{
+				Type:        nonNull(graphql.Float),
+				Description: "Price times quantity, before discounts.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					item, _ := p.Source.(CartItem)
+					return item.Subtotal(), nil
+				},
+			},
+			"total": &graphql.Field{
+				Type:        nonNull(graphql.Float),
+				Description: "Subtotal after the item's discounts.",
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					item, _ := p.Source.(CartItem)
+					return item.Total(), nil
+				},
+			},
+		},
+	})
+
+	totals := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Totals",
+		Fields: graphql.Fields{
+			"currency": &graphql.Field{Type: nonNull(graphql.String)},
+			"subtotal": &graphql.Field{Type: nonNull(graphql.Float)},
+			"discount": &graphql.Field{Type: nonNull(graphql.Float)},
+			"total":    &graphql.Field{Type: nonNull(graphql.Float)},
+		},
+	})
+
+	cart := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Cart",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: nonNull(graphql.ID)},
+			"items":     &graphql.Field{Type: nonNull(graphql.NewList(nonNull(item)))},
+			"discounts": &graphql.Field{Type: nonNull(graphql.NewList(nonNull(discount)))},
+			"totals": &graphql.Field{
+				Type: nonNull(totals),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					cart, _ := p.Source.(Cart)
+
+					totals, err := cart.Totals()
+					if err != nil {
+						return nil, newGraphQLError(err)
+					}
+
+					return totals, nil
+				},
+			},
+		},
+	})
+
+	return graphqlTypes{
+		cart: cart,
+		priceInput: graphql.NewInputObject(graphql.InputObjectConfig{
+			Name: "PriceInput",
+			Fields: graphql.InputObjectConfigFieldMap{
+				"currency": &graphql.InputObjectFieldConfig{Type: nonNull(graphql.String)},
+				"value":    &graphql.InputObjectFieldConfig{Type: nonNull(graphql.Float)},
+			},
+		}),
+		discountInput: graphql.NewInputObject(graphql.InputObjectConfig{
+			Name: "DiscountInput",
+			Fields: graphql.InputObjectConfigFieldMap{
+				"id":    &graphql.InputObjectFieldConfig{Type: nonNull(graphql.ID)},
+				"type":  &graphql.InputObjectFieldConfig{Type: nonNull(discountType)},
+				"value": &graphql.InputObjectFieldConfig{Type: nonNull(graphql.Float)},
+			},
+		}),
+	}
+}
@@ -0,0 +1,97 @@
+package grpcserver
+
+import (
+	"github.com/aalbacetef/kaimono"
+	"github.com/aalbacetef/kaimono/kaimonopb"
+)
+
+func toProtoCart(cart kaimono.Cart) *kaimonopb.Cart {
+	items := make([]*kaimonopb.CartItem, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		items = append(items, &kaimonopb.CartItem{
+			Id:        item.ID,
+			Quantity:  int64(item.Quantity),
+			Discounts: toProtoDiscounts(item.Discounts),
+			Price:     &kaimonopb.Price{Currency: item.Price.Currency, Value: item.Price.Value},
+		})
+	}
+
+	return &kaimonopb.Cart{
+		Id:        cart.ID,
+		Items:     items,
+		Discounts: toProtoDiscounts(cart.Discounts),
+	}
+}
+
+func toProtoDiscounts(discounts []kaimono.Discount) []*kaimonopb.Discount {
+	out := make([]*kaimonopb.Discount, 0, len(discounts))
+	for _, d := range discounts {
+		out = append(out, &kaimonopb.Discount{Id: d.ID, Type: toProtoDiscountType(d.Type), Value: d.Value})
+	}
+
+	return out
+}
+
+func toProtoDiscountType(t kaimono.DiscountType) kaimonopb.DiscountType {
+	switch t {
+	case kaimono.PercentageDiscount:
+		return kaimonopb.DiscountType_DISCOUNT_TYPE_PERCENTAGE
+	case kaimono.FixedAmountDiscount:
+		return kaimonopb.DiscountType_DISCOUNT_TYPE_FIXED_AMOUNT
+	default:
+		return kaimonopb.DiscountType_DISCOUNT_TYPE_UNSPECIFIED
+	}
+}
+
+func fromProtoCart(cart *kaimonopb.Cart) kaimono.Cart {
+	items := make([]kaimono.CartItem, 0, len(cart.GetItems()))
+	for _, item := range cart.GetItems() {
+		items = append(items, kaimono.CartItem{
+			ID:        item.GetId(),
+			Quantity:  int(item.GetQuantity()),
+			Discounts: fromProtoDiscounts(item.GetDiscounts()),
+			Price: kaimono.Price{
+				Currency: item.GetPrice().GetCurrency(),
+				Value:    item.GetPrice().GetValue(),
+			},
+		})
+	}
+
+	return kaimono.Cart{
+		ID:        cart.GetId(),
+		Items:     items,
+		Discounts: fromProtoDiscounts(cart.GetDiscounts()),
+	}
+}
+
+// withStoredFields copies the fields the protobuf Cart doesn't carry from
+// the stored Cart, so updates don't drop them.
+func withStoredFields(cart, stored kaimono.Cart) kaimono.Cart {
+	cart.Name = stored.Name
+	cart.Kind = stored.Kind
+	cart.UserID = stored.UserID
+	cart.Saved = stored.Saved
+	cart.CreatedAt = stored.CreatedAt
+
+	return cart
+}
+
+func fromProtoDiscounts(discounts []*kaimonopb.Discount) []kaimono.Discount {
+	out := make([]kaimono.Discount, 0, len(discounts))
+	for _, d := range discounts {
+		out = append(out, kaimono.Discount{ID: d.GetId(), Type: fromProtoDiscountType(d.GetType()), Value: d.GetValue()})
+	}
+
+	return out
+}
+
+func fromProtoDiscountType(t kaimonopb.DiscountType) kaimono.DiscountType {
+	switch t {
+	case kaimonopb.DiscountType_DISCOUNT_TYPE_PERCENTAGE:
+		return kaimono.PercentageDiscount
+	case kaimonopb.DiscountType_DISCOUNT_TYPE_FIXED_AMOUNT:
+		return kaimono.FixedAmountDiscount
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,234 @@
+// Package grpcserver exposes kaimono's cart operations over gRPC, through
+// the mutation API of a kaimono.Service so changes are validated and
+// published the same way as over HTTP.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/aalbacetef/kaimono"
+	"github.com/aalbacetef/kaimono/kaimonopb"
+)
+
+// Server implements kaimonopb.CartServiceServer and
+// kaimonopb.CartAdminServiceServer.
+type Server struct {
+	svc           *kaimono.Service
+	usrCtxFetcher UserContextFetcher
+	authorizer    kaimono.Authorizer
+	logger        *slog.Logger
+}
+
+func New(
+	svc *kaimono.Service, usrCtxFetcher UserContextFetcher, authorizer kaimono.Authorizer, logger *slog.Logger,
+) *Server {
+	if logger == nil {
+		logger = slog.New(slog.NewJSONHandler(io.Discard, nil))
+	}
+
+	return &Server{
+		svc:           svc,
+		usrCtxFetcher: usrCtxFetcher,
+		authorizer:    authorizer,
+		logger:        logger,
+	}
+}
+
+// Register registers both the standard and admin services.
+func (srv *Server) Register(registrar grpc.ServiceRegistrar) {
+	kaimonopb.RegisterCartServiceServer(registrar, cartServer{srv: srv})
+	kaimonopb.RegisterCartAdminServiceServer(registrar, adminServer{srv: srv})
+}
+
+// toStatus maps kaimono's errors to the gRPC code matching the HTTP status
+// code returned by kaimono.Service.
+func (srv *Server) toStatus(err error) error {
+	switch {
+	case errors.Is(err, kaimono.ErrCartNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, kaimono.ErrSessionNotFound), errors.As(err, &kaimono.ValidationError{}):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, kaimono.ErrInvalidCredentials):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, kaimono.ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, kaimono.ErrInvalidID), errors.As(err, &kaimono.NotAuthorizedError{}):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, kaimono.ErrReadOnly), errors.Is(err, kaimono.ErrAccessRevoked):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, kaimono.ErrShareExpired):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		srv.logger.Error("unexpected error", "error", err)
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+type cartServer struct {
+	kaimonopb.UnimplementedCartServiceServer
+
+	srv *Server
+}
+
+func (s cartServer) GetCart(ctx context.Context, _ *kaimonopb.GetCartRequest) (*kaimonopb.GetCartResponse, error) {
+	usrCtx, err := s.srv.usrCtxFetcher.GetUserContext(ctx)
+	if err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	cart, err := s.srv.svc.SessionCart(ctx, usrCtx)
+	if err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	return &kaimonopb.GetCartResponse{Cart: toProtoCart(cart)}, nil
+}
+
+func (s cartServer) CreateCart(ctx context.Context, _ *kaimonopb.CreateCartRequest) (*kaimonopb.CreateCartResponse, error) {
+	usrCtx, err := s.srv.usrCtxFetcher.GetUserContext(ctx)
+	if err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	cart, err := s.srv.svc.CreateSessionCart(ctx, usrCtx)
+	if err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	return &kaimonopb.CreateCartResponse{Cart: toProtoCart(cart)}, nil
+}
+
+func (s cartServer) UpdateCart(ctx context.Context, req *kaimonopb.UpdateCartRequest) (*kaimonopb.UpdateCartResponse, error) {
+	usrCtx, err := s.srv.usrCtxFetcher.GetUserContext(ctx)
+	if err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	foundCart, err := s.srv.svc.SessionCart(ctx, usrCtx)
+	if err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	cart, err := s.srv.svc.UpdateSessionCart(ctx, usrCtx, withStoredFields(fromProtoCart(req.GetCart()), foundCart))
+	if err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	return &kaimonopb.UpdateCartResponse{Cart: toProtoCart(cart)}, nil
+}
+
+func (s cartServer) DeleteCart(ctx context.Context, _ *kaimonopb.DeleteCartRequest) (*kaimonopb.DeleteCartResponse, error) {
+	usrCtx, err := s.srv.usrCtxFetcher.GetUserContext(ctx)
+	if err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	if err := s.srv.svc.DeleteSessionCart(ctx, usrCtx); err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	return &kaimonopb.DeleteCartResponse{}, nil
+}
+
+type adminServer struct {
+	kaimonopb.UnimplementedCartAdminServiceServer
+
+	srv *Server
+}
+
+func (s adminServer) authorize(ctx context.Context, opType kaimono.OperationType, cartID string) error {
+	req, err := requestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	op := kaimono.Operation{Type: opType, Resource: "cart"}
+
+	return s.srv.authorizer.AuthorizeUser(req, op, cartID)
+}
+
+func (s adminServer) GetCartWithID(
+	ctx context.Context, req *kaimonopb.GetCartWithIDRequest,
+) (*kaimonopb.GetCartWithIDResponse, error) {
+	if err := s.authorize(ctx, kaimono.ReadOp, req.GetId()); err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	cart, err := s.srv.svc.LookupCart(ctx, req.GetId())
+	if err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	return &kaimonopb.GetCartWithIDResponse{Cart: toProtoCart(cart)}, nil
+}
+
+func (s adminServer) CreateCartWithoutSession(
+	ctx context.Context, _ *kaimonopb.CreateCartWithoutSessionRequest,
+) (*kaimonopb.CreateCartWithoutSessionResponse, error) {
+	if err := s.authorize(ctx, kaimono.CreateOp, ""); err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	cart, err := s.srv.svc.CreateCart(ctx)
+	if err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	return &kaimonopb.CreateCartWithoutSessionResponse{Cart: toProtoCart(cart)}, nil
+}
+
+func (s adminServer) UpdateCartWithID(
+	ctx context.Context, req *kaimonopb.UpdateCartWithIDRequest,
+) (*kaimonopb.UpdateCartWithIDResponse, error) {
+	cart := fromProtoCart(req.GetCart())
+	if err := s.authorize(ctx, kaimono.UpdateOp, cart.ID); err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	stored, err := s.srv.svc.LookupCart(ctx, cart.ID)
+	if err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	cart, err = s.srv.svc.UpdateCart(ctx, withStoredFields(cart, stored))
+	if err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	return &kaimonopb.UpdateCartWithIDResponse{Cart: toProtoCart(cart)}, nil
+}
+
+func (s adminServer) DeleteCartWithID(
+	ctx context.Context, req *kaimonopb.DeleteCartWithIDRequest,
+) (*kaimonopb.DeleteCartWithIDResponse, error) {
+	if err := s.authorize(ctx, kaimono.DeleteOp, req.GetId()); err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	if err := s.srv.svc.DeleteCart(ctx, req.GetId()); err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	return &kaimonopb.DeleteCartWithIDResponse{}, nil
+}
+
+func (s adminServer) AssignCartWithID(
+	ctx context.Context, req *kaimonopb.AssignCartWithIDRequest,
+) (*kaimonopb.AssignCartWithIDResponse, error) {
+	if err := s.authorize(ctx, kaimono.UpdateOp, req.GetId()); err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	if err := s.srv.svc.AssignCart(ctx, req.GetId(), req.GetSessionToken()); err != nil {
+		return nil, s.srv.toStatus(err)
+	}
+
+	return &kaimonopb.AssignCartWithIDResponse{}, nil
+}
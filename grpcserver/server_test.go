@@ -0,0 +1,304 @@
+package grpcserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/aalbacetef/kaimono"
+	"github.com/aalbacetef/kaimono/kaimonopb"
+	"github.com/aalbacetef/kaimono/memstore"
+	"github.com/aalbacetef/kaimono/session"
+)
+
+const bufSize = 1024 * 1024
+
+type headerAuthorizer struct{}
+
+func (headerAuthorizer) AuthorizeUser(req *http.Request, op kaimono.Operation, resourceID string) error {
+	if req.Header.Get("authorization") == "Bearer admin" {
+		return nil
+	}
+
+	return kaimono.NotAuthorizedError{Operation: op, ID: resourceID}
+}
+
+func newTestConn(t *testing.T) (*kaimono.Service, *grpc.ClientConn) {
+	t.Helper()
+
+	svc, err := kaimono.NewService(memstore.New(), session.Header{}, headerAuthorizer{}, nil)
+	if err != nil {
+		t.Fatalf("could not create service: %v", err)
+	}
+
+	lis := bufconn.Listen(bufSize)
+	grpcSrv := grpc.NewServer()
+
+	New(svc, MetadataFetcher{}, headerAuthorizer{}, nil).Register(grpcSrv)
+
+	go func() {
+		_ = grpcSrv.Serve(lis)
+	}()
+
+	t.Cleanup(grpcSrv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return svc, conn
+}
+
+func wantCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+
+	if got := status.Code(err); got != code {
+		t.Fatalf("got code %v (%v), want %v", got, err, code)
+	}
+}
+
+func TestCartService(t *testing.T) {
+	_, conn := newTestConn(t)
+	client := kaimonopb.NewCartServiceClient(conn)
+
+	_, err := client.GetCart(context.Background(), &kaimonopb.GetCartRequest{})
+	wantCode(t, err, codes.InvalidArgument)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), DefaultSessionKey, "session-a")
+
+	_, err = client.GetCart(ctx, &kaimonopb.GetCartRequest{})
+	wantCode(t, err, codes.NotFound)
+
+	created, err := client.CreateCart(ctx, &kaimonopb.CreateCartRequest{})
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	_, err = client.CreateCart(ctx, &kaimonopb.CreateCartRequest{})
+	wantCode(t, err, codes.AlreadyExists)
+
+	cart := created.GetCart()
+	cart.Items = append(cart.Items, &kaimonopb.CartItem{
+		Id:       "apple",
+		Quantity: 2,
+		Price:    &kaimonopb.Price{Currency: "EUR", Value: 1.5},
+	})
+
+	if _, err := client.UpdateCart(ctx, &kaimonopb.UpdateCartRequest{Cart: cart}); err != nil {
+		t.Fatalf("could not update cart: %v", err)
+	}
+
+	_, err = client.UpdateCart(ctx, &kaimonopb.UpdateCartRequest{Cart: &kaimonopb.Cart{Id: "other"}})
+	wantCode(t, err, codes.PermissionDenied)
+
+	found, err := client.GetCart(ctx, &kaimonopb.GetCartRequest{})
+	if err != nil {
+		t.Fatalf("could not get cart: %v", err)
+	}
+
+	if items := found.GetCart().GetItems(); len(items) != 1 || items[0].GetPrice().GetValue() != 1.5 {
+		t.Fatalf("unexpected items: %v", items)
+	}
+
+	if _, err := client.DeleteCart(ctx, &kaimonopb.DeleteCartRequest{}); err != nil {
+		t.Fatalf("could not delete cart: %v", err)
+	}
+}
+
+func TestCartAdminService(t *testing.T) {
+	_, conn := newTestConn(t)
+	admin := kaimonopb.NewCartAdminServiceClient(conn)
+
+	_, err := admin.CreateCartWithoutSession(context.Background(), &kaimonopb.CreateCartWithoutSessionRequest{})
+	wantCode(t, err, codes.PermissionDenied)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer admin")
+
+	created, err := admin.CreateCartWithoutSession(ctx, &kaimonopb.CreateCartWithoutSessionRequest{})
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	cartID := created.GetCart().GetId()
+
+	_, err = admin.AssignCartWithID(ctx, &kaimonopb.AssignCartWithIDRequest{Id: cartID, SessionToken: "session-b"})
+	if err != nil {
+		t.Fatalf("could not assign cart: %v", err)
+	}
+
+	sessionCtx := metadata.AppendToOutgoingContext(context.Background(), DefaultSessionKey, "session-b")
+
+	found, err := kaimonopb.NewCartServiceClient(conn).GetCart(sessionCtx, &kaimonopb.GetCartRequest{})
+	if err != nil || found.GetCart().GetId() != cartID {
+		t.Fatalf("got (%v, %v), want the assigned cart", found, err)
+	}
+
+	if _, err := admin.DeleteCartWithID(ctx, &kaimonopb.DeleteCartWithIDRequest{Id: cartID}); err != nil {
+		t.Fatalf("could not delete cart: %v", err)
+	}
+
+	_, err = admin.GetCartWithID(ctx, &kaimonopb.GetCartWithIDRequest{Id: cartID})
+	wantCode(t, err, codes.NotFound)
+}
+
+// shareCart shares the session's Cart through the Service's HTTP API and
+// attaches the guest session to it.
+func shareCart(t *testing.T, svc *kaimono.Service, owner, guest string, perm kaimono.SharePermission) {
+	t.Helper()
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	do := func(sessionToken, path string, body, out any) {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("could not encode body: %v", err)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/cart"+path, bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("could not make request: %v", err)
+		}
+
+		req.Header.Set(session.DefaultHeaderName, sessionToken)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("could not do request: %v", err)
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= http.StatusBadRequest {
+			t.Fatalf("(%s) got code %d", path, resp.StatusCode)
+		}
+
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatalf("could not decode response: %v", err)
+			}
+		}
+	}
+
+	share := kaimono.CreateShareResponse{}
+	do(owner, "/shares", kaimono.CreateShareRequest{Data: kaimono.ShareRequest{Permission: perm}}, &share)
+	do(guest, "/shared/"+share.Data.Token+"/attach", nil, nil)
+}
+
+func TestMutationsGoThroughService(t *testing.T) {
+	svc, conn := newTestConn(t)
+	client := kaimonopb.NewCartServiceClient(conn)
+
+	owner := metadata.AppendToOutgoingContext(context.Background(), DefaultSessionKey, "session-owner")
+	guest := metadata.AppendToOutgoingContext(context.Background(), DefaultSessionKey, "session-guest")
+
+	created, err := client.CreateCart(owner, &kaimonopb.CreateCartRequest{})
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	cart := created.GetCart()
+
+	events, unsubscribe := svc.Subscribe(cart.GetId())
+	t.Cleanup(unsubscribe)
+
+	cart.Items = append(cart.Items, &kaimonopb.CartItem{Id: "apple", Quantity: 1, Price: &kaimonopb.Price{Currency: "EUR", Value: 1}})
+
+	if _, err := client.UpdateCart(owner, &kaimonopb.UpdateCartRequest{Cart: cart}); err != nil {
+		t.Fatalf("could not update cart: %v", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Type != kaimono.CartUpdated || len(event.Cart.Items) != 1 {
+			t.Fatalf("unexpected event: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the update to be published")
+	}
+
+	shareCart(t, svc, "session-owner", "session-guest", kaimono.ViewPermission)
+
+	if _, err := client.GetCart(guest, &kaimonopb.GetCartRequest{}); err != nil {
+		t.Fatalf("expected the guest to view the cart, got %v", err)
+	}
+
+	cart.Items = nil
+
+	_, err = client.UpdateCart(guest, &kaimonopb.UpdateCartRequest{Cart: cart})
+	wantCode(t, err, codes.PermissionDenied)
+
+	_, err = client.DeleteCart(guest, &kaimonopb.DeleteCartRequest{})
+	wantCode(t, err, codes.PermissionDenied)
+}
+
+func TestUpdatesAreValidated(t *testing.T) {
+	_, conn := newTestConn(t)
+	client := kaimonopb.NewCartServiceClient(conn)
+	admin := kaimonopb.NewCartAdminServiceClient(conn)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), DefaultSessionKey, "session-a", "authorization", "Bearer admin")
+
+	created, err := client.CreateCart(ctx, &kaimonopb.CreateCartRequest{})
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	item := func(id string, quantity int64) *kaimonopb.CartItem {
+		return &kaimonopb.CartItem{Id: id, Quantity: quantity, Price: &kaimonopb.Price{Currency: "EUR", Value: 1}}
+	}
+
+	tests := []struct {
+		label string
+		items []*kaimonopb.CartItem
+	}{
+		{"negative quantity", []*kaimonopb.CartItem{item("apple", -1)}},
+		{"duplicate IDs", []*kaimonopb.CartItem{item("apple", 1), item("apple", 2)}},
+		{"over the limit", []*kaimonopb.CartItem{item("apple", kaimono.DefaultMaxQuantity+1)}},
+	}
+
+	for _, c := range tests {
+		cart := created.GetCart()
+		cart.Items = c.items
+
+		_, err := client.UpdateCart(ctx, &kaimonopb.UpdateCartRequest{Cart: cart})
+		wantCode(t, err, codes.InvalidArgument)
+
+		_, err = admin.UpdateCartWithID(ctx, &kaimonopb.UpdateCartWithIDRequest{Cart: cart})
+		wantCode(t, err, codes.InvalidArgument)
+	}
+
+	found, err := client.GetCart(ctx, &kaimonopb.GetCartRequest{})
+	if err != nil || len(found.GetCart().GetItems()) != 0 {
+		t.Fatalf("expected invalid carts not to be stored, got (%v, %v)", found, err)
+	}
+}
+
+func TestInvalidCredentials(t *testing.T) {
+	srv := New(nil, MetadataFetcher{}, headerAuthorizer{}, nil)
+
+	err := srv.toStatus(fmt.Errorf("%w: token expired", kaimono.ErrInvalidCredentials))
+	wantCode(t, err, codes.Unauthenticated)
+}
@@ -0,0 +1,83 @@
+package grpcserver
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+const (
+	DefaultSessionKey = "x-session-token"
+	DefaultUserKey    = "x-user-id"
+)
+
+// UserContextFetcher is the gRPC equivalent of kaimono.UserContextFetcher,
+// it extracts the session token and user ID from the call's context.
+// Returns kaimono.ErrSessionNotFound if no session could be found.
+type UserContextFetcher interface {
+	GetUserContext(ctx context.Context) (kaimono.UserContext, error)
+}
+
+// MetadataFetcher reads the session token and user ID from the incoming
+// metadata. Empty keys default to DefaultSessionKey and DefaultUserKey.
+type MetadataFetcher struct {
+	SessionKey string
+	UserKey    string
+}
+
+func (f MetadataFetcher) GetUserContext(ctx context.Context) (kaimono.UserContext, error) {
+	sessionKey, userKey := f.SessionKey, f.UserKey
+	if sessionKey == "" {
+		sessionKey = DefaultSessionKey
+	}
+
+	if userKey == "" {
+		userKey = DefaultUserKey
+	}
+
+	usrCtx := kaimono.UserContext{
+		SessionToken: firstValue(ctx, sessionKey),
+		UserID:       firstValue(ctx, userKey),
+	}
+
+	if usrCtx.SessionToken == "" {
+		return usrCtx, kaimono.ErrSessionNotFound
+	}
+
+	return usrCtx, nil
+}
+
+func firstValue(ctx context.Context, key string) string {
+	values := metadata.ValueFromIncomingContext(ctx, key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// requestFromContext builds the *http.Request passed to kaimono.Authorizer,
+// which is HTTP-based. The incoming metadata is copied into the headers and
+// the URL path is set to the full gRPC method, e.g:
+// /kaimono.v1.CartAdminService/GetCartWithID.
+func requestFromContext(ctx context.Context) (*http.Request, error) {
+	method, _ := grpc.Method(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, method, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+
+	return req, nil
+}
@@ -0,0 +1,369 @@
+package kaimono
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client-generated key identifying
+	// retries of the same request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set to "true" on responses replayed from
+	// the IdempotencyStore.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	defaultIdempotencyTTL     = 24 * time.Hour
+	maxIdempotencyKeyLength   = 255
+	idempotencySweepInterval  = time.Minute
+	idempotencyScopeSeparator = "\x00"
+)
+
+var (
+	ErrInvalidIdempotencyKey = errors.New("invalid idempotency key")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInUse   = errors.New("a request with the same idempotency key is in progress")
+)
+
+// IdempotencyRecord is the first request made with an idempotency key, and
+// its response once Done.
+type IdempotencyRecord struct {
+	// Key is derived from the Idempotency-Key header and the session or
+	// user making the request, keys are never shared between them.
+	Key string
+
+	// Fingerprint identifies the request's method, path and body.
+	Fingerprint string
+
+	Done      bool
+	Status    int
+	Header    http.Header
+	Body      []byte
+	ExpiresAt time.Time
+}
+
+// Expired reports whether the record can be forgotten.
+func (r IdempotencyRecord) Expired(now time.Time) bool {
+	return !now.Before(r.ExpiresAt)
+}
+
+// IdempotencyStore persists the responses of requests made with an
+// idempotency key. Expired records must be treated as absent.
+type IdempotencyStore interface {
+	// Reserve stores the record of a request about to be handled. If the
+	// key is already stored, it returns the stored record and
+	// ErrAlreadyExists.
+	Reserve(record IdempotencyRecord) (IdempotencyRecord, error)
+
+	// Complete replaces the record with the one holding its response.
+	Complete(record IdempotencyRecord) error
+
+	// Release removes the record, so the request can be retried.
+	Release(key string) error
+}
+
+// WithIdempotencyStore sets the store used for idempotency keys, an
+// in-memory store is used otherwise.
+func WithIdempotencyStore(store IdempotencyStore) Option {
+	return func(svc *Service) {
+		svc.idempotency = store
+	}
+}
+
+// WithIdempotencyTTL sets how long the response to an idempotency key is
+// replayed, defaults to 24 hours.
+func WithIdempotencyTTL(d time.Duration) Option {
+	return func(svc *Service) {
+		svc.idempotencyTTL = d
+	}
+}
+
+// idempotent replays the stored response of requests retried with the
+// same Idempotency-Key header. Reusing a key with a different request is
+// rejected, as are retries made while the first request is still handled.
+// Requests without a key or a session are passed through unchanged.
+//
+// Responses with a 5xx status code are not stored, nor are those of handlers
+// which panic, so they can be retried.
+func (svc *Service) idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get(IdempotencyKeyHeader) == "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		usrCtx, err := svc.usrCtxFetcher.GetUserContext(req)
+		if err != nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		svc.serveIdempotent(w, req, next, userScope(usrCtx))
+	})
+}
+
+// adminIdempotent is idempotent for the admin routes authorized as op.
+// Callers without a session are scoped by the operation they are authorized
+// for instead, as Authorizers don't identify them.
+func (svc *Service) adminIdempotent(op OperationType) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get(IdempotencyKeyHeader) == "" {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			if usrCtx, err := svc.usrCtxFetcher.GetUserContext(req); err == nil {
+				svc.serveIdempotent(w, req, next, userScope(usrCtx))
+				return
+			}
+
+			// unauthorized callers are rejected by the handler.
+			operation := Operation{Type: op, Resource: "cart"}
+			if err := svc.authorizer.AuthorizeUser(req, operation, chi.URLParam(req, "id")); err != nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			svc.serveIdempotent(w, req, next, "authorized:"+string(op))
+		})
+	}
+}
+
+// serveIdempotent handles a request with an Idempotency-Key header, the
+// key being scoped to scope.
+func (svc *Service) serveIdempotent(w http.ResponseWriter, req *http.Request, next http.Handler, scope string) {
+	key := req.Header.Get(IdempotencyKeyHeader)
+	if len(key) > maxIdempotencyKeyLength {
+		svc.json(svc.writeError(w, http.StatusBadRequest, ErrInvalidIdempotencyKey))
+		return
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		svc.json(svc.writeError(w, http.StatusBadRequest, fmt.Errorf("could not read request: %w", err)))
+		return
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	record := IdempotencyRecord{
+		Key:         idempotencyKey(scope, key),
+		Fingerprint: requestFingerprint(req, body),
+		ExpiresAt:   time.Now().Add(svc.idempotencyTTL),
+	}
+
+	stored, err := svc.idempotency.Reserve(record)
+	if errors.Is(err, ErrAlreadyExists) {
+		svc.replay(w, req, record, stored)
+		return
+	}
+
+	if err != nil {
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
+		return
+	}
+
+	logger := svc.loggerFor(req.Context())
+	completed := false
+
+	// released unless completed, including when the handler panics, so
+	// retries aren't rejected as in progress until the record expires.
+	defer func() {
+		if !completed {
+			logIfError(logger, "release idempotency key", svc.idempotency.Release(record.Key))
+		}
+	}()
+
+	rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	next.ServeHTTP(rec, req)
+
+	if rec.status >= http.StatusInternalServerError {
+		return
+	}
+
+	record.Done = true
+	record.Status = rec.status
+	record.Header = rec.header
+	record.Body = rec.body.Bytes()
+
+	err = svc.idempotency.Complete(record)
+	logIfError(logger, "store idempotent response", err)
+
+	completed = err == nil
+}
+
+// replay writes the stored response if it answers the same request. The
+// request keeps its own ID, and the stored cart cookie is only replayed to
+// requests without one, as it would roll back the changes made since.
+func (svc *Service) replay(w http.ResponseWriter, req *http.Request, record, stored IdempotencyRecord) {
+	if stored.Fingerprint != record.Fingerprint {
+		svc.json(svc.writeError(w, http.StatusUnprocessableEntity, ErrIdempotencyKeyReused))
+		return
+	}
+
+	if !stored.Done {
+		svc.json(svc.writeError(w, http.StatusConflict, ErrIdempotencyKeyInUse))
+		return
+	}
+
+	for name, values := range stored.Header {
+		switch {
+		case name == http.CanonicalHeaderKey(RequestIDHeader):
+		case name == "Set-Cookie" && svc.hasCartCookie(req):
+		default:
+			w.Header()[name] = values
+		}
+	}
+
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(stored.Status)
+
+	_, err := w.Write(stored.Body)
+	svc.json(err)
+}
+
+// hasCartCookie reports whether the request carries a stateless Cart.
+func (svc *Service) hasCartCookie(req *http.Request) bool {
+	if svc.stateless == nil {
+		return false
+	}
+
+	_, err := req.Cookie(svc.stateless.cfg.CookieName)
+
+	return err == nil
+}
+
+// userScope scopes keys to the user, or the session for anonymous users.
+func userScope(usrCtx UserContext) string {
+	if usrCtx.IsLoggedIn() {
+		return "user:" + usrCtx.UserID
+	}
+
+	return "session:" + usrCtx.SessionToken
+}
+
+// idempotencyKey scopes the key, hashed so stores don't hold session
+// tokens.
+func idempotencyKey(scope, key string) string {
+	sum := sha256.Sum256([]byte(scope + idempotencyScopeSeparator + key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func requestFingerprint(req *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + req.URL.Path + idempotencyScopeSeparator))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response written through it.
+type responseRecorder struct {
+	http.ResponseWriter
+
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	rec.ResponseWriter.WriteHeader(code)
+
+	// the headers are kept once written, as outer writers may set some
+	// when the header is written, e.g: the stateless cart cookie.
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.header = rec.Header().Clone()
+		rec.wroteHeader = true
+	}
+}
+
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+
+	rec.body.Write(data)
+
+	return rec.ResponseWriter.Write(data)
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// memoryIdempotencyStore is the default, in-memory IdempotencyStore.
+type memoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]IdempotencyRecord
+	lastSweep time.Time
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{
+		records: make(map[string]IdempotencyRecord),
+	}
+}
+
+func (store *memoryIdempotencyStore) Reserve(record IdempotencyRecord) (IdempotencyRecord, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	store.sweep(now)
+
+	if stored, found := store.records[record.Key]; found && !stored.Expired(now) {
+		return stored, ErrAlreadyExists
+	}
+
+	store.records[record.Key] = record
+
+	return record, nil
+}
+
+func (store *memoryIdempotencyStore) Complete(record IdempotencyRecord) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.records[record.Key] = record
+
+	return nil
+}
+
+func (store *memoryIdempotencyStore) Release(key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.records, key)
+
+	return nil
+}
+
+// sweep removes the expired records, at most once per
+// idempotencySweepInterval.
+func (store *memoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(store.lastSweep) < idempotencySweepInterval {
+		return
+	}
+
+	for key, record := range store.records {
+		if record.Expired(now) {
+			delete(store.records, key)
+		}
+	}
+
+	store.lastSweep = now
+}
@@ -0,0 +1,243 @@
+package kaimono
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type idempotentResponse struct {
+	code     int
+	replayed bool
+	body     string
+}
+
+func doIdempotentRequest(t *testing.T, url, sessionToken, key string, body any) idempotentResponse {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("could not encode body: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("could not make request: %v", err)
+	}
+
+	setTestCookie(req, sessionToken)
+	req.Header.Set(IdempotencyKeyHeader, key)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("could not do request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read response: %v", err)
+	}
+
+	return idempotentResponse{
+		code:     resp.StatusCode,
+		replayed: resp.Header.Get(IdempotentReplayedHeader) == "true",
+		body:     string(respBody),
+	}
+}
+
+func newIdempotencyTestServer(t *testing.T, opts ...Option) (*mockBackend, *Service, string) {
+	t.Helper()
+
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil, opts...)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	return mock, svc, srv.URL + "/cart/"
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	mock, _, url := newIdempotencyTestServer(t)
+	user, anonymous := mock.sessions[0], mock.sessions[2]
+
+	first := doIdempotentRequest(t, url, user, "create-1", nil)
+	if first.code != http.StatusCreated || first.replayed {
+		t.Fatalf("unexpected first response: %+v", first)
+	}
+
+	retry := doIdempotentRequest(t, url, user, "create-1", nil)
+	if retry.code != http.StatusCreated || !retry.replayed || retry.body != first.body {
+		t.Fatalf("expected the first response to be replayed, got %+v", retry)
+	}
+
+	if len(mock.carts) != 1 {
+		t.Fatalf("expected 1 cart, got %d", len(mock.carts))
+	}
+
+	// keys are scoped to the session or user.
+	other := doIdempotentRequest(t, url, anonymous, "create-1", nil)
+	if other.code != http.StatusCreated || other.replayed || other.body == first.body {
+		t.Fatalf("expected the key not to be shared, got %+v", other)
+	}
+
+	again := doIdempotentRequest(t, url, user, "create-2", nil)
+	if again.code != http.StatusConflict || again.replayed {
+		t.Fatalf("expected a new key to be handled, got %+v", again)
+	}
+}
+
+func TestIdempotencyKeyReuse(t *testing.T) {
+	mock, svc, url := newIdempotencyTestServer(t)
+	user := mock.sessions[0]
+	usersURL := url + "carts"
+
+	first := doIdempotentRequest(t, usersURL, user, "key", CreateUserCartRequest{Data: NewUserCart{Name: "first"}})
+	if first.code != http.StatusCreated {
+		t.Fatalf("unexpected first response: %+v", first)
+	}
+
+	reused := doIdempotentRequest(t, usersURL, user, "key", CreateUserCartRequest{Data: NewUserCart{Name: "second"}})
+	if reused.code != http.StatusUnprocessableEntity {
+		t.Fatalf("got code %d, want %d", reused.code, http.StatusUnprocessableEntity)
+	}
+
+	// a request still being handled can't be retried yet.
+	body := CreateUserCartRequest{Data: NewUserCart{Name: "first"}}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("could not encode body: %v", err)
+	}
+
+	inProgress := IdempotencyRecord{
+		Key:         idempotencyKey(userScope(UserContext{UserID: "test-user"}), "in-progress"),
+		Fingerprint: requestFingerprint(httptest.NewRequest(http.MethodPost, "/cart/carts", nil), data),
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+
+	if _, err := svc.idempotency.Reserve(inProgress); err != nil {
+		t.Fatalf("could not reserve key: %v", err)
+	}
+
+	if got := doIdempotentRequest(t, usersURL, user, "in-progress", body); got.code != http.StatusConflict {
+		t.Fatalf("got code %d, want %d", got.code, http.StatusConflict)
+	}
+
+	if len(mock.carts) != 1 {
+		t.Fatalf("expected 1 cart, got %d", len(mock.carts))
+	}
+}
+
+func TestIdempotencyKeyExpiry(t *testing.T) {
+	mock, _, url := newIdempotencyTestServer(t, WithIdempotencyTTL(time.Nanosecond))
+	user := mock.sessions[0]
+
+	if got := doIdempotentRequest(t, url, user, "key", nil); got.code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", got.code, http.StatusCreated)
+	}
+
+	got := doIdempotentRequest(t, url, user, "key", nil)
+	if got.code != http.StatusConflict || got.replayed {
+		t.Fatalf("expected the expired key to be handled again, got %+v", got)
+	}
+}
+
+func TestIdempotentPanic(t *testing.T) {
+	mock, svc, _ := newIdempotencyTestServer(t)
+
+	do := func(handler http.HandlerFunc) (code int, panicked bool) {
+		defer func() {
+			panicked = recover() != nil
+		}()
+
+		req := httptest.NewRequest(http.MethodPost, "/cart/", nil)
+		setTestCookie(req, mock.sessions[0])
+		req.Header.Set(IdempotencyKeyHeader, "key")
+
+		rec := httptest.NewRecorder()
+		svc.idempotent(handler).ServeHTTP(rec, req)
+
+		return rec.Code, false
+	}
+
+	if _, panicked := do(func(http.ResponseWriter, *http.Request) { panic("handler failed") }); !panicked {
+		t.Fatalf("expected the panic to be propagated")
+	}
+
+	code, _ := do(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusCreated) })
+	if code != http.StatusCreated {
+		t.Fatalf("expected the retry to be handled, got code %d", code)
+	}
+}
+
+// bearerAuthorizer authorizes admin callers without a session.
+type bearerAuthorizer struct{}
+
+func (bearerAuthorizer) AuthorizeUser(req *http.Request, op Operation, resourceID string) error {
+	if req.Header.Get("Authorization") == "Bearer admin" {
+		return nil
+	}
+
+	return NotAuthorizedError{Operation: op, ID: resourceID}
+}
+
+func TestAdminIdempotentCreate(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, bearerAuthorizer{}, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.AdminRouter("/admin"))
+	t.Cleanup(srv.Close)
+
+	create := func(requestID string) (*http.Response, CreateCartResponse) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/admin/", nil)
+		if err != nil {
+			t.Fatalf("could not make request: %v", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer admin")
+		req.Header.Set(IdempotencyKeyHeader, "create-cart")
+		req.Header.Set(RequestIDHeader, requestID)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("could not do request: %v", err)
+		}
+		defer resp.Body.Close()
+
+		created := CreateCartResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+
+		return resp, created
+	}
+
+	_, first := create("first")
+
+	resp, replayed := create("second")
+	if resp.Header.Get(IdempotentReplayedHeader) != "true" || replayed.Data.ID != first.Data.ID {
+		t.Fatalf("expected the creation to be replayed, got %+v", replayed.Data)
+	}
+
+	if id := resp.Header.Get(RequestIDHeader); id != "second" {
+		t.Fatalf("expected the replay to keep its request ID, got %q", id)
+	}
+
+	if len(mock.carts) != 1 {
+		t.Fatalf("got %d carts, want 1", len(mock.carts))
+	}
+}
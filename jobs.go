@@ -0,0 +1,425 @@
+package kaimono
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// jobRetention is how long finished jobs can still be looked up.
+	jobRetention     = 24 * time.Hour
+	jobSweepInterval = time.Minute
+)
+
+var (
+	ErrJobNotFound    = errors.New("job not found")
+	ErrInvalidJob     = errors.New("invalid job")
+	ErrUnknownJobType = errors.New("unknown job type")
+)
+
+type JobType string
+
+const (
+	DeleteCartsJob    JobType = "delete-carts"
+	RemoveItemJob     JobType = "remove-item"
+	ApplyDiscountJob  JobType = "apply-discount"
+	RevokeDiscountJob JobType = "revoke-discount"
+)
+
+type JobStatus string
+
+const (
+	JobRunning   JobStatus = "running"
+	JobCompleted JobStatus = "completed"
+	JobFailed    JobStatus = "failed"
+)
+
+// JobRequest describes a bulk operation over every Cart matching Filter,
+// a URL-encoded query accepting the same parameters as Service.List (e.g:
+// "item-id=apple&currency=EUR"), except cursor and limit. An empty filter
+// matches every Cart.
+//
+// RemoveItemJob requires ItemID and only visits carts containing it, saved
+// for later or not, ApplyDiscountJob requires Discount and RevokeDiscountJob
+// DiscountID. Dry runs report the carts that would change without changing
+// them.
+type JobRequest struct {
+	Type       JobType   `json:"type"`
+	Filter     string    `json:"filter"`
+	ItemID     string    `json:"item-id,omitempty"`
+	Discount   *Discount `json:"discount,omitempty"`
+	DiscountID string    `json:"discount-id,omitempty"`
+	DryRun     bool      `json:"dry-run"`
+}
+
+// Job tracks the progress of a bulk operation. Total is the number of carts
+// matching the filter when the job started.
+type Job struct {
+	ID         string       `json:"id"`
+	Request    JobRequest   `json:"request"`
+	Status     JobStatus    `json:"status"`
+	Total      int          `json:"total"`
+	Processed  int          `json:"processed"`
+	Changed    int          `json:"changed"`
+	Failures   []JobFailure `json:"failures"`
+	Error      string       `json:"error,omitempty"`
+	CreatedAt  time.Time    `json:"created-at"`
+	FinishedAt *time.Time   `json:"finished-at"`
+}
+
+// JobFailure reports a Cart the job couldn't process.
+type JobFailure struct {
+	CartID string `json:"cart-id"`
+	Error  string `json:"error"`
+}
+
+type CreateJobRequest = Request[JobRequest]
+type CreateJobResponse = Response[Job]
+type GetJobResponse = Response[Job]
+
+// operation returns the Operation type guarding the job.
+func (jr JobRequest) operation() OperationType {
+	if jr.Type == DeleteCartsJob {
+		return BulkDeleteOp
+	}
+
+	return BulkUpdateOp
+}
+
+// query parses the job's filter.
+func (jr JobRequest) query() (CartQuery, string, error) {
+	values, err := url.ParseQuery(jr.Filter)
+	if err != nil {
+		return CartQuery{}, "", fmt.Errorf("%w: could not parse filter: %w", ErrInvalidJob, err)
+	}
+
+	if values.Has("cursor") || values.Has("limit") {
+		return CartQuery{}, "", fmt.Errorf("%w: filter can't page", ErrInvalidJob)
+	}
+
+	query, sessionToken, err := parseCartQuery(values)
+	if err != nil {
+		return query, sessionToken, err
+	}
+
+	// recalled items must not be restored from the saved items either.
+	if jr.Type == RemoveItemJob {
+		query.ItemID = jr.ItemID
+		query.IncludeSaved = true
+	}
+
+	query.Limit = maxPageLimit
+
+	return query, sessionToken, nil
+}
+
+func (jr JobRequest) validate() error {
+	switch jr.Type {
+	case DeleteCartsJob:
+		return nil
+	case RemoveItemJob:
+		if jr.ItemID == "" {
+			return missingJobField("item-id")
+		}
+	case ApplyDiscountJob:
+		if jr.Discount == nil || jr.Discount.ID == "" {
+			return missingJobField("discount")
+		}
+	case RevokeDiscountJob:
+		if jr.DiscountID == "" {
+			return missingJobField("discount-id")
+		}
+	default:
+		return ValidationError{
+			Err:    ErrUnknownJobType,
+			Fields: []FieldError{{Field: "type", Message: fmt.Sprintf("'%s' is not a known job type", jr.Type)}},
+		}
+	}
+
+	return nil
+}
+
+func missingJobField(field string) error {
+	return ValidationError{Err: ErrInvalidJob, Fields: []FieldError{{Field: field, Message: "is required"}}}
+}
+
+// apply applies the job to the Cart, reporting whether it changed.
+func (jr JobRequest) apply(cart *Cart) bool {
+	switch jr.Type {
+	case RemoveItemJob:
+		_, itemErr := cart.TakeItem(jr.ItemID, 0)
+		_, savedErr := cart.TakeSaved(jr.ItemID)
+
+		return itemErr == nil || savedErr == nil
+	case ApplyDiscountJob:
+		cart.ApplyDiscount(*jr.Discount)
+		return true
+	case RevokeDiscountJob:
+		return cart.RemoveDiscount(jr.DiscountID)
+	default:
+		return false
+	}
+}
+
+// jobTracker keeps the state of the jobs started since the Service was
+// created, forgetting them jobRetention after they finish.
+type jobTracker struct {
+	mu        sync.RWMutex
+	jobs      map[string]*Job
+	lastSweep time.Time
+}
+
+func newJobTracker() *jobTracker {
+	return &jobTracker{jobs: make(map[string]*Job)}
+}
+
+func (tracker *jobTracker) add(job Job) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	tracker.sweep(time.Now())
+	tracker.jobs[job.ID] = &job
+}
+
+func (tracker *jobTracker) get(jobID string) (Job, error) {
+	tracker.mu.RLock()
+	defer tracker.mu.RUnlock()
+
+	job, found := tracker.jobs[jobID]
+	if !found || jobExpired(job, time.Now()) {
+		return Job{}, ErrJobNotFound
+	}
+
+	out := *job
+	out.Failures = slices.Clone(job.Failures)
+
+	return out, nil
+}
+
+func (tracker *jobTracker) update(jobID string, fn func(job *Job)) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if job, found := tracker.jobs[jobID]; found {
+		fn(job)
+	}
+}
+
+// sweep removes the expired jobs, at most once per jobSweepInterval.
+func (tracker *jobTracker) sweep(now time.Time) {
+	if now.Sub(tracker.lastSweep) < jobSweepInterval {
+		return
+	}
+
+	for jobID, job := range tracker.jobs {
+		if jobExpired(job, now) {
+			delete(tracker.jobs, jobID)
+		}
+	}
+
+	tracker.lastSweep = now
+}
+
+func jobExpired(job *Job, now time.Time) bool {
+	return job.FinishedAt != nil && now.Sub(*job.FinishedAt) >= jobRetention
+}
+
+// CreateJob starts a bulk operation in the background, authorized as a
+// BulkDeleteOp for DeleteCartsJob and as a BulkUpdateOp otherwise.
+//
+// Status codes:
+//   - 202: Job started
+//   - 400: Invalid request
+//   - 403: Forbidden
+//   - 500: unexpected error
+//   - 501: DB doesn't implement CartQuerier
+func (svc *Service) CreateJob(w http.ResponseWriter, req *http.Request) {
+	payload := CreateJobRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+		svc.json(svc.writeError(w, http.StatusBadRequest, fmt.Errorf("could not decode request: %w", err)))
+		return
+	}
+
+	jobReq := payload.Data
+	if err := jobReq.validate(); err != nil {
+		svc.json(svc.writeError(w, http.StatusBadRequest, err))
+		return
+	}
+
+	op := Operation{
+		Type:     jobReq.operation(),
+		Resource: "cart",
+	}
+
+	if !checkAndReportAuthorized(svc, w, req, op, "") {
+		return
+	}
+
+	query, sessionToken, err := jobReq.query()
+	if err != nil {
+		svc.json(svc.writeError(w, http.StatusBadRequest, err))
+		return
+	}
+
+	if _, ok := svc.store(req.Context()).(CartQuerier); !ok && sessionToken == "" {
+		svc.json(svc.writeError(w, http.StatusNotImplemented, ErrNotSupported))
+		return
+	}
+
+	job := Job{
+		ID:        uuid.New().String(),
+		Request:   jobReq,
+		Status:    JobRunning,
+		Failures:  []JobFailure{},
+		CreatedAt: time.Now().UTC(),
+	}
+
+	svc.jobs.add(job)
+
+	// the job outlives the request, but is still traced as part of it.
+	go svc.runJob(context.WithoutCancel(req.Context()), job.ID, jobReq, query, sessionToken)
+
+	svc.json(writeResponse(w, http.StatusAccepted, CreateJobResponse{Data: job}))
+}
+
+// GetJob returns the progress of the job, authorized as a ReadOp on the
+// "job" resource.
+//
+// Status codes:
+//   - 200: OK
+//   - 403: Forbidden
+//   - 404: No job found
+//   - 500: unexpected error
+func (svc *Service) GetJob(w http.ResponseWriter, req *http.Request) {
+	op := Operation{
+		Type:     ReadOp,
+		Resource: "job",
+	}
+
+	jobID := chi.URLParam(req, "id")
+	if !checkAndReportAuthorized(svc, w, req, op, jobID) {
+		return
+	}
+
+	job, err := svc.jobs.get(jobID)
+	if err != nil {
+		svc.json(svc.writeError(w, http.StatusNotFound, err))
+		return
+	}
+
+	svc.json(writeResponse(w, http.StatusOK, GetJobResponse{Data: job}))
+}
+
+// runJob collects the carts matching the query first, so the carts changed
+// by the job don't affect the pagination, then processes them one by one.
+func (svc *Service) runJob(ctx context.Context, jobID string, jobReq JobRequest, query CartQuery, sessionToken string) {
+	cartIDs := []string{}
+
+	for {
+		page, err := svc.queryCarts(ctx, query, sessionToken)
+		if err != nil {
+			svc.finishJob(ctx, jobID, err)
+			return
+		}
+
+		for _, cart := range page.Carts {
+			cartIDs = append(cartIDs, cart.ID)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		query.Cursor = page.NextCursor
+	}
+
+	svc.jobs.update(jobID, func(job *Job) { job.Total = len(cartIDs) })
+
+	for _, cartID := range cartIDs {
+		changed, err := svc.runJobOnCart(ctx, jobReq, query, cartID)
+
+		svc.jobs.update(jobID, func(job *Job) {
+			job.Processed++
+
+			if changed {
+				job.Changed++
+			}
+
+			if err != nil {
+				job.Failures = append(job.Failures, JobFailure{CartID: cartID, Error: err.Error()})
+			}
+		})
+	}
+
+	svc.finishJob(ctx, jobID, nil)
+}
+
+// runJobOnCart reports whether the Cart was (or, on dry runs, would be)
+// changed. Carts that stopped matching the filter since the job started
+// are skipped.
+func (svc *Service) runJobOnCart(ctx context.Context, jobReq JobRequest, query CartQuery, cartID string) (bool, error) {
+	found, err := svc.store(ctx).LookupCart(cartID)
+	if errors.Is(err, ErrCartNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if !query.Matches(found) {
+		return false, nil
+	}
+
+	if jobReq.Type == DeleteCartsJob {
+		if jobReq.DryRun {
+			return true, nil
+		}
+
+		if err := svc.deleteCart(ctx, cartID); err != nil {
+			return false, err
+		}
+
+		return true, nil
+	}
+
+	cart := found.Clone()
+	if !jobReq.apply(&cart) {
+		return false, nil
+	}
+
+	if jobReq.DryRun {
+		return true, nil
+	}
+
+	if err := svc.updateCart(ctx, &cart); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (svc *Service) finishJob(ctx context.Context, jobID string, err error) {
+	svc.jobs.update(jobID, func(job *Job) {
+		now := time.Now().UTC()
+		job.FinishedAt = &now
+		job.Status = JobCompleted
+
+		if err != nil {
+			job.Status = JobFailed
+			job.Error = err.Error()
+		}
+	})
+
+	logIfError(svc.loggerFor(ctx), "job failed", err)
+}
@@ -0,0 +1,246 @@
+package kaimono
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitForJob(t *testing.T, url, sessionToken string) Job {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		resp := GetJobResponse{}
+		if code := doJSONRequest(t, http.MethodGet, url, sessionToken, nil, &resp); code != http.StatusOK {
+			t.Fatalf("got code %d, want %d", code, http.StatusOK)
+		}
+
+		if resp.Data.Status != JobRunning {
+			return resp.Data
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("job did not finish")
+
+	return Job{}
+}
+
+func TestBulkJobs(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.AdminRouter("/admin"))
+	t.Cleanup(srv.Close)
+
+	for k := range 4 {
+		cart, err := svc.createCart(context.Background())
+		if err != nil {
+			t.Fatalf("could not create cart: %v", err)
+		}
+
+		cart.Items = []CartItem{{ID: "pear", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}, Discounts: []Discount{}}}
+		apple := CartItem{ID: "apple", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}, Discounts: []Discount{}}
+
+		switch k {
+		case 0, 2:
+			cart.Items = append(cart.Items, apple)
+		case 1:
+			cart.Saved = []CartItem{apple}
+		}
+
+		if err := svc.updateCart(context.Background(), &cart); err != nil {
+			t.Fatalf("could not update cart: %v", err)
+		}
+	}
+
+	admin := mock.sessions[1]
+
+	tests := []struct {
+		label       string
+		req         JobRequest
+		wantTotal   int
+		wantChanged int
+		check       func(t *testing.T)
+	}{
+		{
+			label:       "dry runs don't change carts",
+			req:         JobRequest{Type: RemoveItemJob, ItemID: "apple", DryRun: true},
+			wantTotal:   3,
+			wantChanged: 3,
+			check: func(t *testing.T) {
+				page, _ := mock.QueryCarts(CartQuery{ItemID: "apple", IncludeSaved: true, Limit: maxPageLimit})
+				if len(page.Carts) != 3 {
+					t.Fatalf("got %d carts with the item, want 3", len(page.Carts))
+				}
+			},
+		},
+		{
+			label:       "removes the item, even if saved for later",
+			req:         JobRequest{Type: RemoveItemJob, ItemID: "apple"},
+			wantTotal:   3,
+			wantChanged: 3,
+			check: func(t *testing.T) {
+				page, _ := mock.QueryCarts(CartQuery{ItemID: "apple", IncludeSaved: true, Limit: maxPageLimit})
+				if len(page.Carts) != 0 {
+					t.Fatalf("got %d carts with the item, want 0", len(page.Carts))
+				}
+			},
+		},
+		{
+			label:       "applies a discount",
+			req:         JobRequest{Type: ApplyDiscountJob, Discount: &Discount{ID: "recall", Type: FixedAmountDiscount, Value: 1}},
+			wantTotal:   4,
+			wantChanged: 4,
+		},
+		{
+			label:       "revokes a discount",
+			req:         JobRequest{Type: RevokeDiscountJob, DiscountID: "recall"},
+			wantTotal:   4,
+			wantChanged: 4,
+		},
+		{
+			label:       "deletes the carts",
+			req:         JobRequest{Type: DeleteCartsJob, Filter: "item-id=pear"},
+			wantTotal:   4,
+			wantChanged: 4,
+			check: func(t *testing.T) {
+				if len(mock.carts) != 0 {
+					t.Fatalf("got %d carts, want 0", len(mock.carts))
+				}
+			},
+		},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			created := CreateJobResponse{}
+			body := CreateJobRequest{Data: c.req}
+
+			if code := doJSONRequest(t, http.MethodPost, srv.URL+"/admin/jobs", admin, body, &created); code != http.StatusAccepted {
+				t.Fatalf("got code %d, want %d", code, http.StatusAccepted)
+			}
+
+			job := waitForJob(t, srv.URL+"/admin/jobs/"+created.Data.ID, admin)
+			if job.Status != JobCompleted || job.Total != c.wantTotal || job.Changed != c.wantChanged || len(job.Failures) != 0 {
+				t.Fatalf("unexpected job: %+v", job)
+			}
+
+			if c.check != nil {
+				c.check(t)
+			}
+		})
+	}
+}
+
+func TestBulkJobErrors(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.AdminRouter("/admin"))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		label        string
+		sessionToken string
+		req          JobRequest
+		wantCode     int
+	}{
+		{
+			label:        "requires authorization",
+			sessionToken: mock.sessions[0],
+			req:          JobRequest{Type: DeleteCartsJob},
+			wantCode:     http.StatusForbidden,
+		},
+		{
+			label:        "rejects unknown types",
+			sessionToken: mock.sessions[1],
+			req:          JobRequest{Type: "reprice"},
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			label:        "requires the job's arguments",
+			sessionToken: mock.sessions[1],
+			req:          JobRequest{Type: RemoveItemJob},
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			label:        "rejects paging filters",
+			sessionToken: mock.sessions[1],
+			req:          JobRequest{Type: DeleteCartsJob, Filter: "limit=10"},
+			wantCode:     http.StatusBadRequest,
+		},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			body := CreateJobRequest{Data: c.req}
+			if code := doJSONRequest(t, http.MethodPost, srv.URL+"/admin/jobs", c.sessionToken, body, nil); code != c.wantCode {
+				t.Fatalf("got code %d, want %d", code, c.wantCode)
+			}
+		})
+	}
+
+	if code := doJSONRequest(t, http.MethodGet, srv.URL+"/admin/jobs/unknown", mock.sessions[1], nil, nil); code != http.StatusNotFound {
+		t.Fatalf("got code %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestJobRetention(t *testing.T) {
+	tracker := newJobTracker()
+	now := time.Now()
+	finished := now.Add(-jobRetention)
+
+	tracker.add(Job{ID: "running", Status: JobRunning})
+	tracker.add(Job{ID: "finished", Status: JobCompleted, FinishedAt: &finished})
+
+	if _, err := tracker.get("finished"); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected the expired job to be hidden, got %v", err)
+	}
+
+	tracker.sweep(now.Add(2 * jobSweepInterval))
+
+	if _, found := tracker.jobs["finished"]; found {
+		t.Fatalf("expected the expired job to be removed")
+	}
+
+	if _, err := tracker.get("running"); err != nil {
+		t.Fatalf("expected running jobs to be kept, got %v", err)
+	}
+}
+
+func TestBulkJobUsesRequestDB(t *testing.T) {
+	mock := newMockBackend()
+
+	// the Service's DB can't query carts, but the request's can.
+	svc, err := NewService(struct{ DB }{mock}, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	body := strings.NewReader(`{"data": {"type": "delete-carts", "dry-run": true}}`)
+	req := httptest.NewRequest(http.MethodPost, "/admin/jobs", body)
+	req = req.WithContext(context.WithValue(req.Context(), requestDBKey{}, DB(mock)))
+	setTestCookie(req, mock.sessions[1])
+
+	rec := httptest.NewRecorder()
+	svc.CreateJob(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("got code %d, want %d", rec.Code, http.StatusAccepted)
+	}
+}
@@ -0,0 +1,1322 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.1
+// 	protoc        (unknown)
+// source: kaimono/v1/cart.proto
+
+package kaimonopb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type DiscountType int32
+
+const (
+	DiscountType_DISCOUNT_TYPE_UNSPECIFIED  DiscountType = 0
+	DiscountType_DISCOUNT_TYPE_PERCENTAGE   DiscountType = 1
+	DiscountType_DISCOUNT_TYPE_FIXED_AMOUNT DiscountType = 2
+)
+
+// Enum value maps for DiscountType.
+var (
+	DiscountType_name = map[int32]string{
+		0: "DISCOUNT_TYPE_UNSPECIFIED",
+		1: "DISCOUNT_TYPE_PERCENTAGE",
+		2: "DISCOUNT_TYPE_FIXED_AMOUNT",
+	}
+	DiscountType_value = map[string]int32{
+		"DISCOUNT_TYPE_UNSPECIFIED":  0,
+		"DISCOUNT_TYPE_PERCENTAGE":   1,
+		"DISCOUNT_TYPE_FIXED_AMOUNT": 2,
+	}
+)
+
+func (x DiscountType) Enum() *DiscountType {
+	p := new(DiscountType)
+	*p = x
+	return p
+}
+
+func (x DiscountType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (DiscountType) Descriptor() protoreflect.EnumDescriptor {
+	return file_kaimono_v1_cart_proto_enumTypes[0].Descriptor()
+}
+
+func (DiscountType) Type() protoreflect.EnumType {
+	return &file_kaimono_v1_cart_proto_enumTypes[0]
+}
+
+func (x DiscountType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use DiscountType.Descriptor instead.
+func (DiscountType) EnumDescriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{0}
+}
+
+type Price struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Currency string  `protobuf:"bytes,1,opt,name=currency,proto3" json:"currency,omitempty"`
+	Value    float64 `protobuf:"fixed64,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *Price) Reset() {
+	*x = Price{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Price) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Price) ProtoMessage() {}
+
+func (x *Price) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Price.ProtoReflect.Descriptor instead.
+func (*Price) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Price) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Price) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+type Discount struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id    string       `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type  DiscountType `protobuf:"varint,2,opt,name=type,proto3,enum=kaimono.v1.DiscountType" json:"type,omitempty"`
+	Value float64      `protobuf:"fixed64,3,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (x *Discount) Reset() {
+	*x = Discount{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Discount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Discount) ProtoMessage() {}
+
+func (x *Discount) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Discount.ProtoReflect.Descriptor instead.
+func (*Discount) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Discount) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Discount) GetType() DiscountType {
+	if x != nil {
+		return x.Type
+	}
+	return DiscountType_DISCOUNT_TYPE_UNSPECIFIED
+}
+
+func (x *Discount) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+type CartItem struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id        string      `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Quantity  int64       `protobuf:"varint,2,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Discounts []*Discount `protobuf:"bytes,3,rep,name=discounts,proto3" json:"discounts,omitempty"`
+	Price     *Price      `protobuf:"bytes,4,opt,name=price,proto3" json:"price,omitempty"`
+}
+
+func (x *CartItem) Reset() {
+	*x = CartItem{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CartItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CartItem) ProtoMessage() {}
+
+func (x *CartItem) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CartItem.ProtoReflect.Descriptor instead.
+func (*CartItem) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CartItem) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *CartItem) GetQuantity() int64 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *CartItem) GetDiscounts() []*Discount {
+	if x != nil {
+		return x.Discounts
+	}
+	return nil
+}
+
+func (x *CartItem) GetPrice() *Price {
+	if x != nil {
+		return x.Price
+	}
+	return nil
+}
+
+type Cart struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id        string      `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Items     []*CartItem `protobuf:"bytes,2,rep,name=items,proto3" json:"items,omitempty"`
+	Discounts []*Discount `protobuf:"bytes,3,rep,name=discounts,proto3" json:"discounts,omitempty"`
+}
+
+func (x *Cart) Reset() {
+	*x = Cart{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Cart) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Cart) ProtoMessage() {}
+
+func (x *Cart) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Cart.ProtoReflect.Descriptor instead.
+func (*Cart) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Cart) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Cart) GetItems() []*CartItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *Cart) GetDiscounts() []*Discount {
+	if x != nil {
+		return x.Discounts
+	}
+	return nil
+}
+
+type GetCartRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *GetCartRequest) Reset() {
+	*x = GetCartRequest{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCartRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCartRequest) ProtoMessage() {}
+
+func (x *GetCartRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCartRequest.ProtoReflect.Descriptor instead.
+func (*GetCartRequest) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{4}
+}
+
+type GetCartResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cart *Cart `protobuf:"bytes,1,opt,name=cart,proto3" json:"cart,omitempty"`
+}
+
+func (x *GetCartResponse) Reset() {
+	*x = GetCartResponse{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCartResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCartResponse) ProtoMessage() {}
+
+func (x *GetCartResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCartResponse.ProtoReflect.Descriptor instead.
+func (*GetCartResponse) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *GetCartResponse) GetCart() *Cart {
+	if x != nil {
+		return x.Cart
+	}
+	return nil
+}
+
+type CreateCartRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *CreateCartRequest) Reset() {
+	*x = CreateCartRequest{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateCartRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateCartRequest) ProtoMessage() {}
+
+func (x *CreateCartRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateCartRequest.ProtoReflect.Descriptor instead.
+func (*CreateCartRequest) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{6}
+}
+
+type CreateCartResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cart *Cart `protobuf:"bytes,1,opt,name=cart,proto3" json:"cart,omitempty"`
+}
+
+func (x *CreateCartResponse) Reset() {
+	*x = CreateCartResponse{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateCartResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateCartResponse) ProtoMessage() {}
+
+func (x *CreateCartResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateCartResponse.ProtoReflect.Descriptor instead.
+func (*CreateCartResponse) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CreateCartResponse) GetCart() *Cart {
+	if x != nil {
+		return x.Cart
+	}
+	return nil
+}
+
+type UpdateCartRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cart *Cart `protobuf:"bytes,1,opt,name=cart,proto3" json:"cart,omitempty"`
+}
+
+func (x *UpdateCartRequest) Reset() {
+	*x = UpdateCartRequest{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateCartRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateCartRequest) ProtoMessage() {}
+
+func (x *UpdateCartRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateCartRequest.ProtoReflect.Descriptor instead.
+func (*UpdateCartRequest) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UpdateCartRequest) GetCart() *Cart {
+	if x != nil {
+		return x.Cart
+	}
+	return nil
+}
+
+type UpdateCartResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cart *Cart `protobuf:"bytes,1,opt,name=cart,proto3" json:"cart,omitempty"`
+}
+
+func (x *UpdateCartResponse) Reset() {
+	*x = UpdateCartResponse{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateCartResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateCartResponse) ProtoMessage() {}
+
+func (x *UpdateCartResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateCartResponse.ProtoReflect.Descriptor instead.
+func (*UpdateCartResponse) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *UpdateCartResponse) GetCart() *Cart {
+	if x != nil {
+		return x.Cart
+	}
+	return nil
+}
+
+type DeleteCartRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *DeleteCartRequest) Reset() {
+	*x = DeleteCartRequest{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteCartRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteCartRequest) ProtoMessage() {}
+
+func (x *DeleteCartRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteCartRequest.ProtoReflect.Descriptor instead.
+func (*DeleteCartRequest) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{10}
+}
+
+type DeleteCartResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *DeleteCartResponse) Reset() {
+	*x = DeleteCartResponse{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteCartResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteCartResponse) ProtoMessage() {}
+
+func (x *DeleteCartResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteCartResponse.ProtoReflect.Descriptor instead.
+func (*DeleteCartResponse) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{11}
+}
+
+type GetCartWithIDRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *GetCartWithIDRequest) Reset() {
+	*x = GetCartWithIDRequest{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCartWithIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCartWithIDRequest) ProtoMessage() {}
+
+func (x *GetCartWithIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCartWithIDRequest.ProtoReflect.Descriptor instead.
+func (*GetCartWithIDRequest) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GetCartWithIDRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetCartWithIDResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cart *Cart `protobuf:"bytes,1,opt,name=cart,proto3" json:"cart,omitempty"`
+}
+
+func (x *GetCartWithIDResponse) Reset() {
+	*x = GetCartWithIDResponse{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetCartWithIDResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetCartWithIDResponse) ProtoMessage() {}
+
+func (x *GetCartWithIDResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetCartWithIDResponse.ProtoReflect.Descriptor instead.
+func (*GetCartWithIDResponse) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetCartWithIDResponse) GetCart() *Cart {
+	if x != nil {
+		return x.Cart
+	}
+	return nil
+}
+
+type CreateCartWithoutSessionRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *CreateCartWithoutSessionRequest) Reset() {
+	*x = CreateCartWithoutSessionRequest{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateCartWithoutSessionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateCartWithoutSessionRequest) ProtoMessage() {}
+
+func (x *CreateCartWithoutSessionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateCartWithoutSessionRequest.ProtoReflect.Descriptor instead.
+func (*CreateCartWithoutSessionRequest) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{14}
+}
+
+type CreateCartWithoutSessionResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cart *Cart `protobuf:"bytes,1,opt,name=cart,proto3" json:"cart,omitempty"`
+}
+
+func (x *CreateCartWithoutSessionResponse) Reset() {
+	*x = CreateCartWithoutSessionResponse{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateCartWithoutSessionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateCartWithoutSessionResponse) ProtoMessage() {}
+
+func (x *CreateCartWithoutSessionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateCartWithoutSessionResponse.ProtoReflect.Descriptor instead.
+func (*CreateCartWithoutSessionResponse) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *CreateCartWithoutSessionResponse) GetCart() *Cart {
+	if x != nil {
+		return x.Cart
+	}
+	return nil
+}
+
+type UpdateCartWithIDRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cart *Cart `protobuf:"bytes,1,opt,name=cart,proto3" json:"cart,omitempty"`
+}
+
+func (x *UpdateCartWithIDRequest) Reset() {
+	*x = UpdateCartWithIDRequest{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateCartWithIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateCartWithIDRequest) ProtoMessage() {}
+
+func (x *UpdateCartWithIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateCartWithIDRequest.ProtoReflect.Descriptor instead.
+func (*UpdateCartWithIDRequest) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *UpdateCartWithIDRequest) GetCart() *Cart {
+	if x != nil {
+		return x.Cart
+	}
+	return nil
+}
+
+type UpdateCartWithIDResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Cart *Cart `protobuf:"bytes,1,opt,name=cart,proto3" json:"cart,omitempty"`
+}
+
+func (x *UpdateCartWithIDResponse) Reset() {
+	*x = UpdateCartWithIDResponse{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateCartWithIDResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateCartWithIDResponse) ProtoMessage() {}
+
+func (x *UpdateCartWithIDResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateCartWithIDResponse.ProtoReflect.Descriptor instead.
+func (*UpdateCartWithIDResponse) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *UpdateCartWithIDResponse) GetCart() *Cart {
+	if x != nil {
+		return x.Cart
+	}
+	return nil
+}
+
+type DeleteCartWithIDRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (x *DeleteCartWithIDRequest) Reset() {
+	*x = DeleteCartWithIDRequest{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteCartWithIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteCartWithIDRequest) ProtoMessage() {}
+
+func (x *DeleteCartWithIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteCartWithIDRequest.ProtoReflect.Descriptor instead.
+func (*DeleteCartWithIDRequest) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *DeleteCartWithIDRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type DeleteCartWithIDResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *DeleteCartWithIDResponse) Reset() {
+	*x = DeleteCartWithIDResponse{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteCartWithIDResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteCartWithIDResponse) ProtoMessage() {}
+
+func (x *DeleteCartWithIDResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteCartWithIDResponse.ProtoReflect.Descriptor instead.
+func (*DeleteCartWithIDResponse) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{19}
+}
+
+type AssignCartWithIDRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id           string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	SessionToken string `protobuf:"bytes,2,opt,name=session_token,json=sessionToken,proto3" json:"session_token,omitempty"`
+}
+
+func (x *AssignCartWithIDRequest) Reset() {
+	*x = AssignCartWithIDRequest{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AssignCartWithIDRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AssignCartWithIDRequest) ProtoMessage() {}
+
+func (x *AssignCartWithIDRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AssignCartWithIDRequest.ProtoReflect.Descriptor instead.
+func (*AssignCartWithIDRequest) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *AssignCartWithIDRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *AssignCartWithIDRequest) GetSessionToken() string {
+	if x != nil {
+		return x.SessionToken
+	}
+	return ""
+}
+
+type AssignCartWithIDResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *AssignCartWithIDResponse) Reset() {
+	*x = AssignCartWithIDResponse{}
+	mi := &file_kaimono_v1_cart_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AssignCartWithIDResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AssignCartWithIDResponse) ProtoMessage() {}
+
+func (x *AssignCartWithIDResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_kaimono_v1_cart_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AssignCartWithIDResponse.ProtoReflect.Descriptor instead.
+func (*AssignCartWithIDResponse) Descriptor() ([]byte, []int) {
+	return file_kaimono_v1_cart_proto_rawDescGZIP(), []int{21}
+}
+
+var File_kaimono_v1_cart_proto protoreflect.FileDescriptor
+
+var file_kaimono_v1_cart_proto_rawDesc = []byte{
+	0x0a, 0x15, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2f, 0x76, 0x31, 0x2f, 0x63, 0x61, 0x72,
+	0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0a, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f,
+	0x2e, 0x76, 0x31, 0x22, 0x39, 0x0a, 0x05, 0x50, 0x72, 0x69, 0x63, 0x65, 0x12, 0x1a, 0x0a, 0x08,
+	0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x5e,
+	0x0a, 0x08, 0x44, 0x69, 0x73, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x2c, 0x0a, 0x04, 0x74, 0x79,
+	0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f,
+	0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x69, 0x73, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x54, 0x79,
+	0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0x93,
+	0x01, 0x0a, 0x08, 0x43, 0x61, 0x72, 0x74, 0x49, 0x74, 0x65, 0x6d, 0x12, 0x0e, 0x0a, 0x02, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x71,
+	0x75, 0x61, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x71,
+	0x75, 0x61, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x32, 0x0a, 0x09, 0x64, 0x69, 0x73, 0x63, 0x6f,
+	0x75, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6b, 0x61, 0x69,
+	0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x69, 0x73, 0x63, 0x6f, 0x75, 0x6e, 0x74,
+	0x52, 0x09, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x12, 0x27, 0x0a, 0x05, 0x70,
+	0x72, 0x69, 0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x6b, 0x61, 0x69,
+	0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x69, 0x63, 0x65, 0x52, 0x05, 0x70,
+	0x72, 0x69, 0x63, 0x65, 0x22, 0x76, 0x0a, 0x04, 0x43, 0x61, 0x72, 0x74, 0x12, 0x0e, 0x0a, 0x02,
+	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x2a, 0x0a, 0x05,
+	0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6b, 0x61,
+	0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x61, 0x72, 0x74, 0x49, 0x74, 0x65,
+	0x6d, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x12, 0x32, 0x0a, 0x09, 0x64, 0x69, 0x73, 0x63,
+	0x6f, 0x75, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6b, 0x61,
+	0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x69, 0x73, 0x63, 0x6f, 0x75, 0x6e,
+	0x74, 0x52, 0x09, 0x64, 0x69, 0x73, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x22, 0x10, 0x0a, 0x0e,
+	0x47, 0x65, 0x74, 0x43, 0x61, 0x72, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x37,
+	0x0a, 0x0f, 0x47, 0x65, 0x74, 0x43, 0x61, 0x72, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x24, 0x0a, 0x04, 0x63, 0x61, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x10, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x61, 0x72,
+	0x74, 0x52, 0x04, 0x63, 0x61, 0x72, 0x74, 0x22, 0x13, 0x0a, 0x11, 0x43, 0x72, 0x65, 0x61, 0x74,
+	0x65, 0x43, 0x61, 0x72, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x3a, 0x0a, 0x12,
+	0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x24, 0x0a, 0x04, 0x63, 0x61, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x10, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x61,
+	0x72, 0x74, 0x52, 0x04, 0x63, 0x61, 0x72, 0x74, 0x22, 0x39, 0x0a, 0x11, 0x55, 0x70, 0x64, 0x61,
+	0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x24, 0x0a,
+	0x04, 0x63, 0x61, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6b, 0x61,
+	0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x61, 0x72, 0x74, 0x52, 0x04, 0x63,
+	0x61, 0x72, 0x74, 0x22, 0x3a, 0x0a, 0x12, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72,
+	0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x24, 0x0a, 0x04, 0x63, 0x61, 0x72,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e,
+	0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x61, 0x72, 0x74, 0x52, 0x04, 0x63, 0x61, 0x72, 0x74, 0x22,
+	0x13, 0x0a, 0x11, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x22, 0x14, 0x0a, 0x12, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x43, 0x61,
+	0x72, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x26, 0x0a, 0x14, 0x47, 0x65,
+	0x74, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x49, 0x44, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02,
+	0x69, 0x64, 0x22, 0x3d, 0x0a, 0x15, 0x47, 0x65, 0x74, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74,
+	0x68, 0x49, 0x44, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x24, 0x0a, 0x04, 0x63,
+	0x61, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6b, 0x61, 0x69, 0x6d,
+	0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x61, 0x72, 0x74, 0x52, 0x04, 0x63, 0x61, 0x72,
+	0x74, 0x22, 0x21, 0x0a, 0x1f, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x57,
+	0x69, 0x74, 0x68, 0x6f, 0x75, 0x74, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x22, 0x48, 0x0a, 0x20, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x43, 0x61,
+	0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x6f, 0x75, 0x74, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x24, 0x0a, 0x04, 0x63, 0x61, 0x72, 0x74,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f,
+	0x2e, 0x76, 0x31, 0x2e, 0x43, 0x61, 0x72, 0x74, 0x52, 0x04, 0x63, 0x61, 0x72, 0x74, 0x22, 0x3f,
+	0x0a, 0x17, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68,
+	0x49, 0x44, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x24, 0x0a, 0x04, 0x63, 0x61, 0x72,
+	0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e,
+	0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x61, 0x72, 0x74, 0x52, 0x04, 0x63, 0x61, 0x72, 0x74, 0x22,
+	0x40, 0x0a, 0x18, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74,
+	0x68, 0x49, 0x44, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x24, 0x0a, 0x04, 0x63,
+	0x61, 0x72, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x6b, 0x61, 0x69, 0x6d,
+	0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x61, 0x72, 0x74, 0x52, 0x04, 0x63, 0x61, 0x72,
+	0x74, 0x22, 0x29, 0x0a, 0x17, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x57,
+	0x69, 0x74, 0x68, 0x49, 0x44, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02,
+	0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x22, 0x1a, 0x0a, 0x18,
+	0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x49, 0x44,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x4e, 0x0a, 0x17, 0x41, 0x73, 0x73, 0x69,
+	0x67, 0x6e, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x49, 0x44, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x02, 0x69, 0x64, 0x12, 0x23, 0x0a, 0x0d, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x74,
+	0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x73, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x22, 0x1a, 0x0a, 0x18, 0x41, 0x73, 0x73, 0x69,
+	0x67, 0x6e, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x49, 0x44, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x2a, 0x6b, 0x0a, 0x0c, 0x44, 0x69, 0x73, 0x63, 0x6f, 0x75, 0x6e, 0x74,
+	0x54, 0x79, 0x70, 0x65, 0x12, 0x1d, 0x0a, 0x19, 0x44, 0x49, 0x53, 0x43, 0x4f, 0x55, 0x4e, 0x54,
+	0x5f, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45,
+	0x44, 0x10, 0x00, 0x12, 0x1c, 0x0a, 0x18, 0x44, 0x49, 0x53, 0x43, 0x4f, 0x55, 0x4e, 0x54, 0x5f,
+	0x54, 0x59, 0x50, 0x45, 0x5f, 0x50, 0x45, 0x52, 0x43, 0x45, 0x4e, 0x54, 0x41, 0x47, 0x45, 0x10,
+	0x01, 0x12, 0x1e, 0x0a, 0x1a, 0x44, 0x49, 0x53, 0x43, 0x4f, 0x55, 0x4e, 0x54, 0x5f, 0x54, 0x59,
+	0x50, 0x45, 0x5f, 0x46, 0x49, 0x58, 0x45, 0x44, 0x5f, 0x41, 0x4d, 0x4f, 0x55, 0x4e, 0x54, 0x10,
+	0x02, 0x32, 0xb8, 0x02, 0x0a, 0x0b, 0x43, 0x61, 0x72, 0x74, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x12, 0x42, 0x0a, 0x07, 0x47, 0x65, 0x74, 0x43, 0x61, 0x72, 0x74, 0x12, 0x1a, 0x2e, 0x6b,
+	0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x43, 0x61, 0x72,
+	0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1b, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f,
+	0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x43, 0x61, 0x72, 0x74, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x4b, 0x0a, 0x0a, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x43,
+	0x61, 0x72, 0x74, 0x12, 0x1d, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31,
+	0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e,
+	0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e,
+	0x73, 0x65, 0x12, 0x4b, 0x0a, 0x0a, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74,
+	0x12, 0x1d, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x70,
+	0x64, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a,
+	0x1e, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x4b, 0x0a, 0x0a, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x12, 0x1d, 0x2e,
+	0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74,
+	0x65, 0x43, 0x61, 0x72, 0x74, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x6b,
+	0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65,
+	0x43, 0x61, 0x72, 0x74, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x32, 0xfc, 0x03, 0x0a,
+	0x10, 0x43, 0x61, 0x72, 0x74, 0x41, 0x64, 0x6d, 0x69, 0x6e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x12, 0x54, 0x0a, 0x0d, 0x47, 0x65, 0x74, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68,
+	0x49, 0x44, 0x12, 0x20, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e,
+	0x47, 0x65, 0x74, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x49, 0x44, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x21, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76,
+	0x31, 0x2e, 0x47, 0x65, 0x74, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x49, 0x44, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x75, 0x0a, 0x18, 0x43, 0x72, 0x65, 0x61, 0x74,
+	0x65, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x6f, 0x75, 0x74, 0x53, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x12, 0x2b, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31,
+	0x2e, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x6f,
+	0x75, 0x74, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x1a, 0x2c, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x72,
+	0x65, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x6f, 0x75, 0x74, 0x53,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x5d,
+	0x0a, 0x10, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68,
+	0x49, 0x44, 0x12, 0x23, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e,
+	0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x49, 0x44,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x24, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e,
+	0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x57,
+	0x69, 0x74, 0x68, 0x49, 0x44, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x5d, 0x0a,
+	0x10, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x49,
+	0x44, 0x12, 0x23, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x44,
+	0x65, 0x6c, 0x65, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x49, 0x44, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x24, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f,
+	0x2e, 0x76, 0x31, 0x2e, 0x44, 0x65, 0x6c, 0x65, 0x74, 0x65, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69,
+	0x74, 0x68, 0x49, 0x44, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x5d, 0x0a, 0x10,
+	0x41, 0x73, 0x73, 0x69, 0x67, 0x6e, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x49, 0x44,
+	0x12, 0x23, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x73,
+	0x73, 0x69, 0x67, 0x6e, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74, 0x68, 0x49, 0x44, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x24, 0x2e, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2e,
+	0x76, 0x31, 0x2e, 0x41, 0x73, 0x73, 0x69, 0x67, 0x6e, 0x43, 0x61, 0x72, 0x74, 0x57, 0x69, 0x74,
+	0x68, 0x49, 0x44, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x33, 0x5a, 0x31, 0x67,
+	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x61, 0x61, 0x6c, 0x62, 0x61, 0x63,
+	0x65, 0x74, 0x65, 0x66, 0x2f, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x2f, 0x6b, 0x61, 0x69,
+	0x6d, 0x6f, 0x6e, 0x6f, 0x70, 0x62, 0x3b, 0x6b, 0x61, 0x69, 0x6d, 0x6f, 0x6e, 0x6f, 0x70, 0x62,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_kaimono_v1_cart_proto_rawDescOnce sync.Once
+	file_kaimono_v1_cart_proto_rawDescData = file_kaimono_v1_cart_proto_rawDesc
+)
+
+func file_kaimono_v1_cart_proto_rawDescGZIP() []byte {
+	file_kaimono_v1_cart_proto_rawDescOnce.Do(func() {
+		file_kaimono_v1_cart_proto_rawDescData = protoimpl.X.CompressGZIP(file_kaimono_v1_cart_proto_rawDescData)
+	})
+	return file_kaimono_v1_cart_proto_rawDescData
+}
+
+var file_kaimono_v1_cart_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_kaimono_v1_cart_proto_msgTypes = make([]protoimpl.MessageInfo, 22)
+var file_kaimono_v1_cart_proto_goTypes = []any{
+	(DiscountType)(0),                        // 0: kaimono.v1.DiscountType
+	(*Price)(nil),                            // 1: kaimono.v1.Price
+	(*Discount)(nil),                         // 2: kaimono.v1.Discount
+	(*CartItem)(nil),                         // 3: kaimono.v1.CartItem
+	(*Cart)(nil),                             // 4: kaimono.v1.Cart
+	(*GetCartRequest)(nil),                   // 5: kaimono.v1.GetCartRequest
+	(*GetCartResponse)(nil),                  // 6: kaimono.v1.GetCartResponse
+	(*CreateCartRequest)(nil),                // 7: kaimono.v1.CreateCartRequest
+	(*CreateCartResponse)(nil),               // 8: kaimono.v1.CreateCartResponse
+	(*UpdateCartRequest)(nil),                // 9: kaimono.v1.UpdateCartRequest
+	(*UpdateCartResponse)(nil),               // 10: kaimono.v1.UpdateCartResponse
+	(*DeleteCartRequest)(nil),                // 11: kaimono.v1.DeleteCartRequest
+	(*DeleteCartResponse)(nil),               // 12: kaimono.v1.DeleteCartResponse
+	(*GetCartWithIDRequest)(nil),             // 13: kaimono.v1.GetCartWithIDRequest
+	(*GetCartWithIDResponse)(nil),            // 14: kaimono.v1.GetCartWithIDResponse
+	(*CreateCartWithoutSessionRequest)(nil),  // 15: kaimono.v1.CreateCartWithoutSessionRequest
+	(*CreateCartWithoutSessionResponse)(nil), // 16: kaimono.v1.CreateCartWithoutSessionResponse
+	(*UpdateCartWithIDRequest)(nil),          // 17: kaimono.v1.UpdateCartWithIDRequest
+	(*UpdateCartWithIDResponse)(nil),         // 18: kaimono.v1.UpdateCartWithIDResponse
+	(*DeleteCartWithIDRequest)(nil),          // 19: kaimono.v1.DeleteCartWithIDRequest
+	(*DeleteCartWithIDResponse)(nil),         // 20: kaimono.v1.DeleteCartWithIDResponse
+	(*AssignCartWithIDRequest)(nil),          // 21: kaimono.v1.AssignCartWithIDRequest
+	(*AssignCartWithIDResponse)(nil),         // 22: kaimono.v1.AssignCartWithIDResponse
+}
+var file_kaimono_v1_cart_proto_depIdxs = []int32{
+	0,  // 0: kaimono.v1.Discount.type:type_name -> kaimono.v1.DiscountType
+	2,  // 1: kaimono.v1.CartItem.discounts:type_name -> kaimono.v1.Discount
+	1,  // 2: kaimono.v1.CartItem.price:type_name -> kaimono.v1.Price
+	3,  // 3: kaimono.v1.Cart.items:type_name -> kaimono.v1.CartItem
+	2,  // 4: kaimono.v1.Cart.discounts:type_name -> kaimono.v1.Discount
+	4,  // 5: kaimono.v1.GetCartResponse.cart:type_name -> kaimono.v1.Cart
+	4,  // 6: kaimono.v1.CreateCartResponse.cart:type_name -> kaimono.v1.Cart
+	4,  // 7: kaimono.v1.UpdateCartRequest.cart:type_name -> kaimono.v1.Cart
+	4,  // 8: kaimono.v1.UpdateCartResponse.cart:type_name -> kaimono.v1.Cart
+	4,  // 9: kaimono.v1.GetCartWithIDResponse.cart:type_name -> kaimono.v1.Cart
+	4,  // 10: kaimono.v1.CreateCartWithoutSessionResponse.cart:type_name -> kaimono.v1.Cart
+	4,  // 11: kaimono.v1.UpdateCartWithIDRequest.cart:type_name -> kaimono.v1.Cart
+	4,  // 12: kaimono.v1.UpdateCartWithIDResponse.cart:type_name -> kaimono.v1.Cart
+	5,  // 13: kaimono.v1.CartService.GetCart:input_type -> kaimono.v1.GetCartRequest
+	7,  // 14: kaimono.v1.CartService.CreateCart:input_type -> kaimono.v1.CreateCartRequest
+	9,  // 15: kaimono.v1.CartService.UpdateCart:input_type -> kaimono.v1.UpdateCartRequest
+	11, // 16: kaimono.v1.CartService.DeleteCart:input_type -> kaimono.v1.DeleteCartRequest
+	13, // 17: kaimono.v1.CartAdminService.GetCartWithID:input_type -> kaimono.v1.GetCartWithIDRequest
+	15, // 18: kaimono.v1.CartAdminService.CreateCartWithoutSession:input_type -> kaimono.v1.CreateCartWithoutSessionRequest
+	17, // 19: kaimono.v1.CartAdminService.UpdateCartWithID:input_type -> kaimono.v1.UpdateCartWithIDRequest
+	19, // 20: kaimono.v1.CartAdminService.DeleteCartWithID:input_type -> kaimono.v1.DeleteCartWithIDRequest
+	21, // 21: kaimono.v1.CartAdminService.AssignCartWithID:input_type -> kaimono.v1.AssignCartWithIDRequest
+	6,  // 22: kaimono.v1.CartService.GetCart:output_type -> kaimono.v1.GetCartResponse
+	8,  // 23: kaimono.v1.CartService.CreateCart:output_type -> kaimono.v1.CreateCartResponse
+	10, // 24: kaimono.v1.CartService.UpdateCart:output_type -> kaimono.v1.UpdateCartResponse
+	12, // 25: kaimono.v1.CartService.DeleteCart:output_type -> kaimono.v1.DeleteCartResponse
+	14, // 26: kaimono.v1.CartAdminService.GetCartWithID:output_type -> kaimono.v1.GetCartWithIDResponse
+	16, // 27: kaimono.v1.CartAdminService.CreateCartWithoutSession:output_type -> kaimono.v1.CreateCartWithoutSessionResponse
+	18, // 28: kaimono.v1.CartAdminService.UpdateCartWithID:output_type -> kaimono.v1.UpdateCartWithIDResponse
+	20, // 29: kaimono.v1.CartAdminService.DeleteCartWithID:output_type -> kaimono.v1.DeleteCartWithIDResponse
+	22, // 30: kaimono.v1.CartAdminService.AssignCartWithID:output_type -> kaimono.v1.AssignCartWithIDResponse
+	22, // [22:31] is the sub-list for method output_type
+	13, // [13:22] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
+}
+
+func init() { file_kaimono_v1_cart_proto_init() }
+func file_kaimono_v1_cart_proto_init() {
+	if File_kaimono_v1_cart_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_kaimono_v1_cart_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   22,
+			NumExtensions: 0,
+			NumServices:   2,
+		},
+		GoTypes:           file_kaimono_v1_cart_proto_goTypes,
+		DependencyIndexes: file_kaimono_v1_cart_proto_depIdxs,
+		EnumInfos:         file_kaimono_v1_cart_proto_enumTypes,
+		MessageInfos:      file_kaimono_v1_cart_proto_msgTypes,
+	}.Build()
+	File_kaimono_v1_cart_proto = out.File
+	file_kaimono_v1_cart_proto_rawDesc = nil
+	file_kaimono_v1_cart_proto_goTypes = nil
+	file_kaimono_v1_cart_proto_depIdxs = nil
+}
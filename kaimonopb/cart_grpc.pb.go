@@ -0,0 +1,517 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: kaimono/v1/cart.proto
+
+package kaimonopb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	CartService_GetCart_FullMethodName    = "/kaimono.v1.CartService/GetCart"
+	CartService_CreateCart_FullMethodName = "/kaimono.v1.CartService/CreateCart"
+	CartService_UpdateCart_FullMethodName = "/kaimono.v1.CartService/UpdateCart"
+	CartService_DeleteCart_FullMethodName = "/kaimono.v1.CartService/DeleteCart"
+)
+
+// CartServiceClient is the client API for CartService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// CartService acts on the cart of the caller's session. The session token
+// (and user ID, if logged in) are carried in the request metadata.
+type CartServiceClient interface {
+	// GetCart returns the cart associated to the session.
+	GetCart(ctx context.Context, in *GetCartRequest, opts ...grpc.CallOption) (*GetCartResponse, error)
+	// CreateCart creates a new cart for the session.
+	CreateCart(ctx context.Context, in *CreateCartRequest, opts ...grpc.CallOption) (*CreateCartResponse, error)
+	// UpdateCart updates the session's cart, the cart ID must match it.
+	UpdateCart(ctx context.Context, in *UpdateCartRequest, opts ...grpc.CallOption) (*UpdateCartResponse, error)
+	// DeleteCart deletes the session's cart.
+	DeleteCart(ctx context.Context, in *DeleteCartRequest, opts ...grpc.CallOption) (*DeleteCartResponse, error)
+}
+
+type cartServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCartServiceClient(cc grpc.ClientConnInterface) CartServiceClient {
+	return &cartServiceClient{cc}
+}
+
+func (c *cartServiceClient) GetCart(ctx context.Context, in *GetCartRequest, opts ...grpc.CallOption) (*GetCartResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCartResponse)
+	err := c.cc.Invoke(ctx, CartService_GetCart_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cartServiceClient) CreateCart(ctx context.Context, in *CreateCartRequest, opts ...grpc.CallOption) (*CreateCartResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateCartResponse)
+	err := c.cc.Invoke(ctx, CartService_CreateCart_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cartServiceClient) UpdateCart(ctx context.Context, in *UpdateCartRequest, opts ...grpc.CallOption) (*UpdateCartResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateCartResponse)
+	err := c.cc.Invoke(ctx, CartService_UpdateCart_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cartServiceClient) DeleteCart(ctx context.Context, in *DeleteCartRequest, opts ...grpc.CallOption) (*DeleteCartResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteCartResponse)
+	err := c.cc.Invoke(ctx, CartService_DeleteCart_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CartServiceServer is the server API for CartService service.
+// All implementations must embed UnimplementedCartServiceServer
+// for forward compatibility.
+//
+// CartService acts on the cart of the caller's session. The session token
+// (and user ID, if logged in) are carried in the request metadata.
+type CartServiceServer interface {
+	// GetCart returns the cart associated to the session.
+	GetCart(context.Context, *GetCartRequest) (*GetCartResponse, error)
+	// CreateCart creates a new cart for the session.
+	CreateCart(context.Context, *CreateCartRequest) (*CreateCartResponse, error)
+	// UpdateCart updates the session's cart, the cart ID must match it.
+	UpdateCart(context.Context, *UpdateCartRequest) (*UpdateCartResponse, error)
+	// DeleteCart deletes the session's cart.
+	DeleteCart(context.Context, *DeleteCartRequest) (*DeleteCartResponse, error)
+	mustEmbedUnimplementedCartServiceServer()
+}
+
+// UnimplementedCartServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCartServiceServer struct{}
+
+func (UnimplementedCartServiceServer) GetCart(context.Context, *GetCartRequest) (*GetCartResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCart not implemented")
+}
+func (UnimplementedCartServiceServer) CreateCart(context.Context, *CreateCartRequest) (*CreateCartResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCart not implemented")
+}
+func (UnimplementedCartServiceServer) UpdateCart(context.Context, *UpdateCartRequest) (*UpdateCartResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCart not implemented")
+}
+func (UnimplementedCartServiceServer) DeleteCart(context.Context, *DeleteCartRequest) (*DeleteCartResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCart not implemented")
+}
+func (UnimplementedCartServiceServer) mustEmbedUnimplementedCartServiceServer() {}
+func (UnimplementedCartServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeCartServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CartServiceServer will
+// result in compilation errors.
+type UnsafeCartServiceServer interface {
+	mustEmbedUnimplementedCartServiceServer()
+}
+
+func RegisterCartServiceServer(s grpc.ServiceRegistrar, srv CartServiceServer) {
+	// If the following call pancis, it indicates UnimplementedCartServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CartService_ServiceDesc, srv)
+}
+
+func _CartService_GetCart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartServiceServer).GetCart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CartService_GetCart_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartServiceServer).GetCart(ctx, req.(*GetCartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CartService_CreateCart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartServiceServer).CreateCart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CartService_CreateCart_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartServiceServer).CreateCart(ctx, req.(*CreateCartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CartService_UpdateCart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateCartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartServiceServer).UpdateCart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CartService_UpdateCart_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartServiceServer).UpdateCart(ctx, req.(*UpdateCartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CartService_DeleteCart_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCartRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartServiceServer).DeleteCart(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CartService_DeleteCart_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartServiceServer).DeleteCart(ctx, req.(*DeleteCartRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CartService_ServiceDesc is the grpc.ServiceDesc for CartService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CartService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kaimono.v1.CartService",
+	HandlerType: (*CartServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCart",
+			Handler:    _CartService_GetCart_Handler,
+		},
+		{
+			MethodName: "CreateCart",
+			Handler:    _CartService_CreateCart_Handler,
+		},
+		{
+			MethodName: "UpdateCart",
+			Handler:    _CartService_UpdateCart_Handler,
+		},
+		{
+			MethodName: "DeleteCart",
+			Handler:    _CartService_DeleteCart_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "kaimono/v1/cart.proto",
+}
+
+const (
+	CartAdminService_GetCartWithID_FullMethodName            = "/kaimono.v1.CartAdminService/GetCartWithID"
+	CartAdminService_CreateCartWithoutSession_FullMethodName = "/kaimono.v1.CartAdminService/CreateCartWithoutSession"
+	CartAdminService_UpdateCartWithID_FullMethodName         = "/kaimono.v1.CartAdminService/UpdateCartWithID"
+	CartAdminService_DeleteCartWithID_FullMethodName         = "/kaimono.v1.CartAdminService/DeleteCartWithID"
+	CartAdminService_AssignCartWithID_FullMethodName         = "/kaimono.v1.CartAdminService/AssignCartWithID"
+)
+
+// CartAdminServiceClient is the client API for CartAdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// CartAdminService acts on any cart, each call is checked by the Authorizer.
+type CartAdminServiceClient interface {
+	// GetCartWithID returns the cart matching the ID.
+	GetCartWithID(ctx context.Context, in *GetCartWithIDRequest, opts ...grpc.CallOption) (*GetCartWithIDResponse, error)
+	// CreateCartWithoutSession creates a cart that isn't assigned to a session.
+	CreateCartWithoutSession(ctx context.Context, in *CreateCartWithoutSessionRequest, opts ...grpc.CallOption) (*CreateCartWithoutSessionResponse, error)
+	// UpdateCartWithID updates the cart matching cart.id.
+	UpdateCartWithID(ctx context.Context, in *UpdateCartWithIDRequest, opts ...grpc.CallOption) (*UpdateCartWithIDResponse, error)
+	// DeleteCartWithID deletes the cart matching the ID.
+	DeleteCartWithID(ctx context.Context, in *DeleteCartWithIDRequest, opts ...grpc.CallOption) (*DeleteCartWithIDResponse, error)
+	// AssignCartWithID assigns the cart matching the ID to a session.
+	AssignCartWithID(ctx context.Context, in *AssignCartWithIDRequest, opts ...grpc.CallOption) (*AssignCartWithIDResponse, error)
+}
+
+type cartAdminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCartAdminServiceClient(cc grpc.ClientConnInterface) CartAdminServiceClient {
+	return &cartAdminServiceClient{cc}
+}
+
+func (c *cartAdminServiceClient) GetCartWithID(ctx context.Context, in *GetCartWithIDRequest, opts ...grpc.CallOption) (*GetCartWithIDResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCartWithIDResponse)
+	err := c.cc.Invoke(ctx, CartAdminService_GetCartWithID_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cartAdminServiceClient) CreateCartWithoutSession(ctx context.Context, in *CreateCartWithoutSessionRequest, opts ...grpc.CallOption) (*CreateCartWithoutSessionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateCartWithoutSessionResponse)
+	err := c.cc.Invoke(ctx, CartAdminService_CreateCartWithoutSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cartAdminServiceClient) UpdateCartWithID(ctx context.Context, in *UpdateCartWithIDRequest, opts ...grpc.CallOption) (*UpdateCartWithIDResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateCartWithIDResponse)
+	err := c.cc.Invoke(ctx, CartAdminService_UpdateCartWithID_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cartAdminServiceClient) DeleteCartWithID(ctx context.Context, in *DeleteCartWithIDRequest, opts ...grpc.CallOption) (*DeleteCartWithIDResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteCartWithIDResponse)
+	err := c.cc.Invoke(ctx, CartAdminService_DeleteCartWithID_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *cartAdminServiceClient) AssignCartWithID(ctx context.Context, in *AssignCartWithIDRequest, opts ...grpc.CallOption) (*AssignCartWithIDResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AssignCartWithIDResponse)
+	err := c.cc.Invoke(ctx, CartAdminService_AssignCartWithID_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CartAdminServiceServer is the server API for CartAdminService service.
+// All implementations must embed UnimplementedCartAdminServiceServer
+// for forward compatibility.
+//
+// CartAdminService acts on any cart, each call is checked by the Authorizer.
+type CartAdminServiceServer interface {
+	// GetCartWithID returns the cart matching the ID.
+	GetCartWithID(context.Context, *GetCartWithIDRequest) (*GetCartWithIDResponse, error)
+	// CreateCartWithoutSession creates a cart that isn't assigned to a session.
+	CreateCartWithoutSession(context.Context, *CreateCartWithoutSessionRequest) (*CreateCartWithoutSessionResponse, error)
+	// UpdateCartWithID updates the cart matching cart.id.
+	UpdateCartWithID(context.Context, *UpdateCartWithIDRequest) (*UpdateCartWithIDResponse, error)
+	// DeleteCartWithID deletes the cart matching the ID.
+	DeleteCartWithID(context.Context, *DeleteCartWithIDRequest) (*DeleteCartWithIDResponse, error)
+	// AssignCartWithID assigns the cart matching the ID to a session.
+	AssignCartWithID(context.Context, *AssignCartWithIDRequest) (*AssignCartWithIDResponse, error)
+	mustEmbedUnimplementedCartAdminServiceServer()
+}
+
+// UnimplementedCartAdminServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCartAdminServiceServer struct{}
+
+func (UnimplementedCartAdminServiceServer) GetCartWithID(context.Context, *GetCartWithIDRequest) (*GetCartWithIDResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCartWithID not implemented")
+}
+func (UnimplementedCartAdminServiceServer) CreateCartWithoutSession(context.Context, *CreateCartWithoutSessionRequest) (*CreateCartWithoutSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCartWithoutSession not implemented")
+}
+func (UnimplementedCartAdminServiceServer) UpdateCartWithID(context.Context, *UpdateCartWithIDRequest) (*UpdateCartWithIDResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCartWithID not implemented")
+}
+func (UnimplementedCartAdminServiceServer) DeleteCartWithID(context.Context, *DeleteCartWithIDRequest) (*DeleteCartWithIDResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCartWithID not implemented")
+}
+func (UnimplementedCartAdminServiceServer) AssignCartWithID(context.Context, *AssignCartWithIDRequest) (*AssignCartWithIDResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AssignCartWithID not implemented")
+}
+func (UnimplementedCartAdminServiceServer) mustEmbedUnimplementedCartAdminServiceServer() {}
+func (UnimplementedCartAdminServiceServer) testEmbeddedByValue()                          {}
+
+// UnsafeCartAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CartAdminServiceServer will
+// result in compilation errors.
+type UnsafeCartAdminServiceServer interface {
+	mustEmbedUnimplementedCartAdminServiceServer()
+}
+
+func RegisterCartAdminServiceServer(s grpc.ServiceRegistrar, srv CartAdminServiceServer) {
+	// If the following call pancis, it indicates UnimplementedCartAdminServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CartAdminService_ServiceDesc, srv)
+}
+
+func _CartAdminService_GetCartWithID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCartWithIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartAdminServiceServer).GetCartWithID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CartAdminService_GetCartWithID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartAdminServiceServer).GetCartWithID(ctx, req.(*GetCartWithIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CartAdminService_CreateCartWithoutSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCartWithoutSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartAdminServiceServer).CreateCartWithoutSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CartAdminService_CreateCartWithoutSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartAdminServiceServer).CreateCartWithoutSession(ctx, req.(*CreateCartWithoutSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CartAdminService_UpdateCartWithID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateCartWithIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartAdminServiceServer).UpdateCartWithID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CartAdminService_UpdateCartWithID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartAdminServiceServer).UpdateCartWithID(ctx, req.(*UpdateCartWithIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CartAdminService_DeleteCartWithID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCartWithIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartAdminServiceServer).DeleteCartWithID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CartAdminService_DeleteCartWithID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartAdminServiceServer).DeleteCartWithID(ctx, req.(*DeleteCartWithIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CartAdminService_AssignCartWithID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AssignCartWithIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CartAdminServiceServer).AssignCartWithID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CartAdminService_AssignCartWithID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CartAdminServiceServer).AssignCartWithID(ctx, req.(*AssignCartWithIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CartAdminService_ServiceDesc is the grpc.ServiceDesc for CartAdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CartAdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kaimono.v1.CartAdminService",
+	HandlerType: (*CartAdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetCartWithID",
+			Handler:    _CartAdminService_GetCartWithID_Handler,
+		},
+		{
+			MethodName: "CreateCartWithoutSession",
+			Handler:    _CartAdminService_CreateCartWithoutSession_Handler,
+		},
+		{
+			MethodName: "UpdateCartWithID",
+			Handler:    _CartAdminService_UpdateCartWithID_Handler,
+		},
+		{
+			MethodName: "DeleteCartWithID",
+			Handler:    _CartAdminService_DeleteCartWithID_Handler,
+		},
+		{
+			MethodName: "AssignCartWithID",
+			Handler:    _CartAdminService_AssignCartWithID_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "kaimono/v1/cart.proto",
+}
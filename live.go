@@ -0,0 +1,433 @@
+package kaimono
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/google/uuid"
+)
+
+const (
+	liveWriteTimeout = 10 * time.Second
+	liveOutBuffer    = 32
+)
+
+var ErrUnknownOp = errors.New("unknown operation")
+
+type LiveOpType string
+
+const (
+	AddItemOp        LiveOpType = "add-item"
+	SetQuantityOp    LiveOpType = "set-quantity"
+	ChangeQuantityOp LiveOpType = "change-quantity"
+	RemoveItemOp     LiveOpType = "remove-item"
+)
+
+// LiveOp is an item operation sent by a participant of a live session.
+//
+// Concurrent quantity edits are resolved on the server: change-quantity
+// applies Delta to the current quantity, while set-quantity sets Quantity
+// unless BaseQuantity (the quantity the participant last saw) is set, in
+// which case the difference is applied to the current quantity so edits
+// made meanwhile by others are kept. A quantity of 0 removes the item.
+type LiveOp struct {
+	ID           string     `json:"id"`
+	Op           LiveOpType `json:"op"`
+	Item         CartItem   `json:"item"`
+	ItemID       string     `json:"item-id"`
+	Quantity     int        `json:"quantity"`
+	BaseQuantity *int       `json:"base-quantity,omitempty"`
+	Delta        int        `json:"delta"`
+}
+
+func (op LiveOp) apply(cart *Cart) error {
+	switch op.Op {
+	case AddItemOp:
+		return cart.AddItem(op.Item)
+	case RemoveItemOp:
+		return cart.RemoveItem(op.ItemID)
+	case SetQuantityOp, ChangeQuantityOp:
+	default:
+		return fmt.Errorf("%w: '%s'", ErrUnknownOp, op.Op)
+	}
+
+	item, found := cart.Item(op.ItemID)
+	if !found {
+		return ErrItemNotFound
+	}
+
+	if op.Op == SetQuantityOp && op.Quantity < 0 {
+		return ErrInvalidQuantity
+	}
+
+	quantity := item.Quantity + op.Delta
+
+	if op.Op == SetQuantityOp {
+		quantity = op.Quantity
+		if op.BaseQuantity != nil {
+			quantity = item.Quantity + op.Quantity - *op.BaseQuantity
+		}
+	}
+
+	return cart.SetQuantity(op.ItemID, max(0, quantity))
+}
+
+type LiveMessageType string
+
+const (
+	LiveSnapshot LiveMessageType = "snapshot"
+	LiveUpdate   LiveMessageType = "update"
+	LivePresence LiveMessageType = "presence"
+	LiveDeleted  LiveMessageType = "deleted"
+	LiveError    LiveMessageType = "error"
+)
+
+// LiveMessage is sent by the server to the participants of a live session.
+//
+//   - snapshot: sent on connect, with the Cart, the participants and the
+//     participant's own ID in You.
+//   - update: the Cart changed, By and Op are set when the change was made
+//     by a participant, and are empty for changes made through other APIs.
+//   - presence: a participant joined or left.
+//   - deleted: the Cart was deleted, the connection is closed afterwards.
+//   - error: the operation with ID Op.ID failed.
+type LiveMessage struct {
+	Type         LiveMessageType `json:"type"`
+	Version      uint64          `json:"version,omitempty"`
+	Cart         *Cart           `json:"cart,omitempty"`
+	Op           *LiveOp         `json:"op,omitempty"`
+	By           string          `json:"by,omitempty"`
+	You          string          `json:"you,omitempty"`
+	Participants []Participant   `json:"participants,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	Code         ErrorCode       `json:"code,omitempty"`
+}
+
+// Participant is a connection to a live session. The ID identifies the
+// connection, UserID is empty for anonymous users.
+type Participant struct {
+	ID     string `json:"id"`
+	UserID string `json:"user-id"`
+}
+
+// Live upgrades the connection to a WebSocket to edit the current
+// session's Cart together with every other session sharing it. Clients
+// send LiveOp messages and receive LiveMessage messages.
+//
+// Each operation is checked against the participant's session, so a
+// participant whose session no longer maps to the Cart is disconnected.
+//
+// Status codes:
+//   - 101: Switching protocols
+//   - 400: No session found for request
+//   - 403: Session's access to a shared cart was revoked
+//   - 404: No cart found for session
+//   - 500: unexpected error
+func (svc *Service) Live(w http.ResponseWriter, req *http.Request) {
+	usrCtx, ok := svc.fetchCtxOrExit(w, req)
+	if !ok {
+		return
+	}
+
+	cart, ok := svc.lookupAccessibleCartOrExit(req.Context(), w, usrCtx, ViewPermission)
+	if !ok {
+		return
+	}
+
+	conn, err := websocket.Accept(w, req, nil)
+	if err != nil {
+		logIfError(svc.loggerFor(req.Context()), "websocket accept", err)
+		return
+	}
+
+	defer conn.CloseNow()
+
+	p := &liveParticipant{
+		Participant:  Participant{ID: uuid.New().String(), UserID: usrCtx.UserID},
+		sessionToken: usrCtx.SessionToken,
+		out:          make(chan LiveMessage, liveOutBuffer),
+		done:         make(chan struct{}),
+	}
+
+	room := svc.live.join(req.Context(), svc, cart.ID, p)
+	defer svc.live.leave(room, p)
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+
+	go p.writeLoop(ctx, conn)
+
+	for {
+		op := LiveOp{}
+		if err := wsjson.Read(ctx, conn, &op); err != nil {
+			return
+		}
+
+		room.apply(ctx, p, op)
+	}
+}
+
+// lookupSessionCartOrExit looks up the session's Cart, writing the error
+// response if it fails.
+func (svc *Service) lookupSessionCartOrExit(ctx context.Context, w http.ResponseWriter, usrCtx UserContext) (Cart, bool) {
+	cart, err := svc.store(ctx).LookupCartForSession(usrCtx.SessionToken)
+	if errors.Is(err, ErrSessionNotFound) {
+		svc.json(svc.writeError(w, http.StatusBadRequest, err))
+		return cart, false
+	}
+
+	if errors.Is(err, ErrCartNotFound) {
+		svc.json(svc.writeError(w, http.StatusNotFound, err))
+		return cart, false
+	}
+
+	if err != nil {
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
+		return cart, false
+	}
+
+	return cart, true
+}
+
+type liveParticipant struct {
+	Participant
+
+	sessionToken string
+	out          chan LiveMessage
+	done         chan struct{}
+	closeOnce    sync.Once
+}
+
+// send never blocks, participants that fall behind are disconnected.
+func (p *liveParticipant) send(msg LiveMessage) {
+	select {
+	case p.out <- msg:
+	default:
+		p.close()
+	}
+}
+
+func (p *liveParticipant) close() {
+	p.closeOnce.Do(func() { close(p.done) })
+}
+
+func (p *liveParticipant) writeLoop(ctx context.Context, conn *websocket.Conn) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.done:
+			conn.Close(websocket.StatusPolicyViolation, "disconnected")
+			return
+		case msg := <-p.out:
+			writeCtx, cancel := context.WithTimeout(ctx, liveWriteTimeout)
+			err := wsjson.Write(writeCtx, conn, msg)
+
+			cancel()
+
+			if err != nil {
+				conn.CloseNow()
+				return
+			}
+
+			if msg.Type == LiveDeleted {
+				conn.Close(websocket.StatusNormalClosure, "cart deleted")
+				return
+			}
+		}
+	}
+}
+
+// liveHub holds a room per Cart with connected participants.
+type liveHub struct {
+	mu    sync.Mutex
+	rooms map[string]*liveRoom
+}
+
+func newLiveHub() *liveHub {
+	return &liveHub{rooms: make(map[string]*liveRoom)}
+}
+
+func (hub *liveHub) join(ctx context.Context, svc *Service, cartID string, p *liveParticipant) *liveRoom {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	room, found := hub.rooms[cartID]
+	if !found {
+		events, unsubscribe := svc.events.subscribe(cartID)
+		room = &liveRoom{
+			svc:          svc,
+			cartID:       cartID,
+			participants: make(map[*liveParticipant]struct{}),
+			stop:         make(chan struct{}),
+			lastVersion:  svc.events.version(cartID),
+		}
+
+		hub.rooms[cartID] = room
+
+		go room.relay(events, unsubscribe)
+	}
+
+	room.add(ctx, p)
+
+	return room
+}
+
+func (hub *liveHub) leave(room *liveRoom, p *liveParticipant) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if room.remove(p) == 0 {
+		close(room.stop)
+		delete(hub.rooms, room.cartID)
+	}
+}
+
+// liveRoom serializes the operations on a Cart and broadcasts the changes.
+type liveRoom struct {
+	svc          *Service
+	cartID       string
+	mu           sync.Mutex
+	participants map[*liveParticipant]struct{}
+	lastVersion  uint64
+	stop         chan struct{}
+}
+
+func (room *liveRoom) add(ctx context.Context, p *liveParticipant) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	cart, err := room.svc.store(ctx).LookupCart(room.cartID)
+	if err != nil {
+		p.send(LiveMessage{Type: LiveError, Error: err.Error(), Code: ErrorCodeOf(err)})
+		p.close()
+
+		return
+	}
+
+	// the others are notified before adding p, who gets the snapshot instead.
+	room.participants[p] = struct{}{}
+	participants := room.presence()
+	delete(room.participants, p)
+
+	room.broadcast(LiveMessage{Type: LivePresence, Participants: participants})
+	room.participants[p] = struct{}{}
+
+	p.send(LiveMessage{
+		Type:         LiveSnapshot,
+		Version:      room.lastVersion,
+		Cart:         &cart,
+		You:          p.ID,
+		Participants: participants,
+	})
+}
+
+// remove returns the number of participants left.
+func (room *liveRoom) remove(p *liveParticipant) int {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	delete(room.participants, p)
+	room.broadcast(LiveMessage{Type: LivePresence, Participants: room.presence()})
+
+	return len(room.participants)
+}
+
+// presence must be called with the lock held.
+func (room *liveRoom) presence() []Participant {
+	participants := make([]Participant, 0, len(room.participants))
+	for p := range room.participants {
+		participants = append(participants, p.Participant)
+	}
+
+	return participants
+}
+
+// broadcast must be called with the lock held.
+func (room *liveRoom) broadcast(msg LiveMessage) {
+	for p := range room.participants {
+		p.send(msg)
+	}
+}
+
+func (room *liveRoom) apply(ctx context.Context, p *liveParticipant, op LiveOp) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	fail := func(err error) {
+		p.send(LiveMessage{Type: LiveError, Op: &op, Error: err.Error(), Code: ErrorCodeOf(err)})
+	}
+
+	// the session may have been assigned another Cart since connecting.
+	sessionCart, err := room.svc.store(ctx).LookupCartForSession(p.sessionToken)
+	if err != nil || sessionCart.ID != room.cartID {
+		fail(NotAuthorizedError{Operation: Operation{Resource: "cart", Type: UpdateOp}, ID: room.cartID})
+		p.close()
+
+		return
+	}
+
+	if err := room.svc.checkAccess(p.sessionToken, room.cartID, EditPermission); err != nil {
+		fail(err)
+		return
+	}
+
+	found, err := room.svc.store(ctx).LookupCart(room.cartID)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	cart := found.Clone()
+	if err := op.apply(&cart); err != nil {
+		fail(err)
+		return
+	}
+
+	if err := room.svc.updateCart(ctx, &cart); err != nil {
+		fail(err)
+		return
+	}
+
+	room.lastVersion = room.svc.events.version(room.cartID)
+	room.broadcast(LiveMessage{Type: LiveUpdate, Version: room.lastVersion, Cart: &cart, Op: &op, By: p.ID})
+}
+
+// relay broadcasts the changes made outside of the room, e.g: through the
+// standard routes.
+func (room *liveRoom) relay(events <-chan CartEvent, unsubscribe func()) {
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-room.stop:
+			return
+		case event := <-events:
+			room.relayEvent(event)
+		}
+	}
+}
+
+func (room *liveRoom) relayEvent(event CartEvent) {
+	room.mu.Lock()
+	defer room.mu.Unlock()
+
+	if event.Type == CartDeleted {
+		room.broadcast(LiveMessage{Type: LiveDeleted, Version: event.Version})
+		return
+	}
+
+	if event.Version <= room.lastVersion {
+		return
+	}
+
+	room.lastVersion = event.Version
+	cart := event.Cart
+	room.broadcast(LiveMessage{Type: LiveUpdate, Version: event.Version, Cart: &cart})
+}
@@ -0,0 +1,138 @@
+package kaimono
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+func dialLive(t *testing.T, ctx context.Context, srv *httptest.Server, sessionToken string) *websocket.Conn {
+	t.Helper()
+
+	header := http.Header{}
+	header.Set("Cookie", testCookieName+"="+sessionToken)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/cart/live"
+
+	//nolint:bodyclose // the response body is managed by the websocket library.
+	conn, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{HTTPHeader: header})
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+
+	t.Cleanup(func() { conn.CloseNow() })
+
+	return conn
+}
+
+// readLive reads messages until one of the given type arrives.
+func readLive(t *testing.T, ctx context.Context, conn *websocket.Conn, typ LiveMessageType) LiveMessage {
+	t.Helper()
+
+	for {
+		msg := LiveMessage{}
+		if err := wsjson.Read(ctx, conn, &msg); err != nil {
+			t.Fatalf("could not read %s message: %v", typ, err)
+		}
+
+		if msg.Type == typ {
+			return msg
+		}
+	}
+}
+
+func TestLiveCollaboration(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	// both sessions share the same cart.
+	cart, err := mock.CreateCartForSession(mock.sessions[0])
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	mock.data[mock.sessions[2]] = mock.data[mock.sessions[0]]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	alice := dialLive(t, ctx, srv, mock.sessions[0])
+
+	snapshot := readLive(t, ctx, alice, LiveSnapshot)
+	if snapshot.Cart == nil || snapshot.Cart.ID != cart.ID || len(snapshot.Participants) != 1 {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+
+	bob := dialLive(t, ctx, srv, mock.sessions[2])
+	readLive(t, ctx, bob, LiveSnapshot)
+
+	if presence := readLive(t, ctx, alice, LivePresence); len(presence.Participants) != 2 {
+		t.Fatalf("expected 2 participants, got %+v", presence.Participants)
+	}
+
+	add := LiveOp{ID: "1", Op: AddItemOp, Item: CartItem{ID: "apple", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}}}
+	if err := wsjson.Write(ctx, alice, add); err != nil {
+		t.Fatalf("could not send op: %v", err)
+	}
+
+	update := readLive(t, ctx, bob, LiveUpdate)
+	if update.By != snapshot.You || len(update.Cart.Items) != 1 {
+		t.Fatalf("unexpected update: %+v", update)
+	}
+
+	readLive(t, ctx, alice, LiveUpdate)
+
+	// both edit the quantity having last seen 1: the edits are merged.
+	base := 1
+	ops := map[*websocket.Conn]LiveOp{
+		alice: {ID: "2", Op: SetQuantityOp, ItemID: "apple", Quantity: 3, BaseQuantity: &base},
+		bob:   {ID: "3", Op: SetQuantityOp, ItemID: "apple", Quantity: 2, BaseQuantity: &base},
+	}
+
+	for conn, op := range ops {
+		if err := wsjson.Write(ctx, conn, op); err != nil {
+			t.Fatalf("could not send op: %v", err)
+		}
+	}
+
+	readLive(t, ctx, alice, LiveUpdate)
+	update = readLive(t, ctx, alice, LiveUpdate)
+
+	if item, _ := update.Cart.Item("apple"); item.Quantity != 4 {
+		t.Fatalf("got quantity %d, want 4", item.Quantity)
+	}
+
+	if err := wsjson.Write(ctx, bob, LiveOp{ID: "4", Op: RemoveItemOp, ItemID: "pear"}); err != nil {
+		t.Fatalf("could not send op: %v", err)
+	}
+
+	if msg := readLive(t, ctx, bob, LiveError); msg.Op == nil || msg.Op.ID != "4" {
+		t.Fatalf("unexpected error message: %+v", msg)
+	}
+
+	bob.Close(websocket.StatusNormalClosure, "")
+
+	if presence := readLive(t, ctx, alice, LivePresence); len(presence.Participants) != 1 {
+		t.Fatalf("expected 1 participant, got %+v", presence.Participants)
+	}
+
+	// changes made outside of the live session are relayed.
+	if err := svc.deleteCart(context.Background(), cart.ID); err != nil {
+		t.Fatalf("could not delete cart: %v", err)
+	}
+
+	readLive(t, ctx, alice, LiveDeleted)
+}
@@ -0,0 +1,290 @@
+package kaimono
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request's correlation ID. It's taken from
+// the incoming request if set, generated otherwise, and returned in the
+// response.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the incoming correlation IDs, longer ones are
+// replaced.
+const maxRequestIDLength = 128
+
+const redacted = "[REDACTED]"
+
+// redactedKeys are the log attributes whose values are never logged.
+var redactedKeys = map[string]bool{
+	"session-token": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"authorization": true,
+}
+
+type requestIDKey struct{}
+
+type requestLoggerKey struct{}
+
+// RequestID returns the correlation ID of the request being handled with
+// ctx, or an empty string outside of the Service's routers.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// loggerFor returns the logger of the request being handled with ctx,
+// adding its correlation ID to every line, or the Service's logger.
+func (svc *Service) loggerFor(ctx context.Context) *slog.Logger {
+	return requestLogger(ctx, svc.logger)
+}
+
+func requestLogger(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(requestLoggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return fallback
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// logRequests sets the request's correlation ID and logger, and logs the
+// request once handled. The route is logged instead of the path, which
+// may hold share or restore tokens.
+func (svc *Service) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+
+		id := req.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+
+		logger := svc.logger.With("request-id", id)
+
+		ctx := context.WithValue(req.Context(), requestIDKey{}, id)
+		ctx = context.WithValue(ctx, requestLoggerKey{}, logger)
+
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+
+		next.ServeHTTP(rec, req.WithContext(ctx))
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		level := slog.LevelInfo
+		if rec.code >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		logger.LogAttrs(
+			ctx, level, "request",
+			slog.String("method", req.Method),
+			slog.String("route", route),
+			slog.Int("status", rec.code),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
+
+// LogValue never logs the session token, only whether it's set.
+func (u UserContext) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("user-id", u.UserID),
+		slog.Bool("session", u.SessionToken != ""),
+	)
+}
+
+// redactingHandler replaces the values of the redactedKeys.
+type redactingHandler struct {
+	slog.Handler
+}
+
+func (h redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	out := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+
+	record.Attrs(func(attr slog.Attr) bool {
+		out.AddAttrs(redactAttr(attr))
+		return true
+	})
+
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for k, attr := range attrs {
+		redactedAttrs[k] = redactAttr(attr)
+	}
+
+	return redactingHandler{h.Handler.WithAttrs(redactedAttrs)}
+}
+
+func (h redactingHandler) WithGroup(name string) slog.Handler {
+	return redactingHandler{h.Handler.WithGroup(name)}
+}
+
+func redactAttr(attr slog.Attr) slog.Attr {
+	if redactedKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+
+	if attr.Value.Kind() != slog.KindGroup {
+		return attr
+	}
+
+	group := attr.Value.Group()
+	out := make([]any, len(group))
+
+	for k, groupAttr := range group {
+		out[k] = redactAttr(groupAttr)
+	}
+
+	return slog.Group(attr.Key, out...)
+}
+
+// loggedAuthorizer logs the authorization decisions.
+type loggedAuthorizer struct {
+	authorizer Authorizer
+	logger     *slog.Logger
+}
+
+func (a loggedAuthorizer) AuthorizeUser(req *http.Request, op Operation, resourceID string) error {
+	err := a.authorizer.AuthorizeUser(req, op, resourceID)
+	logger := requestLogger(req.Context(), a.logger)
+	attrs := []slog.Attr{
+		slog.String("operation", string(op.Type)),
+		slog.String("resource", op.Resource),
+		slog.String("resource-id", resourceID),
+	}
+
+	switch {
+	case err == nil:
+		logger.LogAttrs(req.Context(), slog.LevelInfo, "authorized", attrs...)
+	case errors.As(err, &NotAuthorizedError{}):
+		logger.LogAttrs(req.Context(), slog.LevelWarn, "not authorized", attrs...)
+	default:
+		logger.LogAttrs(req.Context(), slog.LevelError, "authorization failed", append(attrs, slog.Any("error", err))...)
+	}
+
+	return err
+}
+
+// expectedDBErrors are reported to the client and only logged at the
+// debug level.
+var expectedDBErrors = []error{ErrCartNotFound, ErrSessionNotFound, ErrAlreadyExists}
+
+// logDB returns the DB logging the errors of every call made while
+// handling ctx.
+func logDB(ctx context.Context, logger *slog.Logger, db DB) DB {
+	logged := loggedDB{db: db, ctx: ctx, logger: logger}
+
+	return decorateDB(
+		db, logged,
+		func(querier CartQuerier) CartQuerier { return loggedQuerier{querier, logged} },
+		func(lister UserCartLister) UserCartLister { return loggedLister{lister, logged} },
+	)
+}
+
+type loggedDB struct {
+	db     DB
+	ctx    context.Context
+	logger *slog.Logger
+}
+
+func (l loggedDB) logError(method string, err error, attrs ...slog.Attr) error {
+	if err == nil {
+		return nil
+	}
+
+	level := slog.LevelError
+
+	for _, expected := range expectedDBErrors {
+		if errors.Is(err, expected) {
+			level = slog.LevelDebug
+		}
+	}
+
+	attrs = append(attrs, slog.String("method", method), slog.Any("error", err))
+	l.logger.LogAttrs(l.ctx, level, "db call failed", attrs...)
+
+	return err
+}
+
+func (l loggedDB) CreateCartForSession(sessionToken string) (Cart, error) {
+	cart, err := l.db.CreateCartForSession(sessionToken)
+	return cart, l.logError("CreateCartForSession", err)
+}
+
+func (l loggedDB) CreateCart() (Cart, error) {
+	cart, err := l.db.CreateCart()
+	return cart, l.logError("CreateCart", err)
+}
+
+func (l loggedDB) DeleteCart(cartID string) error {
+	return l.logError("DeleteCart", l.db.DeleteCart(cartID), slog.String("cart-id", cartID))
+}
+
+func (l loggedDB) UpdateCart(cart Cart) error {
+	return l.logError("UpdateCart", l.db.UpdateCart(cart), slog.String("cart-id", cart.ID))
+}
+
+func (l loggedDB) LookupCart(cartID string) (Cart, error) {
+	cart, err := l.db.LookupCart(cartID)
+	return cart, l.logError("LookupCart", err, slog.String("cart-id", cartID))
+}
+
+func (l loggedDB) LookupCartForSession(sessionToken string) (Cart, error) {
+	cart, err := l.db.LookupCartForSession(sessionToken)
+	return cart, l.logError("LookupCartForSession", err)
+}
+
+func (l loggedDB) AssignCartToSession(cartID, sessionToken string) error {
+	return l.logError("AssignCartToSession", l.db.AssignCartToSession(cartID, sessionToken), slog.String("cart-id", cartID))
+}
+
+type loggedQuerier struct {
+	db     CartQuerier
+	logged loggedDB
+}
+
+func (l loggedQuerier) QueryCarts(query CartQuery) (CartPage, error) {
+	page, err := l.db.QueryCarts(query)
+	return page, l.logged.logError("QueryCarts", err)
+}
+
+type loggedLister struct {
+	db     UserCartLister
+	logged loggedDB
+}
+
+func (l loggedLister) ListCartsForUser(userID string) ([]Cart, error) {
+	carts, err := l.db.ListCartsForUser(userID)
+	return carts, l.logged.logError("ListCartsForUser", err, slog.String("user-id", userID))
+}
@@ -0,0 +1,149 @@
+package kaimono
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer is written to by the server's goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) lines(t *testing.T) []map[string]any {
+	t.Helper()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	lines := []map[string]any{}
+	scanner := bufio.NewScanner(bytes.NewReader(b.buf.Bytes()))
+
+	for scanner.Scan() {
+		line := map[string]any{}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("could not decode log line: %v", err)
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func TestRequestLogging(t *testing.T) {
+	mock := newMockBackend()
+	logs := &syncBuffer{}
+	logger := slog.New(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	svc, err := NewService(mock, mock, mock, logger)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.AdminRouter("/admin"))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		label     string
+		session   string
+		requestID string
+		wantCode  int
+		wantMsgs  []string
+	}{
+		{"generated ID", mock.sessions[1], "", http.StatusNotFound, []string{"authorized", "db call failed", "request"}},
+		{"incoming ID", mock.sessions[1], "abc-123", http.StatusNotFound, []string{"authorized", "db call failed", "request"}},
+		{"invalid ID", mock.sessions[1], "has spaces", http.StatusNotFound, []string{"authorized", "db call failed", "request"}},
+		{"denied", mock.sessions[0], "denied-1", http.StatusForbidden, []string{"not authorized", "request"}},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/admin/missing", nil)
+			if err != nil {
+				t.Fatalf("could not make request: %v", err)
+			}
+
+			setTestCookie(req, c.session)
+
+			if c.requestID != "" {
+				req.Header.Set(RequestIDHeader, c.requestID)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("could not do request: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != c.wantCode {
+				t.Fatalf("got code %d, want %d", resp.StatusCode, c.wantCode)
+			}
+
+			id := resp.Header.Get(RequestIDHeader)
+			if id == "" || (c.requestID != "" && validRequestID(c.requestID) && id != c.requestID) {
+				t.Fatalf("got request ID %q, want %q", id, c.requestID)
+			}
+
+			if c.requestID != "" && !validRequestID(c.requestID) && id == c.requestID {
+				t.Fatalf("expected the invalid request ID to be replaced")
+			}
+
+			msgs := []string{}
+
+			for _, line := range logs.lines(t) {
+				if line["request-id"] == id {
+					msgs = append(msgs, line["msg"].(string))
+				}
+			}
+
+			if strings.Join(msgs, ",") != strings.Join(c.wantMsgs, ",") {
+				t.Fatalf("got log lines %v, want %v", msgs, c.wantMsgs)
+			}
+		})
+	}
+
+	for _, line := range logs.lines(t) {
+		if line["msg"] == "request" && line["route"] != "/admin/{id}" {
+			t.Fatalf("got route %v, want %q", line["route"], "/admin/{id}")
+		}
+	}
+}
+
+func TestLogRedaction(t *testing.T) {
+	logs := &syncBuffer{}
+	logger := slog.New(redactingHandler{slog.NewJSONHandler(logs, nil)})
+
+	const secret = "secret-token"
+
+	logger.With("cookie", secret).Info(
+		"redacted",
+		"session-token", secret,
+		slog.Group("headers", "Authorization", "Bearer "+secret),
+		"user", UserContext{UserID: "test-user", SessionToken: secret},
+	)
+
+	if strings.Contains(logs.buf.String(), secret) {
+		t.Fatalf("secret was logged: %s", logs.buf.String())
+	}
+
+	line := logs.lines(t)[0]
+	if user, _ := line["user"].(map[string]any); user["user-id"] != "test-user" || user["session"] != true {
+		t.Fatalf("unexpected user: %v", line["user"])
+	}
+}
@@ -0,0 +1,279 @@
+// Package memstore provides an in-memory implementation of kaimono.DB,
+// optionally persisted to a JSON snapshot on disk.
+package memstore
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+// Store keeps carts and their session assignments in memory. Any non-empty
+// session token is considered a valid session.
+//
+// When created via Open, every mutation is written to the snapshot file.
+type Store struct {
+	mu       sync.RWMutex
+	path     string
+	carts    map[string]kaimono.Cart
+	sessions map[string]string
+}
+
+// snapshot is the on-disk representation of a Store.
+type snapshot struct {
+	Carts    map[string]kaimono.Cart `json:"carts"`
+	Sessions map[string]string       `json:"sessions"`
+}
+
+// New returns an empty, non-persistent Store.
+func New() *Store {
+	return &Store{
+		carts:    make(map[string]kaimono.Cart),
+		sessions: make(map[string]string),
+	}
+}
+
+// Open returns a Store persisted at path, loading the existing snapshot
+// if there is one.
+func Open(path string) (*Store, error) {
+	store := New()
+	store.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read snapshot: %w", err)
+	}
+
+	snap := snapshot{}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("could not decode snapshot: %w", err)
+	}
+
+	if snap.Carts != nil {
+		store.carts = snap.Carts
+	}
+
+	if snap.Sessions != nil {
+		store.sessions = snap.Sessions
+	}
+
+	return store, nil
+}
+
+func (store *Store) CreateCartForSession(sessionToken string) (kaimono.Cart, error) {
+	if sessionToken == "" {
+		return kaimono.Cart{}, kaimono.ErrSessionNotFound
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if cartID, found := store.sessions[sessionToken]; found {
+		return store.carts[cartID].Clone(), kaimono.ErrAlreadyExists
+	}
+
+	cart := newCart()
+	store.carts[cart.ID] = cart
+	store.sessions[sessionToken] = cart.ID
+
+	return cart.Clone(), store.persist()
+}
+
+func (store *Store) CreateCart() (kaimono.Cart, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	cart := newCart()
+	store.carts[cart.ID] = cart
+
+	return cart.Clone(), store.persist()
+}
+
+func (store *Store) DeleteCart(cartID string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, found := store.carts[cartID]; !found {
+		return kaimono.ErrCartNotFound
+	}
+
+	delete(store.carts, cartID)
+
+	for token, id := range store.sessions {
+		if id == cartID {
+			delete(store.sessions, token)
+		}
+	}
+
+	return store.persist()
+}
+
+func (store *Store) UpdateCart(cart kaimono.Cart) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, found := store.carts[cart.ID]; !found {
+		return kaimono.ErrCartNotFound
+	}
+
+	store.carts[cart.ID] = cart.Clone()
+
+	return store.persist()
+}
+
+func (store *Store) LookupCart(cartID string) (kaimono.Cart, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	cart, found := store.carts[cartID]
+	if !found {
+		return kaimono.Cart{}, kaimono.ErrCartNotFound
+	}
+
+	return cart.Clone(), nil
+}
+
+func (store *Store) LookupCartForSession(sessionToken string) (kaimono.Cart, error) {
+	if sessionToken == "" {
+		return kaimono.Cart{}, kaimono.ErrSessionNotFound
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	cartID, found := store.sessions[sessionToken]
+	if !found {
+		return kaimono.Cart{}, kaimono.ErrCartNotFound
+	}
+
+	return store.carts[cartID].Clone(), nil
+}
+
+func (store *Store) AssignCartToSession(cartID, sessionToken string) error {
+	if sessionToken == "" {
+		return kaimono.ErrSessionNotFound
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, found := store.carts[cartID]; !found {
+		return kaimono.ErrCartNotFound
+	}
+
+	store.sessions[sessionToken] = cartID
+
+	return store.persist()
+}
+
+// ListCartsForUser returns the carts owned by the user, sorted by name.
+func (store *Store) ListCartsForUser(userID string) ([]kaimono.Cart, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	carts := []kaimono.Cart{}
+
+	for _, cart := range store.carts {
+		if cart.UserID == userID {
+			carts = append(carts, cart.Clone())
+		}
+	}
+
+	slices.SortFunc(carts, func(a, b kaimono.Cart) int {
+		return cmp.Or(strings.Compare(a.Name, b.Name), strings.Compare(a.ID, b.ID))
+	})
+
+	return carts, nil
+}
+
+// QueryCarts returns the carts matching the query, ordered by ID. The
+// cursor is the ID of the last cart of the previous page.
+func (store *Store) QueryCarts(query kaimono.CartQuery) (kaimono.CartPage, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	ids := make([]string, 0, len(store.carts))
+	for id := range store.carts {
+		if id > query.Cursor {
+			ids = append(ids, id)
+		}
+	}
+
+	slices.Sort(ids)
+
+	page := kaimono.CartPage{Carts: []kaimono.Cart{}}
+
+	for _, id := range ids {
+		cart := store.carts[id]
+		if !query.Matches(cart) {
+			continue
+		}
+
+		if query.Limit > 0 && len(page.Carts) == query.Limit {
+			page.NextCursor = page.Carts[len(page.Carts)-1].ID
+			break
+		}
+
+		page.Carts = append(page.Carts, cart.Clone())
+	}
+
+	return page, nil
+}
+
+// persist writes the snapshot to disk. It must be called with the lock held.
+func (store *Store) persist() error {
+	if store.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(snapshot{Carts: store.carts, Sessions: store.sessions})
+	if err != nil {
+		return fmt.Errorf("could not encode snapshot: %w", err)
+	}
+
+	// write to a temporary file first so a crash never leaves a truncated snapshot.
+	tmp, err := os.CreateTemp(filepath.Dir(store.path), ".kaimono-*")
+	if err != nil {
+		return fmt.Errorf("could not create snapshot: %w", err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write snapshot: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), store.path); err != nil {
+		return fmt.Errorf("could not replace snapshot: %w", err)
+	}
+
+	return nil
+}
+
+func newCart() kaimono.Cart {
+	return kaimono.Cart{
+		ID:        uuid.New().String(),
+		Items:     []kaimono.CartItem{},
+		Discounts: []kaimono.Discount{},
+	}
+}
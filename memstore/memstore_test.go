@@ -0,0 +1,190 @@
+package memstore
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+func TestStoreSessions(t *testing.T) {
+	store := New()
+
+	cart, err := store.CreateCartForSession("session-a")
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	if _, err := store.CreateCartForSession("session-a"); !errors.Is(err, kaimono.ErrAlreadyExists) {
+		t.Fatalf("got %v, want %v", err, kaimono.ErrAlreadyExists)
+	}
+
+	if _, err := store.LookupCartForSession(""); !errors.Is(err, kaimono.ErrSessionNotFound) {
+		t.Fatalf("got %v, want %v", err, kaimono.ErrSessionNotFound)
+	}
+
+	if err := store.AssignCartToSession(cart.ID, "session-b"); err != nil {
+		t.Fatalf("could not assign cart: %v", err)
+	}
+
+	found, err := store.LookupCartForSession("session-b")
+	if err != nil {
+		t.Fatalf("could not lookup cart: %v", err)
+	}
+
+	if found.ID != cart.ID {
+		t.Fatalf("got cart %s, want %s", found.ID, cart.ID)
+	}
+
+	if err := store.DeleteCart(cart.ID); err != nil {
+		t.Fatalf("could not delete cart: %v", err)
+	}
+
+	for _, token := range []string{"session-a", "session-b"} {
+		if _, err := store.LookupCartForSession(token); !errors.Is(err, kaimono.ErrCartNotFound) {
+			t.Fatalf("(%s) got %v, want %v", token, err, kaimono.ErrCartNotFound)
+		}
+	}
+}
+
+func TestStoreCopiesCarts(t *testing.T) {
+	store := New()
+
+	cart, err := store.CreateCart()
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	cart.Items = append(cart.Items, kaimono.CartItem{ID: "item", Quantity: 1})
+	if err := store.UpdateCart(cart); err != nil {
+		t.Fatalf("could not update cart: %v", err)
+	}
+
+	cart.Items[0].Quantity = 5
+
+	found, err := store.LookupCart(cart.ID)
+	if err != nil {
+		t.Fatalf("could not lookup cart: %v", err)
+	}
+
+	if found.Items[0].Quantity != 1 {
+		t.Fatalf("stored cart was mutated: got quantity %d", found.Items[0].Quantity)
+	}
+}
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "carts.json")
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("could not open store: %v", err)
+	}
+
+	cart, err := store.CreateCartForSession("session")
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("could not reopen store: %v", err)
+	}
+
+	found, err := reopened.LookupCartForSession("session")
+	if err != nil {
+		t.Fatalf("could not lookup cart: %v", err)
+	}
+
+	if found.ID != cart.ID {
+		t.Fatalf("got cart %s, want %s", found.ID, cart.ID)
+	}
+}
+
+func TestStoreListsUserCarts(t *testing.T) {
+	store := New()
+
+	for _, name := range []string{"wishlist", "office", ""} {
+		cart, err := store.CreateCart()
+		if err != nil {
+			t.Fatalf("could not create cart: %v", err)
+		}
+
+		cart.Name = name
+		if name != "" {
+			cart.UserID = "user-a"
+		}
+
+		if err := store.UpdateCart(cart); err != nil {
+			t.Fatalf("could not update cart: %v", err)
+		}
+	}
+
+	carts, err := store.ListCartsForUser("user-a")
+	if err != nil {
+		t.Fatalf("could not list carts: %v", err)
+	}
+
+	if len(carts) != 2 || carts[0].Name != "office" || carts[1].Name != "wishlist" {
+		t.Fatalf("unexpected carts: %+v", carts)
+	}
+}
+
+func TestStoreQueriesCarts(t *testing.T) {
+	store := New()
+
+	for k := range 5 {
+		cart, err := store.CreateCart()
+		if err != nil {
+			t.Fatalf("could not create cart: %v", err)
+		}
+
+		if k%2 == 0 {
+			cart.UserID = "user-a"
+		}
+
+		if err := store.UpdateCart(cart); err != nil {
+			t.Fatalf("could not update cart: %v", err)
+		}
+	}
+
+	query := kaimono.CartQuery{UserID: "user-a", Limit: 2}
+	seen := 0
+
+	for range 3 {
+		page, err := store.QueryCarts(query)
+		if err != nil {
+			t.Fatalf("could not query carts: %v", err)
+		}
+
+		for _, cart := range page.Carts {
+			if cart.UserID != "user-a" {
+				t.Fatalf("unexpected cart: %+v", cart)
+			}
+		}
+
+		seen += len(page.Carts)
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		query.Cursor = page.NextCursor
+	}
+
+	if seen != 3 {
+		t.Fatalf("got %d carts, want 3", seen)
+	}
+
+	// zero values don't filter, nor limit.
+	for _, query := range []kaimono.CartQuery{{}, {Limit: -1}} {
+		page, err := store.QueryCarts(query)
+		if err != nil {
+			t.Fatalf("could not query carts: %v", err)
+		}
+
+		if len(page.Carts) != 5 || page.NextCursor != "" {
+			t.Fatalf("(%+v) got %d carts and cursor %q, want every cart", query, len(page.Carts), page.NextCursor)
+		}
+	}
+}
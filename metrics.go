@@ -0,0 +1,479 @@
+package kaimono
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// defaultBuckets are the histogram buckets in seconds, the same as the
+// Prometheus client's defaults.
+var defaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics collects request, DB and business metrics, served in the
+// Prometheus text format by ServeHTTP. Pass it to NewService with
+// WithMetrics and wrap the DB with InstrumentDB.
+type Metrics struct {
+	requests        *counterVec
+	requestDuration *histogramVec
+	dbCalls         *counterVec
+	dbDuration      *histogramVec
+
+	mu        sync.Mutex
+	cartItems map[string]int
+}
+
+// NewMetrics returns an empty Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests: newCounterVec(
+			"kaimono_http_requests_total", "Total HTTP requests handled.",
+			"route", "method", "code", "operation",
+		),
+		requestDuration: newHistogramVec(
+			"kaimono_http_request_duration_seconds", "Duration of the HTTP requests.",
+			"route", "method", "operation",
+		),
+		dbCalls: newCounterVec(
+			"kaimono_db_calls_total", "Total DB calls, by result (ok or error).",
+			"method", "result",
+		),
+		dbDuration: newHistogramVec(
+			"kaimono_db_call_duration_seconds", "Duration of the DB calls.",
+			"method",
+		),
+		cartItems: make(map[string]int),
+	}
+}
+
+// WithMetrics instruments the Service's routers and tracks the carts
+// changed through it.
+func WithMetrics(m *Metrics) Option {
+	return func(svc *Service) {
+		svc.metrics = m
+		svc.events.observe(m.observe)
+	}
+}
+
+// Metrics returns the Metrics passed to NewService, if any.
+func (svc *Service) Metrics() *Metrics {
+	return svc.metrics
+}
+
+// observe tracks the number of items of each non-empty Cart changed since
+// startup.
+func (m *Metrics) observe(event CartEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	items := 0
+	for _, item := range event.Cart.Items {
+		items += item.Quantity
+	}
+
+	// deleted and empty carts don't count, so they aren't kept.
+	if event.Type == CartDeleted || items == 0 {
+		delete(m.cartItems, event.CartID)
+		return
+	}
+
+	m.cartItems[event.CartID] = items
+}
+
+// operationLabel holds the Operation authorized by the request's handler.
+type operationLabel struct {
+	op OperationType
+}
+
+type operationLabelKey struct{}
+
+// labelOperation records the Operation authorized while handling the
+// request, the first one if several are.
+func labelOperation(ctx context.Context, op OperationType) {
+	if label, ok := ctx.Value(operationLabelKey{}).(*operationLabel); ok && label.op == "" {
+		label.op = op
+	}
+}
+
+// requestOperation returns the OperationType of requests which didn't
+// authorize one, from their method and route: only the routes creating carts
+// are labeled as CreateOp, and listing carts, the admin router's only read
+// at its base, as ListOp.
+func requestOperation(admin bool, method string, rctx *chi.Context) OperationType {
+	route := ""
+	if rctx != nil && len(rctx.RoutePatterns) > 0 {
+		route = rctx.RoutePatterns[len(rctx.RoutePatterns)-1]
+	}
+
+	switch method {
+	case http.MethodPost:
+		if route == "/" || (!admin && route == "/carts") {
+			return CreateOp
+		}
+
+		return UpdateOp
+	case http.MethodPut:
+		return UpdateOp
+	case http.MethodDelete:
+		return DeleteOp
+	}
+
+	if admin && route == "/" {
+		return ListOp
+	}
+
+	return ReadOp
+}
+
+// middleware records the requests handled by the router.
+func (m *Metrics) middleware(admin bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+			label := &operationLabel{}
+
+			next.ServeHTTP(rec, req.WithContext(context.WithValue(req.Context(), operationLabelKey{}, label)))
+
+			rctx := chi.RouteContext(req.Context())
+
+			route := "unmatched"
+			if rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			op := string(label.op)
+			if op == "" {
+				op = string(requestOperation(admin, req.Method, rctx))
+			}
+
+			m.requests.inc(route, req.Method, strconv.Itoa(rec.code), op)
+			m.requestDuration.observe(time.Since(start).Seconds(), route, req.Method, op)
+		})
+	}
+}
+
+// statusRecorder records the status code, while still allowing streaming
+// and WebSocket upgrades.
+type statusRecorder struct {
+	http.ResponseWriter
+
+	code        int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.code = code
+		rec.wroteHeader = true
+	}
+
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(data []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.ResponseWriter.Write(data)
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rec.code = http.StatusSwitchingProtocols
+	rec.wroteHeader = true
+
+	return http.NewResponseController(rec.ResponseWriter).Hijack()
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	// nothing can be done once the client stops reading.
+	_, _ = m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	out := &countingWriter{w: w}
+
+	m.requests.write(out)
+	m.requestDuration.write(out)
+	m.dbCalls.write(out)
+	m.dbDuration.write(out)
+
+	m.mu.Lock()
+	active, items := 0, 0
+
+	for _, n := range m.cartItems {
+		if n > 0 {
+			active++
+			items += n
+		}
+	}
+	m.mu.Unlock()
+
+	perCart := 0.0
+	if active > 0 {
+		perCart = float64(items) / float64(active)
+	}
+
+	writeGauge(out, "kaimono_active_carts", "Carts with items, among the carts changed since startup.", float64(active))
+	writeGauge(out, "kaimono_items_per_cart", "Average quantity of items in the active carts.", perCart)
+
+	return out.n, out.err
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) printf(format string, args ...any) {
+	if cw.err != nil {
+		return
+	}
+
+	n, err := fmt.Fprintf(cw.w, format, args...)
+	cw.n += int64(n)
+	cw.err = err
+}
+
+func writeGauge(out *countingWriter, name, help string, value float64) {
+	out.printf("# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatFloat(value))
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// labelKey joins label values, which can't contain the separator.
+func labelKey(values []string) string {
+	return strings.Join(values, "\x00")
+}
+
+func formatLabels(names, values []string, extra ...string) string {
+	pairs := make([]string, 0, len(names)+len(extra)/2)
+
+	for k, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[k])+`"`)
+	}
+
+	for k := 0; k+1 < len(extra); k += 2 {
+		pairs = append(pairs, extra[k]+`="`+escapeLabel(extra[k+1])+`"`)
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+type counterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+func (c *counterVec) inc(values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[labelKey(values)]++
+}
+
+func (c *counterVec) write(out *countingWriter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out.printf("# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+
+	for _, key := range slices.Sorted(maps.Keys(c.values)) {
+		out.printf("%s%s %s\n", c.name, formatLabels(c.labels, strings.Split(key, "\x00")), formatFloat(c.values[key]))
+	}
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type histogramVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+func newHistogramVec(name, help string, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, values: make(map[string]*histogram)}
+}
+
+func (h *histogramVec) observe(v float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := labelKey(values)
+
+	hist, found := h.values[key]
+	if !found {
+		hist = &histogram{counts: make([]uint64, len(defaultBuckets))}
+		h.values[key] = hist
+	}
+
+	for k, bound := range defaultBuckets {
+		if v <= bound {
+			hist.counts[k]++
+		}
+	}
+
+	hist.sum += v
+	hist.count++
+}
+
+func (h *histogramVec) write(out *countingWriter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out.printf("# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+
+	for _, key := range slices.Sorted(maps.Keys(h.values)) {
+		hist := h.values[key]
+		values := strings.Split(key, "\x00")
+
+		for k, bound := range defaultBuckets {
+			out.printf("%s_bucket%s %d\n", h.name, formatLabels(h.labels, values, "le", formatFloat(bound)), hist.counts[k])
+		}
+
+		out.printf("%s_bucket%s %d\n", h.name, formatLabels(h.labels, values, "le", "+Inf"), hist.count)
+		out.printf("%s_sum%s %s\n", h.name, formatLabels(h.labels, values), formatFloat(hist.sum))
+		out.printf("%s_count%s %d\n", h.name, formatLabels(h.labels, values), hist.count)
+	}
+}
+
+// InstrumentDB wraps the DB, recording the calls made to it. The wrapped DB
+// still implements CartQuerier and UserCartLister if db does.
+func (m *Metrics) InstrumentDB(db DB) DB {
+	return decorateDB(
+		db, instrumentedDB{db: db, m: m},
+		func(querier CartQuerier) CartQuerier { return instrumentedQuerier{querier, m} },
+		func(lister UserCartLister) UserCartLister { return instrumentedLister{lister, m} },
+	)
+}
+
+// record records a DB call started at start, returning err.
+func (m *Metrics) record(method string, start time.Time, err error) error {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	m.dbCalls.inc(method, result)
+	m.dbDuration.observe(time.Since(start).Seconds(), method)
+
+	return err
+}
+
+type instrumentedDB struct {
+	db DB
+	m  *Metrics
+}
+
+func (i instrumentedDB) CreateCartForSession(sessionToken string) (Cart, error) {
+	start := time.Now()
+	cart, err := i.db.CreateCartForSession(sessionToken)
+
+	return cart, i.m.record("CreateCartForSession", start, err)
+}
+
+func (i instrumentedDB) CreateCart() (Cart, error) {
+	start := time.Now()
+	cart, err := i.db.CreateCart()
+
+	return cart, i.m.record("CreateCart", start, err)
+}
+
+func (i instrumentedDB) DeleteCart(cartID string) error {
+	start := time.Now()
+
+	return i.m.record("DeleteCart", start, i.db.DeleteCart(cartID))
+}
+
+func (i instrumentedDB) UpdateCart(cart Cart) error {
+	start := time.Now()
+
+	return i.m.record("UpdateCart", start, i.db.UpdateCart(cart))
+}
+
+func (i instrumentedDB) LookupCart(cartID string) (Cart, error) {
+	start := time.Now()
+	cart, err := i.db.LookupCart(cartID)
+
+	return cart, i.m.record("LookupCart", start, err)
+}
+
+func (i instrumentedDB) LookupCartForSession(sessionToken string) (Cart, error) {
+	start := time.Now()
+	cart, err := i.db.LookupCartForSession(sessionToken)
+
+	return cart, i.m.record("LookupCartForSession", start, err)
+}
+
+func (i instrumentedDB) AssignCartToSession(cartID, sessionToken string) error {
+	start := time.Now()
+
+	return i.m.record("AssignCartToSession", start, i.db.AssignCartToSession(cartID, sessionToken))
+}
+
+type instrumentedQuerier struct {
+	db CartQuerier
+	m  *Metrics
+}
+
+func (i instrumentedQuerier) QueryCarts(query CartQuery) (CartPage, error) {
+	start := time.Now()
+	page, err := i.db.QueryCarts(query)
+
+	return page, i.m.record("QueryCarts", start, err)
+}
+
+type instrumentedLister struct {
+	db UserCartLister
+	m  *Metrics
+}
+
+func (i instrumentedLister) ListCartsForUser(userID string) ([]Cart, error) {
+	start := time.Now()
+	carts, err := i.db.ListCartsForUser(userID)
+
+	return carts, i.m.record("ListCartsForUser", start, err)
+}
@@ -0,0 +1,162 @@
+package kaimono
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	mock := newMockBackend()
+	metrics := NewMetrics()
+
+	svc, err := NewService(metrics.InstrumentDB(mock), mock, mock, nil, WithMetrics(metrics))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/cart/", svc.Router("/cart"))
+	mux.Handle("/admin/", svc.AdminRouter("/admin"))
+	mux.Handle("/metrics", metrics)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	user, admin := mock.sessions[0], mock.sessions[1]
+
+	created := CreateCartResponse{}
+	if code := doJSONRequest(t, http.MethodPost, srv.URL+"/cart/", user, nil, &created); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	cart := created.Data
+	cart.Items = []CartItem{
+		{ID: "apple", Quantity: 2, Price: Price{Currency: "EUR", Value: 1}},
+		{ID: "pear", Quantity: 1, Price: Price{Currency: "EUR", Value: 2}},
+	}
+
+	if code := doJSONRequest(t, http.MethodPut, srv.URL+"/cart/", user, UpdateCartRequest{Data: cart}, nil); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if code := doJSONRequest(t, http.MethodGet, srv.URL+"/admin/missing", admin, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("got code %d, want %d", code, http.StatusNotFound)
+	}
+
+	if code := doJSONRequest(t, http.MethodGet, srv.URL+"/admin/", admin, nil, nil); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	// labeled with the operation authorized, or updated, rather than created.
+	job := CreateJobRequest{Data: JobRequest{Type: DeleteCartsJob, Filter: "limit=10"}}
+	if code := doJSONRequest(t, http.MethodPost, srv.URL+"/admin/jobs", admin, job, nil); code != http.StatusBadRequest {
+		t.Fatalf("got code %d, want %d", code, http.StatusBadRequest)
+	}
+
+	if code := doJSONRequest(t, http.MethodPost, srv.URL+"/cart/carts/move", user, nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("got code %d, want %d", code, http.StatusBadRequest)
+	}
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("could not get metrics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body := &strings.Builder{}
+	if _, err := metrics.WriteTo(body); err != nil {
+		t.Fatalf("could not write metrics: %v", err)
+	}
+
+	if got := resp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Fatalf("got content type %q", got)
+	}
+
+	want := []string{
+		`kaimono_http_requests_total{route="/cart",method="POST",code="201",operation="create"} 1`,
+		`kaimono_http_requests_total{route="/cart",method="PUT",code="200",operation="update"} 1`,
+		`kaimono_http_requests_total{route="/admin/{id}",method="GET",code="404",operation="read"} 1`,
+		`kaimono_http_requests_total{route="/admin",method="GET",code="200",operation="list"} 1`,
+		`kaimono_http_requests_total{route="/admin/jobs",method="POST",code="400",operation="bulk-delete"} 1`,
+		`kaimono_http_requests_total{route="/cart/carts/move",method="POST",code="400",operation="update"} 1`,
+		`kaimono_http_request_duration_seconds_count{route="/cart",method="PUT",operation="update"} 1`,
+		`kaimono_db_calls_total{method="CreateCartForSession",result="ok"} 1`,
+		`kaimono_db_calls_total{method="LookupCart",result="error"} 1`,
+		`kaimono_db_calls_total{method="QueryCarts",result="ok"} 1`,
+		`kaimono_db_call_duration_seconds_bucket{method="UpdateCart",le="+Inf"}`,
+		"kaimono_active_carts 1",
+		"kaimono_items_per_cart 3",
+	}
+
+	for _, line := range want {
+		if !strings.Contains(body.String(), line) {
+			t.Fatalf("missing %q in:\n%s", line, body.String())
+		}
+	}
+}
+
+func TestMetricsForgetDeletedCarts(t *testing.T) {
+	metrics := NewMetrics()
+	items := []CartItem{{ID: "apple", Quantity: 1}}
+
+	metrics.observe(CartEvent{Type: CartUpdated, CartID: "deleted", Cart: Cart{ID: "deleted", Items: items}})
+	metrics.observe(CartEvent{Type: CartUpdated, CartID: "emptied", Cart: Cart{ID: "emptied", Items: items}})
+	metrics.observe(CartEvent{Type: CartDeleted, CartID: "deleted"})
+	metrics.observe(CartEvent{Type: CartUpdated, CartID: "emptied", Cart: Cart{ID: "emptied"}})
+
+	if len(metrics.cartItems) != 0 {
+		t.Fatalf("expected the carts to be forgotten, got %v", metrics.cartItems)
+	}
+}
+
+func TestInstrumentDB(t *testing.T) {
+	mock := newMockBackend()
+
+	cases := []struct {
+		name              string
+		db                DB
+		canQuery, canList bool
+	}{
+		{"both", mock, true, true},
+		{"querier", struct {
+			DB
+			CartQuerier
+		}{mock, mock}, true, false},
+		{"lister", struct {
+			DB
+			UserCartLister
+		}{mock, mock}, false, true},
+		{"neither", struct{ DB }{mock}, false, false},
+	}
+
+	for _, c := range cases {
+		db := NewMetrics().InstrumentDB(c.db)
+
+		if _, ok := db.(CartQuerier); ok != c.canQuery {
+			t.Fatalf("%s: got CartQuerier %v, want %v", c.name, ok, c.canQuery)
+		}
+
+		if _, ok := db.(UserCartLister); ok != c.canList {
+			t.Fatalf("%s: got UserCartLister %v, want %v", c.name, ok, c.canList)
+		}
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{`a"b`, `a\"b`},
+		{`a\b`, `a\\b`},
+		{"a\nb", `a\nb`},
+	}
+
+	for _, c := range cases {
+		if got := escapeLabel(c.in); got != c.want {
+			t.Fatalf("escapeLabel(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package kaimono
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// The exported methods below are the Service's mutation API, used by its
+// HTTP handlers and by other transports, e.g: grpcserver. They check the
+// session's share permission, validate, store and publish changes, while
+// authorizing admin operations is left to the caller.
+
+// SessionCart returns the session's Cart, if the session can still view it.
+func (svc *Service) SessionCart(ctx context.Context, usrCtx UserContext) (Cart, error) {
+	cart, err := svc.store(ctx).LookupCartForSession(usrCtx.SessionToken)
+	if err != nil {
+		return cart, err
+	}
+
+	return cart, svc.checkAccess(usrCtx.SessionToken, cart.ID, ViewPermission)
+}
+
+// CreateSessionCart creates the session's Cart, owned by the session's user
+// if any. It returns ErrAlreadyExists if the session has a Cart.
+func (svc *Service) CreateSessionCart(ctx context.Context, usrCtx UserContext) (Cart, error) {
+	return svc.createCartForSession(ctx, usrCtx.SessionToken, usrCtx.UserID)
+}
+
+// UpdateSessionCart replaces the session's Cart, keeping its owner and
+// creation time. It returns ErrInvalidID if the IDs don't match, ErrReadOnly
+// if the Cart was shared with the session as view-only, and a
+// ValidationError if the Cart is invalid.
+func (svc *Service) UpdateSessionCart(ctx context.Context, usrCtx UserContext, cart Cart) (Cart, error) {
+	found, err := svc.store(ctx).LookupCartForSession(usrCtx.SessionToken)
+	if err != nil {
+		return cart, err
+	}
+
+	if cart.ID != found.ID {
+		return cart, ErrInvalidID
+	}
+
+	if err := svc.checkAccess(usrCtx.SessionToken, found.ID, EditPermission); err != nil {
+		return cart, err
+	}
+
+	// the owner and creation time can't be changed through the session.
+	cart.UserID = found.UserID
+	cart.CreatedAt = found.CreatedAt
+
+	return cart, svc.updateCart(ctx, &cart)
+}
+
+// DeleteSessionCart deletes the session's Cart. It returns ErrReadOnly if
+// the Cart was shared with the session.
+func (svc *Service) DeleteSessionCart(ctx context.Context, usrCtx UserContext) error {
+	found, err := svc.store(ctx).LookupCartForSession(usrCtx.SessionToken)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.checkOwner(usrCtx.SessionToken, found.ID); err != nil {
+		return err
+	}
+
+	return svc.deleteCart(ctx, found.ID)
+}
+
+// LookupCart returns the Cart with the ID.
+func (svc *Service) LookupCart(ctx context.Context, cartID string) (Cart, error) {
+	return svc.store(ctx).LookupCart(cartID)
+}
+
+// CreateCart creates a Cart without assigning it to a session.
+func (svc *Service) CreateCart(ctx context.Context) (Cart, error) {
+	return svc.createCart(ctx)
+}
+
+// UpdateCart replaces the stored Cart with the same ID, keeping its creation
+// time, and its owner, kind and name unless set. Callers authorize owner
+// changes, see TransferOp. It returns a ValidationError if the Cart is
+// invalid.
+func (svc *Service) UpdateCart(ctx context.Context, cart Cart) (Cart, error) {
+	found, err := svc.store(ctx).LookupCart(cart.ID)
+	if err != nil {
+		return cart, err
+	}
+
+	cart.CreatedAt = found.CreatedAt
+
+	if cart.UserID == "" {
+		cart.UserID = found.UserID
+	}
+
+	if cart.Kind == "" {
+		cart.Kind = found.Kind
+	}
+
+	if cart.Name == "" {
+		cart.Name = found.Name
+	}
+
+	return cart, svc.updateCart(ctx, &cart)
+}
+
+// DeleteCart deletes the Cart with the ID.
+func (svc *Service) DeleteCart(ctx context.Context, cartID string) error {
+	return svc.deleteCart(ctx, cartID)
+}
+
+// AssignCart assigns the Cart to the session, moving the items saved for
+// later in the session's previous Cart to it.
+func (svc *Service) AssignCart(ctx context.Context, cartID, sessionToken string) error {
+	return svc.assignCartToSession(ctx, cartID, sessionToken)
+}
+
+// The methods below are the Service's mutation path: every change made to
+// a Cart goes through them so subscribers are notified.
+
+// createCartForSession creates the session's Cart, owned by the user if
+// the session belongs to one.
+func (svc *Service) createCartForSession(ctx context.Context, sessionToken, userID string) (Cart, error) {
+	cart, err := svc.store(ctx).CreateCartForSession(sessionToken)
+	if err != nil {
+		return cart, err
+	}
+
+	cart.UserID = userID
+
+	return cart, svc.initCart(ctx, &cart)
+}
+
+func (svc *Service) createCart(ctx context.Context) (Cart, error) {
+	cart, err := svc.store(ctx).CreateCart()
+	if err != nil {
+		return cart, err
+	}
+
+	return cart, svc.initCart(ctx, &cart)
+}
+
+// createUserCart creates a Cart owned by the user, without assigning it
+// to a session.
+func (svc *Service) createUserCart(ctx context.Context, userID, name string, kind CartKind) (Cart, error) {
+	cart, err := svc.store(ctx).CreateCart()
+	if err != nil {
+		return cart, err
+	}
+
+	cart.UserID = userID
+	cart.Name = name
+	cart.Kind = kind
+
+	return cart, svc.initCart(ctx, &cart)
+}
+
+// initCart stores the fields set by the Service on a newly created Cart.
+func (svc *Service) initCart(ctx context.Context, cart *Cart) error {
+	cart.Touch(time.Now().UTC())
+
+	if err := svc.store(ctx).UpdateCart(*cart); err != nil {
+		return err
+	}
+
+	svc.events.publish(CartCreated, *cart)
+
+	return nil
+}
+
+// updateCart validates the Cart, sets its UpdatedAt and stores it.
+func (svc *Service) updateCart(ctx context.Context, cart *Cart) error {
+	// stateless carts are only trusted with the Catalog's prices.
+	if _, stateless := ctx.Value(requestDBKey{}).(DB); stateless {
+		if err := svc.repriceStatelessCart(cart); err != nil {
+			return err
+		}
+	}
+
+	if err := svc.validateCart(*cart); err != nil {
+		return err
+	}
+
+	cart.Touch(time.Now().UTC())
+
+	if err := svc.store(ctx).UpdateCart(*cart); err != nil {
+		return err
+	}
+
+	svc.events.publish(CartUpdated, *cart)
+
+	return nil
+}
+
+func (svc *Service) deleteCart(ctx context.Context, cartID string) error {
+	if err := svc.store(ctx).DeleteCart(cartID); err != nil {
+		return err
+	}
+
+	svc.events.publish(CartDeleted, Cart{ID: cartID})
+
+	return nil
+}
+
+// assignCartToSession assigns the Cart to the session, carrying over the
+// items saved for later in the session's previous Cart so they aren't lost
+// when the session migrates, e.g: on login.
+func (svc *Service) assignCartToSession(ctx context.Context, cartID, sessionToken string) error {
+	previous, lookupErr := svc.store(ctx).LookupCartForSession(sessionToken)
+	if lookupErr != nil && !errors.Is(lookupErr, ErrCartNotFound) {
+		return lookupErr
+	}
+
+	if err := svc.store(ctx).AssignCartToSession(cartID, sessionToken); err != nil {
+		return err
+	}
+
+	if lookupErr != nil || previous.ID == cartID || len(previous.Saved) == 0 {
+		return nil
+	}
+
+	found, err := svc.store(ctx).LookupCart(cartID)
+	if err != nil {
+		return err
+	}
+
+	cart := found.Clone()
+	for _, item := range previous.Saved {
+		cart.saveItem(item)
+	}
+
+	if err := svc.updateCart(ctx, &cart); err != nil {
+		return err
+	}
+
+	previous = previous.Clone()
+	previous.Saved = nil
+
+	return svc.updateCart(ctx, &previous)
+}
@@ -0,0 +1,166 @@
+// Package notify provides implementations of kaimono.Notifier, delivering
+// abandoned cart notifications by email or to a file.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+const filePerm = 0o600
+
+// File appends each notification as a JSON line to a file, e.g: for a
+// separate process to deliver them.
+type File struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFile returns a File notifier writing to path, which is created if it
+// doesn't exist.
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Notify(_ context.Context, notification kaimono.Notification) error {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("could not encode notification: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, filePerm)
+	if err != nil {
+		return fmt.Errorf("could not open file: %w", err)
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("could not write notification: %w", err)
+	}
+
+	return file.Close()
+}
+
+// RecipientFunc returns the email address of the user.
+type RecipientFunc func(ctx context.Context, userID string) (string, error)
+
+// SMTP emails the notifications through an SMTP server, e.g: a local relay.
+type SMTP struct {
+	addr      string
+	from      string
+	auth      smtp.Auth
+	recipient RecipientFunc
+	subject   string
+	body      *template.Template
+}
+
+// SMTPOption configures optional SMTP behaviour.
+type SMTPOption func(s *SMTP)
+
+// WithAuth sets the authentication used with the server, none by default.
+func WithAuth(auth smtp.Auth) SMTPOption {
+	return func(s *SMTP) {
+		s.auth = auth
+	}
+}
+
+// WithSubject sets the subject of the emails.
+func WithSubject(subject string) SMTPOption {
+	return func(s *SMTP) {
+		s.subject = subject
+	}
+}
+
+// WithBody sets the template of the email body, executed with the
+// kaimono.Notification.
+func WithBody(body *template.Template) SMTPOption {
+	return func(s *SMTP) {
+		s.body = body
+	}
+}
+
+var defaultBody = template.Must(template.New("body").Parse(
+	`You left {{ len .Cart.Items }} item(s) in your cart.
+
+Pick up where you left off: {{ .RestoreURL }}
+`))
+
+// NewSMTP returns an SMTP notifier sending from the given address to the
+// address returned by recipient, through the server at addr (host:port).
+func NewSMTP(addr, from string, recipient RecipientFunc, opts ...SMTPOption) (*SMTP, error) {
+	if addr == "" || from == "" || recipient == nil {
+		return nil, errors.New("addr, from and recipient are required")
+	}
+
+	s := &SMTP{
+		addr:      addr,
+		from:      from,
+		recipient: recipient,
+		subject:   "Your cart is waiting",
+		body:      defaultBody,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+func (s *SMTP) Notify(ctx context.Context, notification kaimono.Notification) error {
+	to, err := s.recipient(ctx, notification.UserID)
+	if err != nil {
+		return fmt.Errorf("could not get recipient: %w", err)
+	}
+
+	if strings.ContainsAny(to, "\r\n") {
+		return fmt.Errorf("invalid recipient '%q'", to)
+	}
+
+	msg, err := s.message(to, notification)
+	if err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(s.addr, s.auth, s.from, []string{to}, msg); err != nil {
+		return fmt.Errorf("could not send email: %w", err)
+	}
+
+	return nil
+}
+
+// message builds the RFC 5322 message.
+func (s *SMTP) message(to string, notification kaimono.Notification) ([]byte, error) {
+	body := &bytes.Buffer{}
+	if err := s.body.Execute(body, notification); err != nil {
+		return nil, fmt.Errorf("could not render body: %w", err)
+	}
+
+	headers := []string{
+		"From: " + s.from,
+		"To: " + to,
+		"Subject: " + s.subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+	}
+
+	msg := strings.Join(headers, "\r\n") + "\r\n\r\n" +
+		strings.ReplaceAll(body.String(), "\n", "\r\n")
+
+	return []byte(msg), nil
+}
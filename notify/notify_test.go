@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	notifier := NewFile(path)
+
+	for _, userID := range []string{"user-a", "user-b"} {
+		notification := kaimono.Notification{UserID: userID, RestoreURL: "https://shop/recover/token"}
+		if err := notifier.Notify(context.Background(), notification); err != nil {
+			t.Fatalf("could not notify: %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open file: %v", err)
+	}
+
+	defer file.Close()
+
+	users := []string{}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		notification := kaimono.Notification{}
+		if err := json.Unmarshal(scanner.Bytes(), &notification); err != nil {
+			t.Fatalf("could not decode line: %v", err)
+		}
+
+		users = append(users, notification.UserID)
+	}
+
+	if strings.Join(users, ",") != "user-a,user-b" {
+		t.Fatalf("unexpected notifications: %v", users)
+	}
+}
+
+func TestSMTPMessage(t *testing.T) {
+	recipient := func(context.Context, string) (string, error) { return "buyer@example.com", nil }
+
+	notifier, err := NewSMTP("localhost:25", "shop@example.com", recipient, WithSubject("Still thinking?"))
+	if err != nil {
+		t.Fatalf("could not create notifier: %v", err)
+	}
+
+	notification := kaimono.Notification{
+		Cart:       kaimono.Cart{Items: []kaimono.CartItem{{ID: "apple", Quantity: 1}}},
+		RestoreURL: "https://shop/recover/token",
+	}
+
+	msg, err := notifier.message("buyer@example.com", notification)
+	if err != nil {
+		t.Fatalf("could not build message: %v", err)
+	}
+
+	for _, want := range []string{
+		"To: buyer@example.com\r\n",
+		"Subject: Still thinking?\r\n",
+		"You left 1 item(s) in your cart.\r\n",
+		"https://shop/recover/token",
+	} {
+		if !strings.Contains(string(msg), want) {
+			t.Fatalf("message is missing %q:\n%s", want, msg)
+		}
+	}
+
+	if _, err := NewSMTP("", "shop@example.com", recipient); err == nil {
+		t.Fatalf("expected an error without an address")
+	}
+}
@@ -0,0 +1,528 @@
+package kaimono
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const openAPIVersion = "3.0.3"
+
+// routeDoc documents a single route, status codes follow the handler's
+// doc comment. The first status code is the success response, which
+// returns the response type (if any) with the given content type, JSON
+// by default. The rest return the Service's error response. Query parameters are
+// documented as optional strings. Idempotent routes accept the Idempotency-Key
+// header, and the 409 and 422 status codes it can cause are added.
+type routeDoc struct {
+	method      string
+	path        string
+	id          string
+	summary     string
+	request     any
+	response    any
+	contentType string
+	query       []string
+	codes       []int
+	idempotent  bool
+}
+
+func standardRouteDocs() []routeDoc {
+	return []routeDoc{
+		{
+			method: http.MethodGet, path: "/", id: "getCart",
+			summary:  "Return the Cart associated to the current user's session.",
+			response: GetCartResponse{},
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodPost, path: "/", id: "createCart", idempotent: true,
+			summary:  "Create a new Cart for the current session.",
+			response: CreateCartResponse{},
+			codes:    []int{http.StatusCreated, http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPut, path: "/", id: "updateCart",
+			summary:  "Update the Cart for the current session.",
+			request:  UpdateCartRequest{},
+			response: UpdateCartResponse{},
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodDelete, path: "/", id: "deleteCart",
+			summary: "Delete the Cart for the current session.",
+			codes: []int{
+				http.StatusNoContent, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodGet, path: "/events", id: "streamCartEvents",
+			summary:     "Stream the changes made to the current session's Cart as Server-Sent Events.",
+			response:    CartEvent{},
+			contentType: "text/event-stream",
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodGet, path: "/live", id: "liveCart",
+			summary: "Upgrade to a WebSocket to edit the current session's Cart together with the sessions sharing it.",
+			codes: []int{
+				http.StatusSwitchingProtocols, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodPost, path: "/shares", id: "createShare",
+			summary:  "Create a share link for the current session's Cart.",
+			request:  CreateShareRequest{},
+			response: CreateShareResponse{},
+			codes: []int{
+				http.StatusCreated, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodGet, path: "/shares", id: "listShares",
+			summary:  "List the share links of the current session's Cart.",
+			response: ListSharesResponse{},
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodDelete, path: "/shares/{token}", id: "revokeShare",
+			summary: "Revoke a share link of the current session's Cart.",
+			codes: []int{
+				http.StatusNoContent, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodGet, path: "/shared/{token}", id: "getSharedCart",
+			summary:  "Return the Cart a share link grants access to.",
+			response: SharedCartResponse{},
+			codes:    []int{http.StatusOK, http.StatusNotFound, http.StatusGone, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/shared/{token}/attach", id: "attachSharedCart",
+			summary:  "Assign the shared Cart to the current session with the link's permission.",
+			response: SharedCartResponse{},
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusNotFound,
+				http.StatusGone, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodGet, path: "/carts", id: "listUserCarts",
+			summary:  "List the carts owned by the current user.",
+			response: ListUserCartsResponse{},
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusUnauthorized,
+				http.StatusInternalServerError, http.StatusNotImplemented,
+			},
+		},
+		{
+			method: http.MethodPost, path: "/carts", id: "createUserCart", idempotent: true,
+			summary:  "Create a named Cart owned by the current user.",
+			request:  CreateUserCartRequest{},
+			response: CreateUserCartResponse{},
+			codes: []int{
+				http.StatusCreated, http.StatusBadRequest, http.StatusUnauthorized,
+				http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodPost, path: "/carts/{id}/activate", id: "activateUserCart",
+			summary:  "Make the user's Cart the current session's Cart.",
+			response: ActivateUserCartResponse{},
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusUnauthorized,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodPost, path: "/carts/move", id: "moveItem", idempotent: true,
+			summary:  "Move an item between two of the user's carts.",
+			request:  MoveItemRequest{},
+			response: MoveItemResponse{},
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodPost, path: "/items/{id}/save", id: "saveForLater", idempotent: true,
+			summary:  "Move an item of the current session's Cart to its saved items.",
+			response: SaveItemResponse{},
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodPost, path: "/saved/{id}/restore", id: "restoreSaved", idempotent: true,
+			summary:  "Move a saved item back to the current session's Cart, re-pricing it.",
+			response: RestoreItemResponse{},
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodPost, path: "/recover/{token}", id: "restoreCart",
+			summary:  "Assign the Cart of a recovery link to the current session.",
+			response: RestoreCartResponse{},
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusNotFound,
+				http.StatusGone, http.StatusInternalServerError,
+			},
+		},
+	}
+}
+
+func adminRouteDocs() []routeDoc {
+	return []routeDoc{
+		{
+			method: http.MethodGet, path: "/", id: "listCarts",
+			summary:  "List the carts matching the query, paginated by cursor.",
+			response: ListCartsResponse{},
+			query:    cartQueryParams,
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusInternalServerError, http.StatusNotImplemented,
+			},
+		},
+		{
+			method: http.MethodGet, path: "/{id}", id: "getCartWithID",
+			summary:  "Return the Cart with the supplied ID.",
+			response: GetCartByIDResponse{},
+			codes:    []int{http.StatusOK, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/", id: "createCartWithoutSession", idempotent: true,
+			summary:  "Create an empty Cart without assigning it to a session.",
+			response: CreateCartResponse{},
+			codes:    []int{http.StatusCreated, http.StatusForbidden, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPut, path: "/{id}", id: "updateCartWithID",
+			summary:  "Update the Cart with the supplied ID.",
+			request:  UpdateCartRequest{},
+			response: UpdateCartResponse{},
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodDelete, path: "/{id}", id: "deleteCartWithID",
+			summary: "Delete the Cart with the supplied ID.",
+			codes:   []int{http.StatusNoContent, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/{id}/assign", id: "assignCartWithID",
+			summary: "Assign the Cart with the supplied ID to a session.",
+			request: AssignCartRequest{},
+			codes: []int{
+				http.StatusNoContent, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusNotFound, http.StatusInternalServerError,
+			},
+		},
+		{
+			method: http.MethodPost, path: "/jobs", id: "createJob",
+			summary:  "Start a bulk operation over the carts matching a filter.",
+			request:  CreateJobRequest{},
+			response: CreateJobResponse{},
+			codes: []int{
+				http.StatusAccepted, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusInternalServerError, http.StatusNotImplemented,
+			},
+		},
+		{
+			method: http.MethodGet, path: "/jobs/{id}", id: "getJob",
+			summary:  "Return the progress of a bulk operation.",
+			response: GetJobResponse{},
+			codes:    []int{http.StatusOK, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError},
+		},
+		{
+			method: http.MethodPost, path: "/{id}/conversion", id: "convertCartWithID", idempotent: true,
+			summary: "Report the Cart as converted to the analytics.",
+			codes: []int{
+				http.StatusNoContent, http.StatusForbidden, http.StatusNotFound,
+				http.StatusInternalServerError, http.StatusNotImplemented,
+			},
+		},
+		{
+			method: http.MethodGet, path: "/analytics", id: "getAnalytics",
+			summary:  "Return the cart analytics between two times, as CSV if format is csv.",
+			response: AnalyticsReportResponse{},
+			query:    []string{"from", "to", "format"},
+			codes: []int{
+				http.StatusOK, http.StatusBadRequest, http.StatusForbidden,
+				http.StatusInternalServerError, http.StatusNotImplemented,
+			},
+		},
+	}
+}
+
+// OpenAPI returns the OpenAPI 3 document describing the routes registered
+// by Router(base) and AdminRouter(adminBase).
+func (svc *Service) OpenAPI(base, adminBase string) map[string]any {
+	schemas := schemaRegistry{}
+	paths := map[string]map[string]any{}
+
+	errorContent := content(ProblemContentType, schemas.ref(reflect.TypeOf(Problem{})))
+	if !svc.problemDetails {
+		errorContent["application/json"] = map[string]any{"schema": schemas.ref(reflect.TypeOf(ErrorResponse{}))}
+	}
+
+	add := func(base, tag string, docs []routeDoc) {
+		for _, doc := range docs {
+			path := joinRoutePath(base, doc.path)
+			if paths[path] == nil {
+				paths[path] = map[string]any{}
+			}
+
+			if svc.rateLimits != nil {
+				doc.codes = append(slices.Clone(doc.codes), http.StatusTooManyRequests)
+			}
+
+			paths[path][strings.ToLower(doc.method)] = doc.operation(tag, schemas, errorContent)
+		}
+	}
+
+	add(base, "standard", standardRouteDocs())
+	add(adminBase, "admin", adminRouteDocs())
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":   "kaimono",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+		},
+	}
+}
+
+// OpenAPIHandler serves the document returned by OpenAPI as JSON.
+func (svc *Service) OpenAPIHandler(base, adminBase string) http.HandlerFunc {
+	doc := svc.OpenAPI(base, adminBase)
+
+	return func(w http.ResponseWriter, _ *http.Request) {
+		svc.json(writeResponse(w, http.StatusOK, doc))
+	}
+}
+
+func (doc routeDoc) operation(tag string, schemas schemaRegistry, errorContent map[string]any) map[string]any {
+	responses := map[string]any{}
+
+	for k, code := range doc.codes {
+		resp := map[string]any{"description": http.StatusText(code)}
+
+		switch {
+		case k > 0:
+			resp["content"] = errorContent
+		case doc.response != nil:
+			resp["content"] = content(doc.contentType, schemas.ref(reflect.TypeOf(doc.response)))
+		}
+
+		responses[strconv.Itoa(code)] = resp
+	}
+
+	if doc.idempotent {
+		for _, code := range []int{http.StatusConflict, http.StatusUnprocessableEntity} {
+			responses[strconv.Itoa(code)] = map[string]any{
+				"description": http.StatusText(code),
+				"content":     errorContent,
+			}
+		}
+	}
+
+	op := map[string]any{
+		"operationId": doc.id,
+		"summary":     doc.summary,
+		"tags":        []string{tag},
+		"responses":   responses,
+	}
+
+	params := pathParams(doc.path)
+	for _, name := range doc.query {
+		params = append(params, map[string]any{
+			"name":   name,
+			"in":     "query",
+			"schema": map[string]any{"type": "string"},
+		})
+	}
+
+	if doc.idempotent {
+		params = append(params, map[string]any{
+			"name":   IdempotencyKeyHeader,
+			"in":     "header",
+			"schema": map[string]any{"type": "string", "maxLength": maxIdempotencyKeyLength},
+		})
+	}
+
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if doc.request != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  jsonContent(schemas.ref(reflect.TypeOf(doc.request))),
+		}
+	}
+
+	return op
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return content("", schema)
+}
+
+func content(contentType string, schema map[string]any) map[string]any {
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	return map[string]any{
+		contentType: map[string]any{"schema": schema},
+	}
+}
+
+var pathParamRE = regexp.MustCompile(`{([^}]+)}`)
+
+func pathParams(path string) []map[string]any {
+	params := []map[string]any{}
+
+	for _, match := range pathParamRE.FindAllStringSubmatch(path, -1) {
+		params = append(params, map[string]any{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+
+	return params
+}
+
+// joinRoutePath joins the paths the same way chi does when a router is
+// mounted with Route(base, ...).
+func joinRoutePath(base, path string) string {
+	return strings.TrimSuffix(base, "/") + path
+}
+
+// schemaRegistry collects the component schemas, keyed by name.
+type schemaRegistry map[string]any
+
+var typeNameRE = regexp.MustCompile(`[\w./-]+\.`)
+
+// ref returns a reference to the schema for t, registering it (and every
+// struct it references) if needed.
+func (reg schemaRegistry) ref(t reflect.Type) map[string]any {
+	name := typeNameRE.ReplaceAllString(t.Name(), "")
+	name = strings.NewReplacer("[", "", "]", "", ",", "").Replace(name)
+
+	if _, found := reg[name]; !found {
+		// register first so recursive types terminate.
+		reg[name] = map[string]any{}
+		reg[name] = reg.object(t)
+	}
+
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func (reg schemaRegistry) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+
+	for k := range t.NumField() {
+		field := t.Field(k)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		props[name] = reg.schema(field.Type)
+	}
+
+	return map[string]any{"type": "object", "properties": props}
+}
+
+// enumValues lists the allowed values of the enum-like types.
+func enumValues(t reflect.Type) []string {
+	switch t {
+	case reflect.TypeOf(DiscountType("")):
+		return []string{string(PercentageDiscount), string(FixedAmountDiscount)}
+	case reflect.TypeOf(CartKind("")):
+		return []string{string(ShoppingCart), string(WishlistCart)}
+	case reflect.TypeOf(SharePermission("")):
+		return []string{string(ViewPermission), string(EditPermission)}
+	case reflect.TypeOf(JobType("")):
+		return []string{string(DeleteCartsJob), string(RemoveItemJob), string(ApplyDiscountJob), string(RevokeDiscountJob)}
+	case reflect.TypeOf(JobStatus("")):
+		return []string{string(JobRunning), string(JobCompleted), string(JobFailed)}
+	}
+
+	return nil
+}
+
+func (reg schemaRegistry) schema(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	if values := enumValues(t); values != nil {
+		return map[string]any{"type": "string", "enum": values}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := reg.schema(t.Elem())
+		schema["nullable"] = true
+
+		return schema
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": reg.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": reg.schema(t.Elem())}
+	case reflect.Struct:
+		return reg.ref(t)
+	default:
+		return map[string]any{"nullable": true}
+	}
+}
@@ -0,0 +1,113 @@
+package kaimono
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	const (
+		base      = "/cart"
+		adminBase = "/admin/cart"
+	)
+
+	doc := svc.OpenAPI(base, adminBase)
+
+	paths, ok := doc["paths"].(map[string]map[string]any)
+	if !ok {
+		t.Fatalf("unexpected paths type: %T", doc["paths"])
+	}
+
+	documented := map[string]bool{}
+
+	for path, ops := range paths {
+		for method := range ops {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	registered := map[string]bool{}
+	walk := func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		registered[method+" "+route] = true
+		return nil
+	}
+
+	for _, router := range []*chi.Mux{svc.Router(base), svc.AdminRouter(adminBase)} {
+		if err := chi.Walk(router, walk); err != nil {
+			t.Fatalf("could not walk routes: %v", err)
+		}
+	}
+
+	for route := range registered {
+		if !documented[route] {
+			t.Errorf("route %s is not documented", route)
+		}
+	}
+
+	for route := range documented {
+		if !registered[route] {
+			t.Errorf("route %s is documented but not registered", route)
+		}
+	}
+}
+
+func TestOpenAPIReferencesResolve(t *testing.T) {
+	tests := []struct {
+		label     string
+		opts      []Option
+		errSchema string
+	}{
+		{"error envelope", nil, "ErrorResponse"},
+		{"problem details", []Option{WithProblemDetails()}, "Problem"},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			svc, err := NewService(nil, nil, nil, nil, c.opts...)
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+
+			data, err := json.Marshal(svc.OpenAPI("/cart", "/admin/cart"))
+			if err != nil {
+				t.Fatalf("could not encode document: %v", err)
+			}
+
+			doc := struct {
+				Components struct {
+					Schemas map[string]json.RawMessage `json:"schemas"`
+				} `json:"components"`
+			}{}
+
+			if err := json.Unmarshal(data, &doc); err != nil {
+				t.Fatalf("could not decode document: %v", err)
+			}
+
+			for _, name := range []string{"Cart", "CartItem", "Discount", "Price", c.errSchema, "ResponseCart", "RequestCart"} {
+				if _, found := doc.Components.Schemas[name]; !found {
+					t.Errorf("schema %s is missing", name)
+				}
+			}
+
+			const prefix = `"#/components/schemas/`
+
+			for _, ref := range strings.Split(string(data), prefix)[1:] {
+				name, _, _ := strings.Cut(ref, `"`)
+				if _, found := doc.Components.Schemas[name]; !found {
+					t.Errorf("reference to undefined schema %s", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+const defaultReloadInterval = 5 * time.Second
+
+var ErrOwnerRulesNeedDB = errors.New("a DB is required to check the owner of carts")
+
+// RoleFunc returns extra roles of the user, e.g: read from a header set by
+// a gateway.
+type RoleFunc func(req *http.Request, usrCtx kaimono.UserContext) []string
+
+// Decision explains why an operation was allowed or denied.
+type Decision struct {
+	Allowed bool     `json:"allowed"`
+	UserID  string   `json:"user-id"`
+	Roles   []string `json:"roles"`
+
+	// Role and Rule are set to the ones allowing the operation.
+	Role string `json:"role,omitempty"`
+	Rule *Rule  `json:"rule,omitempty"`
+
+	Reason string `json:"reason"`
+}
+
+// Authorizer implements kaimono.Authorizer, allowing the operations granted
+// by the roles of the requesting user.
+type Authorizer struct {
+	fetcher kaimono.UserContextFetcher
+	db      kaimono.DB
+	roles   RoleFunc
+	logger  *slog.Logger
+	explain bool
+	policy  atomic.Pointer[Policy]
+
+	// used when loaded from a file.
+	path           string
+	reloadInterval time.Duration
+	mu             sync.Mutex
+	modTime        time.Time
+	lastCheck      time.Time
+}
+
+// Option configures optional Authorizer behaviour.
+type Option func(a *Authorizer)
+
+// WithRoles adds the roles returned by fn to the user's roles.
+func WithRoles(fn RoleFunc) Option {
+	return func(a *Authorizer) {
+		a.roles = fn
+	}
+}
+
+// WithLogger sets the logger used for reloads and explained decisions.
+func WithLogger(logger *slog.Logger) Option {
+	return func(a *Authorizer) {
+		a.logger = logger
+	}
+}
+
+// WithExplain logs the decision of every denied operation, to debug the
+// policy.
+func WithExplain() Option {
+	return func(a *Authorizer) {
+		a.explain = true
+	}
+}
+
+// WithReloadInterval sets how often the policy file is checked for changes,
+// defaults to 5 seconds. A zero interval disables reloads.
+func WithReloadInterval(d time.Duration) Option {
+	return func(a *Authorizer) {
+		a.reloadInterval = d
+	}
+}
+
+// New returns an Authorizer evaluating the policy. The user is read with
+// fetcher, and db is used to check the owner of carts, it can be nil if the
+// policy has no owner rules.
+func New(policy Policy, fetcher kaimono.UserContextFetcher, db kaimono.DB, opts ...Option) (*Authorizer, error) {
+	a := &Authorizer{
+		fetcher:        fetcher,
+		db:             db,
+		logger:         slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		reloadInterval: defaultReloadInterval,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if err := a.SetPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Load returns an Authorizer evaluating the JSON policy at path. The file is
+// reloaded when it changes, if the new policy is invalid the previous one is
+// kept.
+func Load(path string, fetcher kaimono.UserContextFetcher, db kaimono.DB, opts ...Option) (*Authorizer, error) {
+	a, err := New(Policy{}, fetcher, db, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	a.path = path
+
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// SetPolicy replaces the policy, if valid.
+func (a *Authorizer) SetPolicy(policy Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	if a.db == nil && policy.hasOwnerRules() {
+		return ErrOwnerRulesNeedDB
+	}
+
+	a.policy.Store(&policy)
+
+	return nil
+}
+
+// Policy returns the policy being evaluated.
+func (a *Authorizer) Policy() Policy {
+	return *a.policy.Load()
+}
+
+// Reload reads the policy file again.
+func (a *Authorizer) Reload() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.reload()
+}
+
+func (a *Authorizer) reload() error {
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return fmt.Errorf("could not read policy: %w", err)
+	}
+
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return fmt.Errorf("could not read policy: %w", err)
+	}
+
+	policy, err := Parse(data)
+	if err != nil {
+		return err
+	}
+
+	if err := a.SetPolicy(policy); err != nil {
+		return err
+	}
+
+	a.modTime = info.ModTime()
+
+	return nil
+}
+
+// reloadIfChanged reloads the policy file if it changed, checking it at
+// most once per reloadInterval.
+func (a *Authorizer) reloadIfChanged(now time.Time) {
+	if a.path == "" || a.reloadInterval <= 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if now.Sub(a.lastCheck) < a.reloadInterval {
+		return
+	}
+
+	a.lastCheck = now
+
+	info, err := os.Stat(a.path)
+	if err != nil {
+		a.logger.Error("could not check policy", "path", a.path, "error", err)
+		return
+	}
+
+	if info.ModTime().Equal(a.modTime) {
+		return
+	}
+
+	if err := a.reload(); err != nil {
+		a.logger.Error("could not reload policy, keeping the previous one", "path", a.path, "error", err)
+		return
+	}
+
+	a.logger.Info("policy reloaded", "path", a.path)
+}
+
+func (a *Authorizer) AuthorizeUser(req *http.Request, op kaimono.Operation, resourceID string) error {
+	decision, err := a.Explain(req, op, resourceID)
+	if err != nil {
+		return err
+	}
+
+	if decision.Allowed {
+		return nil
+	}
+
+	if a.explain {
+		a.logger.Info(
+			"operation denied",
+			slog.Any("operation", op),
+			slog.String("resource-id", resourceID),
+			slog.Any("decision", decision),
+		)
+	}
+
+	return kaimono.NotAuthorizedError{Operation: op, ID: resourceID}
+}
+
+// Explain evaluates the policy, returning why the operation is allowed or
+// denied. It only returns an error if the owner of the cart couldn't be
+// looked up.
+func (a *Authorizer) Explain(req *http.Request, op kaimono.Operation, resourceID string) (Decision, error) {
+	a.reloadIfChanged(time.Now())
+
+	policy := a.Policy()
+
+	usrCtx, err := a.fetcher.GetUserContext(req)
+	if err != nil {
+		return Decision{Reason: fmt.Sprintf("could not get the user: %v", err)}, nil
+	}
+
+	if !usrCtx.IsLoggedIn() {
+		return Decision{Reason: "no user logged in"}, nil
+	}
+
+	decision := Decision{UserID: usrCtx.UserID, Roles: a.userRoles(policy, req, usrCtx)}
+	owned := []string{}
+
+	for _, role := range decision.Roles {
+		for _, rule := range policy.Roles[role] {
+			// owners can't hand their carts over, or out of owner rules.
+			if !rule.matches(op) || (rule.Owner && op.Type == kaimono.TransferOp) {
+				continue
+			}
+
+			if !rule.Owner {
+				decision.Allowed, decision.Role, decision.Rule = true, role, &rule
+				decision.Reason = fmt.Sprintf("granted by role '%s'", role)
+
+				return decision, nil
+			}
+
+			owned = append(owned, role)
+		}
+	}
+
+	if len(owned) == 0 {
+		decision.Reason = fmt.Sprintf("no rule of roles %v grants '%s' on '%s'", decision.Roles, op.Type, op.Resource)
+		return decision, nil
+	}
+
+	isOwner, err := a.isOwner(op, resourceID, usrCtx.UserID)
+	if err != nil {
+		return decision, err
+	}
+
+	if !isOwner {
+		decision.Reason = fmt.Sprintf("roles %v only grant '%s' on '%s' to its owner", owned, op.Type, op.Resource)
+		return decision, nil
+	}
+
+	decision.Allowed, decision.Role = true, owned[0]
+	decision.Reason = fmt.Sprintf("granted by role '%s' to the owner", owned[0])
+
+	for _, rule := range policy.Roles[owned[0]] {
+		if rule.Owner && rule.matches(op) {
+			decision.Rule = &rule
+			break
+		}
+	}
+
+	return decision, nil
+}
+
+// userRoles returns the sorted roles of the user which are defined by the
+// policy.
+func (a *Authorizer) userRoles(policy Policy, req *http.Request, usrCtx kaimono.UserContext) []string {
+	roles := slices.Concat(policy.DefaultRoles, policy.Users[usrCtx.UserID])
+	if a.roles != nil {
+		roles = append(roles, a.roles(req, usrCtx)...)
+	}
+
+	roles = slices.DeleteFunc(roles, func(role string) bool {
+		_, found := policy.Roles[role]
+		return !found
+	})
+
+	slices.Sort(roles)
+
+	return slices.Compact(roles)
+}
+
+// isOwner reports whether the resource is a cart owned by the user.
+func (a *Authorizer) isOwner(op kaimono.Operation, resourceID, userID string) (bool, error) {
+	if op.Resource != cartResource || resourceID == "" {
+		return false, nil
+	}
+
+	cart, err := a.db.LookupCart(resourceID)
+	if errors.Is(err, kaimono.ErrCartNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("could not look up cart owner: %w", err)
+	}
+
+	return cart.UserID == userID, nil
+}
@@ -0,0 +1,109 @@
+// Package policy provides a kaimono.Authorizer evaluating a declarative
+// policy: roles grant operations on resources, optionally only on the carts
+// owned by the requesting user.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+// Wildcard matches any resource or operation.
+const Wildcard = "*"
+
+const cartResource = "cart"
+
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+// Rule grants the Operations on the Resource, either of which can be the
+// Wildcard. Owner rules only apply to the carts owned by the requesting
+// user (see kaimono.Cart.UserID), and never grant kaimono.TransferOp.
+type Rule struct {
+	Resource   string                  `json:"resource"`
+	Operations []kaimono.OperationType `json:"operations"`
+	Owner      bool                    `json:"owner,omitempty"`
+}
+
+func (r Rule) matches(op kaimono.Operation) bool {
+	if r.Resource != Wildcard && r.Resource != op.Resource {
+		return false
+	}
+
+	return slices.Contains(r.Operations, Wildcard) || slices.Contains(r.Operations, op.Type)
+}
+
+// Policy maps roles to the rules they grant, and users to their roles.
+type Policy struct {
+	Roles map[string][]Rule `json:"roles"`
+
+	// Users maps user IDs to their roles.
+	Users map[string][]string `json:"users,omitempty"`
+
+	// DefaultRoles are granted to every logged-in user.
+	DefaultRoles []string `json:"default-roles,omitempty"`
+}
+
+// Parse decodes a JSON policy and validates it.
+func Parse(data []byte) (Policy, error) {
+	policy := Policy{}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&policy); err != nil {
+		return policy, fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+	}
+
+	return policy, policy.Validate()
+}
+
+// Validate checks that every rule names a resource and operations, and that
+// every role granted is defined.
+func (p Policy) Validate() error {
+	for role, rules := range p.Roles {
+		for k, rule := range rules {
+			if rule.Resource == "" || len(rule.Operations) == 0 {
+				return fmt.Errorf("%w: rule %d of role '%s' needs a resource and operations", ErrInvalidPolicy, k, role)
+			}
+		}
+	}
+
+	check := func(roles []string) error {
+		for _, role := range roles {
+			if _, found := p.Roles[role]; !found {
+				return fmt.Errorf("%w: unknown role '%s'", ErrInvalidPolicy, role)
+			}
+		}
+
+		return nil
+	}
+
+	if err := check(p.DefaultRoles); err != nil {
+		return err
+	}
+
+	for _, roles := range p.Users {
+		if err := check(roles); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p Policy) hasOwnerRules() bool {
+	for _, rules := range p.Roles {
+		for _, rule := range rules {
+			if rule.Owner {
+				return true
+			}
+		}
+	}
+
+	return false
+}
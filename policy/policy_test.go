@@ -0,0 +1,287 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aalbacetef/kaimono"
+	"github.com/aalbacetef/kaimono/memstore"
+	"github.com/aalbacetef/kaimono/session"
+)
+
+const (
+	userHeader = "X-User"
+	roleHeader = "X-Role"
+)
+
+var testFetcher = session.Header{UserHeader: userHeader}
+
+var testPolicy = Policy{
+	Roles: map[string][]Rule{
+		"admin":    {{Resource: Wildcard, Operations: []kaimono.OperationType{Wildcard}}},
+		"support":  {{Resource: "cart", Operations: []kaimono.OperationType{kaimono.ReadOp, kaimono.ListOp}}},
+		"customer": {{Resource: "cart", Operations: []kaimono.OperationType{kaimono.ReadOp, kaimono.UpdateOp}, Owner: true}},
+		"analyst":  {{Resource: "analytics", Operations: []kaimono.OperationType{kaimono.ReadOp}}},
+	},
+	Users:        map[string][]string{"alice": {"admin"}, "bob": {"support"}},
+	DefaultRoles: []string{"customer"},
+}
+
+func newTestRequest(userID, role string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(session.DefaultHeaderName, "session")
+
+	if userID != "" {
+		req.Header.Set(userHeader, userID)
+	}
+
+	if role != "" {
+		req.Header.Set(roleHeader, role)
+	}
+
+	return req
+}
+
+func TestAuthorizer(t *testing.T) {
+	db := memstore.New()
+
+	cart, err := db.CreateCart()
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	cart.UserID = "carol"
+	if err := db.UpdateCart(cart); err != nil {
+		t.Fatalf("could not update cart: %v", err)
+	}
+
+	roles := func(req *http.Request, _ kaimono.UserContext) []string {
+		return []string{req.Header.Get(roleHeader)}
+	}
+
+	authorizer, err := New(testPolicy, testFetcher, db, WithRoles(roles))
+	if err != nil {
+		t.Fatalf("could not create authorizer: %v", err)
+	}
+
+	op := func(resource string, opType kaimono.OperationType) kaimono.Operation {
+		return kaimono.Operation{Resource: resource, Type: opType}
+	}
+
+	tests := []struct {
+		label      string
+		userID     string
+		role       string
+		op         kaimono.Operation
+		resourceID string
+		allowed    bool
+	}{
+		{"wildcards", "alice", "", op("job", kaimono.BulkDeleteOp), "", true},
+		{"role", "bob", "", op("cart", kaimono.ReadOp), cart.ID, true},
+		{"operation not granted", "bob", "", op("cart", kaimono.DeleteOp), cart.ID, false},
+		{"owner", "carol", "", op("cart", kaimono.UpdateOp), cart.ID, true},
+		{"not the owner", "dave", "", op("cart", kaimono.UpdateOp), cart.ID, false},
+		{"owner rule without a cart", "carol", "", op("cart", kaimono.ReadOp), "", false},
+		{"owner rule with an unknown cart", "carol", "", op("cart", kaimono.ReadOp), "unknown", false},
+		{"role from RoleFunc", "dave", "analyst", op("analytics", kaimono.ReadOp), "", true},
+		{"unknown role from RoleFunc", "dave", "root", op("analytics", kaimono.ReadOp), "", false},
+		{"anonymous", "", "", op("cart", kaimono.ReadOp), cart.ID, false},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			err := authorizer.AuthorizeUser(newTestRequest(c.userID, c.role), c.op, c.resourceID)
+			if c.allowed && err != nil {
+				t.Fatalf("expected to be allowed, got %v", err)
+			}
+
+			if !c.allowed && !errors.As(err, &kaimono.NotAuthorizedError{}) {
+				t.Fatalf("expected to be denied, got %v", err)
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	logs := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(logs, nil))
+
+	authorizer, err := New(testPolicy, testFetcher, memstore.New(), WithExplain(), WithLogger(logger))
+	if err != nil {
+		t.Fatalf("could not create authorizer: %v", err)
+	}
+
+	op := kaimono.Operation{Resource: "cart", Type: kaimono.DeleteOp}
+
+	decision, err := authorizer.Explain(newTestRequest("bob", ""), op, "cart-id")
+	if err != nil {
+		t.Fatalf("could not explain: %v", err)
+	}
+
+	if decision.Allowed || strings.Join(decision.Roles, ",") != "customer,support" || decision.Reason == "" {
+		t.Fatalf("unexpected decision: %+v", decision)
+	}
+
+	decision, err = authorizer.Explain(newTestRequest("alice", ""), op, "cart-id")
+	if err != nil {
+		t.Fatalf("could not explain: %v", err)
+	}
+
+	if !decision.Allowed || decision.Role != "admin" || decision.Rule == nil {
+		t.Fatalf("unexpected decision: %+v", decision)
+	}
+
+	if err := authorizer.AuthorizeUser(newTestRequest("bob", ""), op, "cart-id"); err == nil {
+		t.Fatalf("expected to be denied")
+	}
+
+	if !strings.Contains(logs.String(), "operation denied") || !strings.Contains(logs.String(), "customer") {
+		t.Fatalf("expected the decision to be logged, got: %s", logs.String())
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	tests := []struct {
+		label string
+		data  string
+	}{
+		{"unknown field", `{"roles": {}, "groups": {}}`},
+		{"rule without resource", `{"roles": {"admin": [{"operations": ["*"]}]}}`},
+		{"rule without operations", `{"roles": {"admin": [{"resource": "*"}]}}`},
+		{"unknown user role", `{"roles": {}, "users": {"alice": ["admin"]}}`},
+		{"unknown default role", `{"roles": {}, "default-roles": ["customer"]}`},
+	}
+
+	for _, c := range tests {
+		if _, err := Parse([]byte(c.data)); !errors.Is(err, ErrInvalidPolicy) {
+			t.Fatalf("(%s) expected ErrInvalidPolicy, got %v", c.label, err)
+		}
+	}
+
+	if _, err := New(testPolicy, testFetcher, nil); !errors.Is(err, ErrOwnerRulesNeedDB) {
+		t.Fatalf("expected ErrOwnerRulesNeedDB, got %v", err)
+	}
+}
+
+func TestLoadAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+
+	write := func(data string, modTime time.Time) {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("could not write policy: %v", err)
+		}
+
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("could not set policy time: %v", err)
+		}
+	}
+
+	now := time.Now()
+	write(`{"roles": {"admin": [{"resource": "cart", "operations": ["read"]}]}, "users": {"alice": ["admin"]}}`, now)
+
+	authorizer, err := Load(path, testFetcher, nil, WithReloadInterval(time.Nanosecond))
+	if err != nil {
+		t.Fatalf("could not load policy: %v", err)
+	}
+
+	req := newTestRequest("alice", "")
+	read := kaimono.Operation{Resource: "cart", Type: kaimono.ReadOp}
+	remove := kaimono.Operation{Resource: "cart", Type: kaimono.DeleteOp}
+
+	if err := authorizer.AuthorizeUser(req, remove, "cart-id"); err == nil {
+		t.Fatalf("expected to be denied")
+	}
+
+	write(`{"roles": {"admin": [{"resource": "cart", "operations": ["read", "delete"]}]}, "users": {"alice": ["admin"]}}`, now.Add(time.Second))
+
+	if err := authorizer.AuthorizeUser(req, remove, "cart-id"); err != nil {
+		t.Fatalf("expected the reloaded policy to allow, got %v", err)
+	}
+
+	// an invalid policy is ignored.
+	write(`{"roles": {}, "users": {"alice": ["admin"]}}`, now.Add(2*time.Second))
+
+	if err := authorizer.AuthorizeUser(req, read, "cart-id"); err != nil {
+		t.Fatalf("expected the previous policy to be kept, got %v", err)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json"), testFetcher, nil); err == nil {
+		t.Fatalf("expected an error for a missing policy")
+	}
+}
+
+func TestOwnerCantTransfer(t *testing.T) {
+	db := memstore.New()
+
+	owned := Policy{
+		Roles: map[string][]Rule{
+			"customer": {{Resource: "cart", Operations: []kaimono.OperationType{Wildcard}, Owner: true}},
+		},
+		DefaultRoles: []string{"customer"},
+	}
+
+	authorizer, err := New(owned, testFetcher, db)
+	if err != nil {
+		t.Fatalf("could not create authorizer: %v", err)
+	}
+
+	svc, err := kaimono.NewService(db, testFetcher, authorizer, nil)
+	if err != nil {
+		t.Fatalf("could not create service: %v", err)
+	}
+
+	cart, err := db.CreateCart()
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	cart.UserID = "carol"
+	if err := db.UpdateCart(cart); err != nil {
+		t.Fatalf("could not update cart: %v", err)
+	}
+
+	update := func(userID string) int {
+		body, err := json.Marshal(kaimono.UpdateCartRequest{Data: kaimono.Cart{UserID: userID}})
+		if err != nil {
+			t.Fatalf("could not encode cart: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/"+cart.ID, bytes.NewReader(body))
+		req.Header.Set(session.DefaultHeaderName, "session")
+		req.Header.Set(userHeader, "carol")
+
+		rec := httptest.NewRecorder()
+		svc.AdminRouter("/admin").ServeHTTP(rec, req)
+
+		return rec.Code
+	}
+
+	tests := []struct {
+		label  string
+		userID string
+		code   int
+	}{
+		{"owner kept", "", http.StatusOK},
+		{"same owner", "carol", http.StatusOK},
+		{"hand over", "dave", http.StatusForbidden},
+	}
+
+	for _, c := range tests {
+		if code := update(c.userID); code != c.code {
+			t.Fatalf("(%s) got code %d, want %d", c.label, code, c.code)
+		}
+	}
+
+	if found, _ := db.LookupCart(cart.ID); found.UserID != "carol" {
+		t.Fatalf("expected carol to still own the cart, got %q", found.UserID)
+	}
+}
@@ -0,0 +1,252 @@
+package kaimono
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// cartQueryParams are the query parameters accepted by Service.List.
+var cartQueryParams = []string{
+	"user-id", "session-token", "item-id", "currency",
+	"created-after", "created-before", "updated-after", "updated-before",
+	"min-total", "max-total", "cursor", "limit",
+}
+
+// CartQuery filters the carts listed by a CartQuerier, zero values don't
+// filter. Time ranges are inclusive.
+type CartQuery struct {
+	UserID        string
+	ItemID        string
+	Currency      string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	MinTotal      *float64
+	MaxTotal      *float64
+
+	// IncludeSaved makes ItemID also match the items saved for later.
+	IncludeSaved bool
+
+	Cursor string
+
+	// Limit caps the number of carts returned, none if not positive.
+	Limit int
+}
+
+// CartPage is a page of carts returned by a CartQuerier.
+type CartPage struct {
+	Carts      []Cart `json:"carts"`
+	NextCursor string `json:"next-cursor"`
+}
+
+// CartList is a page of carts and the limit it was requested with.
+type CartList struct {
+	CartPage
+
+	Limit int `json:"limit"`
+}
+
+type ListCartsResponse = Response[CartList]
+
+// Matches reports whether the Cart matches the query's filters. Carts with
+// mixed currencies never match a total or currency filter.
+func (query CartQuery) Matches(cart Cart) bool {
+	if query.UserID != "" && cart.UserID != query.UserID {
+		return false
+	}
+
+	if query.ItemID != "" && !query.hasItem(cart) {
+		return false
+	}
+
+	if !inTimeRange(cart.CreatedAt, query.CreatedAfter, query.CreatedBefore) ||
+		!inTimeRange(cart.UpdatedAt, query.UpdatedAfter, query.UpdatedBefore) {
+		return false
+	}
+
+	if query.Currency == "" && query.MinTotal == nil && query.MaxTotal == nil {
+		return true
+	}
+
+	totals, err := cart.Totals()
+	if err != nil {
+		return false
+	}
+
+	return (query.Currency == "" || totals.Currency == query.Currency) &&
+		(query.MinTotal == nil || totals.Total >= *query.MinTotal) &&
+		(query.MaxTotal == nil || totals.Total <= *query.MaxTotal)
+}
+
+func (query CartQuery) hasItem(cart Cart) bool {
+	if _, found := cart.Item(query.ItemID); found {
+		return true
+	}
+
+	return query.IncludeSaved && slices.ContainsFunc(cart.Saved, func(item CartItem) bool {
+		return item.ID == query.ItemID
+	})
+}
+
+func inTimeRange(t, after, before time.Time) bool {
+	return (after.IsZero() || !t.Before(after)) && (before.IsZero() || !t.After(before))
+}
+
+// parseCartQuery parses the query parameters listed in cartQueryParams,
+// returning the session token to filter by separately.
+func parseCartQuery(values url.Values) (CartQuery, string, error) {
+	fields := []FieldError{}
+
+	for key := range values {
+		if !slices.Contains(cartQueryParams, key) {
+			fields = append(fields, FieldError{Field: key, Message: "is not a known parameter"})
+		}
+	}
+
+	query := CartQuery{
+		UserID:   values.Get("user-id"),
+		ItemID:   values.Get("item-id"),
+		Currency: values.Get("currency"),
+		Cursor:   values.Get("cursor"),
+		Limit:    defaultPageLimit,
+	}
+
+	times := map[string]*time.Time{
+		"created-after":  &query.CreatedAfter,
+		"created-before": &query.CreatedBefore,
+		"updated-after":  &query.UpdatedAfter,
+		"updated-before": &query.UpdatedBefore,
+	}
+
+	for key, dst := range times {
+		if v := values.Get(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				fields = append(fields, FieldError{Field: key, Message: "must be an RFC 3339 time"})
+			}
+
+			*dst = t
+		}
+	}
+
+	totals := map[string]**float64{"min-total": &query.MinTotal, "max-total": &query.MaxTotal}
+
+	for key, dst := range totals {
+		if v := values.Get(key); v != "" {
+			total, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				fields = append(fields, FieldError{Field: key, Message: "must be a number"})
+			}
+
+			*dst = &total
+		}
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxPageLimit {
+			fields = append(fields, FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", maxPageLimit)})
+		}
+
+		query.Limit = limit
+	}
+
+	if len(fields) > 0 {
+		slices.SortFunc(fields, func(a, b FieldError) int { return strings.Compare(a.Field, b.Field) })
+
+		return query, "", ValidationError{Err: ErrInvalidQuery, Fields: fields}
+	}
+
+	return query, values.Get("session-token"), nil
+}
+
+// List will return the carts matching the query parameters, paginated by
+// cursor: pass the returned next-cursor as the cursor parameter to fetch
+// the next page.
+//
+// Query parameters (all optional):
+//   - user-id, session-token, item-id, currency
+//   - created-after, created-before, updated-after, updated-before: RFC 3339 times
+//   - min-total, max-total: bounds on the Cart's total
+//   - cursor, limit: defaults to 50, at most 500
+//
+// Status codes:
+//   - 200: OK
+//   - 400: Invalid query parameters
+//   - 403: Forbidden
+//   - 500: unexpected error
+//   - 501: DB doesn't implement CartQuerier
+func (svc *Service) List(w http.ResponseWriter, req *http.Request) {
+	op := Operation{
+		Type:     ListOp,
+		Resource: "cart",
+	}
+
+	if !checkAndReportAuthorized(svc, w, req, op, "") {
+		return
+	}
+
+	query, sessionToken, err := parseCartQuery(req.URL.Query())
+	if err != nil {
+		svc.json(svc.writeError(w, http.StatusBadRequest, err))
+		return
+	}
+
+	page, err := svc.queryCarts(req.Context(), query, sessionToken)
+	if errors.Is(err, ErrNotSupported) {
+		svc.json(svc.writeError(w, http.StatusNotImplemented, err))
+		return
+	}
+
+	if err != nil {
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
+		return
+	}
+
+	resp := ListCartsResponse{Data: CartList{CartPage: page, Limit: query.Limit}}
+
+	svc.json(writeResponse(w, http.StatusOK, resp))
+}
+
+// queryCarts looks up the session's Cart when filtering by session, since
+// a session has at most one Cart, and queries the DB otherwise.
+func (svc *Service) queryCarts(ctx context.Context, query CartQuery, sessionToken string) (CartPage, error) {
+	page := CartPage{Carts: []Cart{}}
+
+	if sessionToken == "" {
+		querier, ok := svc.store(ctx).(CartQuerier)
+		if !ok {
+			return page, ErrNotSupported
+		}
+
+		return querier.QueryCarts(query)
+	}
+
+	cart, err := svc.store(ctx).LookupCartForSession(sessionToken)
+	if errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrCartNotFound) {
+		return page, nil
+	}
+
+	if err != nil {
+		return page, err
+	}
+
+	if query.Cursor == "" && query.Matches(cart) {
+		page.Carts = append(page.Carts, cart)
+	}
+
+	return page, nil
+}
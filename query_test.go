@@ -0,0 +1,163 @@
+package kaimono
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCartQueryMatches(t *testing.T) {
+	now := time.Now().UTC()
+	minTotal, maxTotal := 5.0, 10.0
+
+	cart := Cart{
+		ID:        "cart",
+		UserID:    "user-a",
+		CreatedAt: now.Add(-time.Hour),
+		UpdatedAt: now,
+		Items: []CartItem{
+			{ID: "apple", Quantity: 3, Price: Price{Currency: "EUR", Value: 2}},
+		},
+	}
+
+	tests := []struct {
+		label string
+		query CartQuery
+		want  bool
+	}{
+		{label: "empty query", query: CartQuery{}, want: true},
+		{label: "user", query: CartQuery{UserID: "user-a"}, want: true},
+		{label: "other user", query: CartQuery{UserID: "user-b"}, want: false},
+		{label: "item", query: CartQuery{ItemID: "apple"}, want: true},
+		{label: "missing item", query: CartQuery{ItemID: "pear"}, want: false},
+		{label: "currency", query: CartQuery{Currency: "EUR"}, want: true},
+		{label: "other currency", query: CartQuery{Currency: "USD"}, want: false},
+		{label: "total range", query: CartQuery{MinTotal: &minTotal, MaxTotal: &maxTotal}, want: true},
+		{label: "below total", query: CartQuery{MaxTotal: &minTotal}, want: false},
+		{label: "created range", query: CartQuery{CreatedAfter: now.Add(-2 * time.Hour), CreatedBefore: now}, want: true},
+		{label: "created later", query: CartQuery{CreatedAfter: now.Add(-time.Minute)}, want: false},
+		{label: "updated earlier", query: CartQuery{UpdatedBefore: now.Add(-time.Minute)}, want: false},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			if got := c.query.Matches(cart); got != c.want {
+				t.Fatalf("got %t, want %t", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAdminList(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.AdminRouter("/admin"))
+	t.Cleanup(srv.Close)
+
+	for range 3 {
+		if _, err := svc.createUserCart(context.Background(), "test-user", "", ShoppingCart); err != nil {
+			t.Fatalf("could not create cart: %v", err)
+		}
+	}
+
+	sessionCart, err := svc.createCartForSession(context.Background(), mock.sessions[2], "")
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	admin := mock.sessions[1]
+	query := url.Values{"user-id": {"test-user"}, "limit": {"2"}}
+
+	first := ListCartsResponse{}
+	if code := doJSONRequest(t, http.MethodGet, srv.URL+"/admin/?"+query.Encode(), admin, nil, &first); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if len(first.Data.Carts) != 2 || first.Data.NextCursor == "" || first.Data.Limit != 2 {
+		t.Fatalf("unexpected first page: %+v", first.Data)
+	}
+
+	query.Set("cursor", first.Data.NextCursor)
+
+	second := ListCartsResponse{}
+	if code := doJSONRequest(t, http.MethodGet, srv.URL+"/admin/?"+query.Encode(), admin, nil, &second); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if len(second.Data.Carts) != 1 || second.Data.NextCursor != "" {
+		t.Fatalf("unexpected second page: %+v", second.Data)
+	}
+
+	bySession := ListCartsResponse{}
+	sessionURL := srv.URL + "/admin/?session-token=" + mock.sessions[2]
+
+	if code := doJSONRequest(t, http.MethodGet, sessionURL, admin, nil, &bySession); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if len(bySession.Data.Carts) != 1 || bySession.Data.Carts[0].ID != sessionCart.ID {
+		t.Fatalf("unexpected carts for session: %+v", bySession.Data)
+	}
+
+	unsupported, err := NewService(struct{ DB }{mock}, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	unsupportedSrv := httptest.NewServer(unsupported.AdminRouter("/admin"))
+	t.Cleanup(unsupportedSrv.Close)
+
+	tests := []struct {
+		label        string
+		url          string
+		sessionToken string
+		wantCode     int
+	}{
+		{
+			label:        "requires authorization",
+			url:          srv.URL + "/admin/",
+			sessionToken: mock.sessions[0],
+			wantCode:     http.StatusForbidden,
+		},
+		{
+			label:        "rejects unknown parameters",
+			url:          srv.URL + "/admin/?color=red",
+			sessionToken: admin,
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			label:        "rejects invalid times",
+			url:          srv.URL + "/admin/?created-after=yesterday",
+			sessionToken: admin,
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			label:        "rejects invalid limits",
+			url:          srv.URL + "/admin/?limit=0",
+			sessionToken: admin,
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			label:        "requires support from the DB",
+			url:          unsupportedSrv.URL + "/admin/",
+			sessionToken: admin,
+			wantCode:     http.StatusNotImplemented,
+		},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			if code := doJSONRequest(t, http.MethodGet, c.url, c.sessionToken, nil, nil); code != c.wantCode {
+				t.Fatalf("got code %d, want %d", code, c.wantCode)
+			}
+		})
+	}
+}
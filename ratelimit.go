@@ -0,0 +1,279 @@
+package kaimono
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const rateLimitSweepInterval = time.Minute
+
+var (
+	ErrRateLimited  = errors.New("rate limit exceeded")
+	ErrTooManyCarts = errors.New("too many carts created")
+)
+
+// RateLimit allows Rate requests per Interval on average, in bursts of up
+// to Burst requests. A zero Rate disables the limit.
+type RateLimit struct {
+	Rate     int
+	Interval time.Duration
+
+	// Burst defaults to Rate.
+	Burst int
+}
+
+func (l RateLimit) enabled() bool {
+	return l.Rate > 0 && l.Interval > 0
+}
+
+// RateLimitConfig configures the limits enforced by both routers. Requests
+// are limited per user, per session for anonymous users, and per client IP
+// for requests without a session.
+type RateLimitConfig struct {
+	// Default applies to every route without its own limit.
+	Default RateLimit
+
+	// Routes overrides the limit of a route, keyed by method and route
+	// pattern, e.g: "POST /cart" or "POST /cart/carts/move". Each route then
+	// has its own budget.
+	Routes map[string]RateLimit
+
+	// CartCreation caps the carts created through the standard router per
+	// user, or per client IP for anonymous users.
+	CartCreation RateLimit
+
+	// ClientIP returns the IP of the client, defaults to the host of
+	// the request's RemoteAddr. Set it to read the IP forwarded by trusted
+	// proxies.
+	ClientIP func(req *http.Request) string
+}
+
+// WithRateLimits enables rate limiting on both routers. Limited requests
+// are rejected with a 429 and a Retry-After header.
+func WithRateLimits(cfg RateLimitConfig) Option {
+	return func(svc *Service) {
+		svc.rateLimits = newRateLimits(cfg)
+	}
+}
+
+type rateLimits struct {
+	clientIP func(req *http.Request) string
+	fallback *tokenBuckets
+	routes   map[string]*tokenBuckets
+	carts    *tokenBuckets
+}
+
+func newRateLimits(cfg RateLimitConfig) *rateLimits {
+	limits := &rateLimits{
+		clientIP: cfg.ClientIP,
+		fallback: newTokenBuckets(cfg.Default),
+		routes:   make(map[string]*tokenBuckets, len(cfg.Routes)),
+		carts:    newTokenBuckets(cfg.CartCreation),
+	}
+
+	if limits.clientIP == nil {
+		limits.clientIP = remoteIP
+	}
+
+	for route, limit := range cfg.Routes {
+		limits.routes[route] = newTokenBuckets(limit)
+	}
+
+	return limits
+}
+
+// forRoute returns the buckets limiting the request, or nil if it isn't
+// limited. It must be called once the route is matched.
+func (limits *rateLimits) forRoute(req *http.Request) *tokenBuckets {
+	if rctx := chi.RouteContext(req.Context()); rctx != nil {
+		if buckets, found := limits.routes[req.Method+" "+rctx.RoutePattern()]; found {
+			return buckets
+		}
+	}
+
+	return limits.fallback
+}
+
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
+
+// limitRequests rejects the requests over their route's limit. It is
+// applied to the routes rather than the router, so the route is matched
+// when it runs.
+func (svc *Service) limitRequests(next http.Handler) http.Handler {
+	if svc.rateLimits == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		buckets := svc.rateLimits.forRoute(req)
+		if buckets == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		// anonymous sessions are chosen by the client, so they're limited
+		// by IP as well.
+		key := "ip:" + svc.rateLimits.clientIP(req)
+
+		if usrCtx, err := svc.usrCtxFetcher.GetUserContext(req); err == nil && usrCtx.IsLoggedIn() {
+			key = "user:" + usrCtx.UserID
+		}
+
+		if wait, ok := buckets.take(key, time.Now()); !ok {
+			svc.writeRateLimited(w, wait, ErrRateLimited)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+// limitCartCreation rejects cart creations once the user, or the client IP
+// for anonymous users, is over the CartCreation limit. Only the carts
+// actually created count towards it.
+func (svc *Service) limitCartCreation(next http.Handler) http.Handler {
+	if svc.rateLimits == nil || svc.rateLimits.carts == nil {
+		return next
+	}
+
+	buckets := svc.rateLimits.carts
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := "ip:" + svc.rateLimits.clientIP(req)
+
+		if usrCtx, err := svc.usrCtxFetcher.GetUserContext(req); err == nil && usrCtx.IsLoggedIn() {
+			key = "user:" + usrCtx.UserID
+		}
+
+		// taken up front so concurrent requests can't all pass, and refunded
+		// if no cart was created.
+		if wait, ok := buckets.take(key, time.Now()); !ok {
+			svc.writeRateLimited(w, wait, ErrTooManyCarts)
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(rec, req)
+
+		if rec.code != http.StatusCreated {
+			buckets.refund(key, time.Now())
+		}
+	})
+}
+
+func (svc *Service) writeRateLimited(w http.ResponseWriter, wait time.Duration, err error) {
+	seconds := max(1, int(math.Ceil(wait.Seconds())))
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	svc.json(svc.writeError(w, http.StatusTooManyRequests, err))
+}
+
+// tokenBuckets holds a token bucket per key, all sharing the same limit.
+type tokenBuckets struct {
+	limit     RateLimit
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBuckets returns nil if the limit is disabled.
+func newTokenBuckets(limit RateLimit) *tokenBuckets {
+	if !limit.enabled() {
+		return nil
+	}
+
+	if limit.Burst <= 0 {
+		limit.Burst = limit.Rate
+	}
+
+	return &tokenBuckets{
+		limit:   limit,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// take consumes a token, or returns how long until one is available.
+func (tb *tokenBuckets) take(key string, now time.Time) (time.Duration, bool) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	bucket := tb.refill(key, now)
+	if bucket.tokens < 1 {
+		return tb.wait(bucket), false
+	}
+
+	bucket.tokens--
+
+	return 0, true
+}
+
+// refund gives back a token taken for a request which didn't use it.
+func (tb *tokenBuckets) refund(key string, now time.Time) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	bucket := tb.refill(key, now)
+	bucket.tokens = min(float64(tb.limit.Burst), bucket.tokens+1)
+}
+
+func (tb *tokenBuckets) refill(key string, now time.Time) *tokenBucket {
+	tb.sweep(now)
+
+	bucket, found := tb.buckets[key]
+	if !found {
+		bucket = &tokenBucket{tokens: float64(tb.limit.Burst), last: now}
+		tb.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.last)
+	if elapsed > 0 {
+		bucket.tokens = min(float64(tb.limit.Burst), bucket.tokens+elapsed.Seconds()*tb.perSecond())
+		bucket.last = now
+	}
+
+	return bucket
+}
+
+func (tb *tokenBuckets) perSecond() float64 {
+	return float64(tb.limit.Rate) / tb.limit.Interval.Seconds()
+}
+
+func (tb *tokenBuckets) wait(bucket *tokenBucket) time.Duration {
+	return time.Duration((1 - bucket.tokens) / tb.perSecond() * float64(time.Second))
+}
+
+// sweep removes the buckets that would be full by now, at most once per
+// rateLimitSweepInterval, as they are the same as new ones.
+func (tb *tokenBuckets) sweep(now time.Time) {
+	if now.Sub(tb.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+
+	for key, bucket := range tb.buckets {
+		missing := float64(tb.limit.Burst) - bucket.tokens
+		if now.Sub(bucket.last).Seconds()*tb.perSecond() >= missing {
+			delete(tb.buckets, key)
+		}
+	}
+
+	tb.lastSweep = now
+}
@@ -0,0 +1,275 @@
+package kaimono
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBuckets(t *testing.T) {
+	buckets := newTokenBuckets(RateLimit{Rate: 1, Interval: time.Second, Burst: 2})
+	now := time.Now()
+
+	for k := range 2 {
+		if _, ok := buckets.take("key", now); !ok {
+			t.Fatalf("request %d should be allowed", k)
+		}
+	}
+
+	wait, ok := buckets.take("key", now)
+	if ok || wait != time.Second {
+		t.Fatalf("expected to wait 1s, got (%v, %v)", wait, ok)
+	}
+
+	if _, ok := buckets.take("other", now); !ok {
+		t.Fatalf("keys should have their own bucket")
+	}
+
+	if _, ok := buckets.take("key", now.Add(time.Second)); !ok {
+		t.Fatalf("a token should be available after 1s")
+	}
+
+	buckets.refund("key", now.Add(time.Second))
+
+	if _, ok := buckets.take("key", now.Add(time.Second)); !ok {
+		t.Fatalf("a refunded token should be available")
+	}
+
+	// refunds never exceed the burst.
+	for range 3 {
+		buckets.refund("other", now)
+	}
+
+	if buckets.buckets["other"].tokens != 2 {
+		t.Fatalf("got %v tokens, want the burst", buckets.buckets["other"].tokens)
+	}
+
+	if newTokenBuckets(RateLimit{}) != nil {
+		t.Fatalf("a zero limit should be disabled")
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	tests := []struct {
+		label   string
+		cfg     RateLimitConfig
+		method  string
+		path    string
+		allowed int
+	}{
+		{
+			"default limit", RateLimitConfig{Default: RateLimit{Rate: 2, Interval: time.Minute}},
+			http.MethodGet, "/cart/", 2,
+		},
+		{
+			"route limit",
+			RateLimitConfig{
+				Default: RateLimit{Rate: 2, Interval: time.Minute},
+				Routes:  map[string]RateLimit{"GET /cart/carts": {Rate: 1, Interval: time.Minute}},
+			},
+			http.MethodGet, "/cart/carts", 1,
+		},
+		{
+			"disabled route limit",
+			RateLimitConfig{
+				Default: RateLimit{Rate: 1, Interval: time.Minute},
+				Routes:  map[string]RateLimit{"GET /cart": {}},
+			},
+			http.MethodGet, "/cart/", 5,
+		},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			mock := newMockBackend()
+
+			svc, err := NewService(mock, mock, mock, nil, WithRateLimits(c.cfg))
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+
+			srv := httptest.NewServer(svc.Router("/cart"))
+			t.Cleanup(srv.Close)
+
+			do := func(session string) *http.Response {
+				req, err := http.NewRequest(c.method, srv.URL+c.path, nil)
+				if err != nil {
+					t.Fatalf("could not make request: %v", err)
+				}
+
+				setTestCookie(req, session)
+
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatalf("could not do request: %v", err)
+				}
+
+				resp.Body.Close()
+
+				return resp
+			}
+
+			for k := range c.allowed {
+				if resp := do(mock.sessions[0]); resp.StatusCode == http.StatusTooManyRequests {
+					t.Fatalf("request %d should be allowed", k)
+				}
+			}
+
+			if c.allowed > 2 {
+				return
+			}
+
+			resp := do(mock.sessions[0])
+			if resp.StatusCode != http.StatusTooManyRequests {
+				t.Fatalf("got code %d, want %d", resp.StatusCode, http.StatusTooManyRequests)
+			}
+
+			if resp.Header.Get("Retry-After") == "" {
+				t.Fatalf("expected the Retry-After header to be set")
+			}
+
+			if resp := do(mock.sessions[2]); resp.StatusCode == http.StatusTooManyRequests {
+				t.Fatalf("sessions should have their own limit")
+			}
+		})
+	}
+}
+
+func TestRateLimitSessionRotation(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil, WithRateLimits(RateLimitConfig{
+		Default: RateLimit{Rate: 2, Interval: time.Minute},
+	}))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	// anonymous sessions are chosen by the client.
+	for k, session := range []string{"first-session", "second-session", "third-session"} {
+		code := doJSONRequest(t, http.MethodGet, srv.URL+"/cart/", session, nil, nil)
+
+		if limited := code == http.StatusTooManyRequests; limited != (k >= 2) {
+			t.Fatalf("(%d) got code %d, rotating the session must not reset the limit", k, code)
+		}
+	}
+}
+
+func TestRateLimitGraphQL(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil, WithRateLimits(RateLimitConfig{
+		Default: RateLimit{Rate: 1, Interval: time.Minute},
+	}))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	handler, err := svc.GraphQLHandler()
+	if err != nil {
+		t.Fatalf("could not create handler: %v", err)
+	}
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	query := graphqlRequest{Query: `{ cart { id } }`}
+
+	for k, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		if code := doJSONRequest(t, http.MethodPost, srv.URL, mock.sessions[0], query, nil); code != want {
+			t.Fatalf("(%d) got code %d, want %d", k, code, want)
+		}
+	}
+}
+
+func TestCartCreationLimit(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil, WithRateLimits(RateLimitConfig{
+		CartCreation: RateLimit{Rate: 1, Interval: time.Hour},
+	}))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	anonymous, user := mock.sessions[2], mock.sessions[0]
+	body := CreateUserCartRequest{Data: NewUserCart{Name: "wishlist", Kind: WishlistCart}}
+
+	tests := []struct {
+		session string
+		path    string
+		body    any
+		code    int
+	}{
+		// failed creations don't count towards the limit.
+		{anonymous, "/cart/carts", body, http.StatusUnauthorized},
+		{anonymous, "/cart/", nil, http.StatusCreated},
+		{anonymous, "/cart/", nil, http.StatusTooManyRequests},
+		{user, "/cart/", nil, http.StatusCreated},
+		{user, "/cart/carts", body, http.StatusTooManyRequests},
+	}
+
+	for k, c := range tests {
+		if code := doJSONRequest(t, http.MethodPost, srv.URL+c.path, c.session, c.body, nil); code != c.code {
+			t.Fatalf("(%d) got code %d, want %d", k, code, c.code)
+		}
+	}
+}
+
+func TestCartCreationLimitConcurrent(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil, WithRateLimits(RateLimitConfig{
+		CartCreation: RateLimit{Rate: 1, Interval: time.Hour},
+	}))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	const requests = 5
+
+	release := make(chan struct{})
+	handler := svc.limitCartCreation(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	codes := make(chan int, requests)
+
+	for range requests {
+		go func() {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cart/", nil))
+			codes <- rec.Code
+		}()
+	}
+
+	// every request but the one being served must be rejected while it's
+	// still in flight.
+	limited := 0
+	for range requests - 1 {
+		select {
+		case code := <-codes:
+			if code != http.StatusTooManyRequests {
+				t.Fatalf("got code %d, want %d", code, http.StatusTooManyRequests)
+			}
+
+			limited++
+		case <-time.After(time.Second):
+			t.Fatalf("only %d concurrent creations were limited", limited)
+		}
+	}
+
+	close(release)
+
+	if code := <-codes; code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+}
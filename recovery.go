@@ -0,0 +1,413 @@
+package kaimono
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultIdleAfter        = 24 * time.Hour
+	defaultRecoveryCooldown = 72 * time.Hour
+	defaultMaxNotifications = 3
+	defaultRestoreLinkTTL   = 7 * 24 * time.Hour
+	defaultRecoveryInterval = time.Hour
+	recoverySweepInterval   = time.Minute
+)
+
+var (
+	ErrRecoveryDisabled = errors.New("cart recovery is not configured")
+	ErrRestoreNotFound  = errors.New("restore link not found")
+	ErrRestoreExpired   = errors.New("restore link expired")
+)
+
+// Notification is sent to the owner of an abandoned Cart. RestoreURL is
+// the configured base URL followed by the restore token.
+type Notification struct {
+	UserID     string    `json:"user-id"`
+	Cart       Cart      `json:"cart"`
+	Token      string    `json:"token"`
+	RestoreURL string    `json:"restore-url"`
+	ExpiresAt  time.Time `json:"expires-at"`
+}
+
+// Notifier delivers recovery notifications, see the notify package for
+// implementations.
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// RecoveryConfig configures the detection of abandoned carts. Zero values
+// use the defaults.
+type RecoveryConfig struct {
+	// IdleAfter is how long a Cart must go without updates to be
+	// considered abandoned, defaults to 24 hours.
+	IdleAfter time.Duration
+
+	// Cooldown is the minimum time between two notifications to the same
+	// user, defaults to 72 hours.
+	Cooldown time.Duration
+
+	// MaxNotifications is the maximum number of notifications sent for a
+	// Cart, defaults to 3. A Cart is only notified again after it was
+	// updated since the last notification.
+	MaxNotifications int
+
+	// LinkTTL is how long restore links are valid, defaults to 7 days.
+	LinkTTL time.Duration
+
+	// RestoreURL is prepended to the restore token, e.g:
+	// "https://shop.example/cart/recover/".
+	RestoreURL string
+
+	// Interval is how often RunRecovery checks for abandoned carts,
+	// defaults to 1 hour.
+	Interval time.Duration
+}
+
+func (cfg RecoveryConfig) withDefaults() RecoveryConfig {
+	if cfg.IdleAfter <= 0 {
+		cfg.IdleAfter = defaultIdleAfter
+	}
+
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultRecoveryCooldown
+	}
+
+	if cfg.MaxNotifications <= 0 {
+		cfg.MaxNotifications = defaultMaxNotifications
+	}
+
+	if cfg.LinkTTL <= 0 {
+		cfg.LinkTTL = defaultRestoreLinkTTL
+	}
+
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultRecoveryInterval
+	}
+
+	return cfg
+}
+
+// RecoveryReport summarizes a run of RecoverAbandoned.
+type RecoveryReport struct {
+	Abandoned  int `json:"abandoned"`
+	Notified   int `json:"notified"`
+	Suppressed int `json:"suppressed"`
+	Failed     int `json:"failed"`
+}
+
+type RestoreCartResponse = Response[Cart]
+
+type restoreLink struct {
+	cartID    string
+	expiresAt time.Time
+}
+
+type cartNotifications struct {
+	count int
+	last  time.Time
+}
+
+// recovery keeps the restore links and the notifications sent, used by
+// the suppression rules, in memory.
+type recovery struct {
+	notifier Notifier
+	cfg      RecoveryConfig
+
+	mu        sync.Mutex
+	links     map[string]restoreLink
+	carts     map[string]cartNotifications
+	lastUser  map[string]time.Time
+	lastSweep time.Time
+}
+
+// WithRecovery enables the detection of abandoned carts, notified through
+// the Notifier. The notifications sent are kept in memory, so the
+// suppression rules start over, and carts may be notified again, when the
+// Service restarts.
+func WithRecovery(notifier Notifier, cfg RecoveryConfig) Option {
+	return func(svc *Service) {
+		svc.recovery = &recovery{
+			notifier: notifier,
+			cfg:      cfg.withDefaults(),
+			links:    make(map[string]restoreLink),
+			carts:    make(map[string]cartNotifications),
+			lastUser: make(map[string]time.Time),
+		}
+	}
+}
+
+// suppressed reports whether the Cart shouldn't be notified. It must be
+// called with the lock held.
+func (rec *recovery) suppressed(cart Cart, now time.Time) bool {
+	sent := rec.carts[cart.ID]
+	if sent.count >= rec.cfg.MaxNotifications || (sent.count > 0 && !cart.UpdatedAt.After(sent.last)) {
+		return true
+	}
+
+	last, found := rec.lastUser[cart.UserID]
+
+	return found && now.Sub(last) < rec.cfg.Cooldown
+}
+
+// RunRecovery checks for abandoned carts every RecoveryConfig.Interval
+// until the context is done.
+func (svc *Service) RunRecovery(ctx context.Context) error {
+	if svc.recovery == nil {
+		return ErrRecoveryDisabled
+	}
+
+	ticker := time.NewTicker(svc.recovery.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := svc.RecoverAbandoned(ctx, time.Now().UTC())
+		if err != nil {
+			logIfError(svc.logger, "cart recovery failed", err)
+		} else {
+			svc.logger.Info("cart recovery", "abandoned", report.Abandoned, "notified", report.Notified)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RecoverAbandoned notifies the owners of the carts idle since before
+// now minus RecoveryConfig.IdleAfter. Only non-empty carts owned by a user
+// are considered, wishlists aren't. It requires the DB to implement
+// CartQuerier.
+func (svc *Service) RecoverAbandoned(ctx context.Context, now time.Time) (RecoveryReport, error) {
+	report := RecoveryReport{}
+
+	rec := svc.recovery
+	if rec == nil {
+		return report, ErrRecoveryDisabled
+	}
+
+	querier, ok := svc.store(ctx).(CartQuerier)
+	if !ok {
+		return report, ErrNotSupported
+	}
+
+	query := CartQuery{UpdatedBefore: now.Add(-rec.cfg.IdleAfter), Limit: maxPageLimit}
+
+	for {
+		page, err := querier.QueryCarts(query)
+		if err != nil {
+			return report, err
+		}
+
+		for _, cart := range page.Carts {
+			if cart.UserID == "" || cart.Kind == WishlistCart || len(cart.Items) == 0 {
+				continue
+			}
+
+			report.Abandoned++
+
+			if err := svc.notifyAbandoned(ctx, cart, now); errors.Is(err, errSuppressed) {
+				report.Suppressed++
+			} else if err != nil {
+				report.Failed++
+
+				logIfError(svc.logger, "could not notify abandoned cart", err)
+			} else {
+				report.Notified++
+			}
+		}
+
+		if page.NextCursor == "" {
+			return report, nil
+		}
+
+		query.Cursor = page.NextCursor
+	}
+}
+
+var errSuppressed = errors.New("notification suppressed")
+
+func (svc *Service) notifyAbandoned(ctx context.Context, cart Cart, now time.Time) error {
+	rec := svc.recovery
+
+	rec.mu.Lock()
+	suppressed := rec.suppressed(cart, now)
+	rec.mu.Unlock()
+
+	if suppressed {
+		return errSuppressed
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return err
+	}
+
+	notification := Notification{
+		UserID:     cart.UserID,
+		Cart:       cart,
+		Token:      token,
+		RestoreURL: rec.cfg.RestoreURL + token,
+		ExpiresAt:  now.Add(rec.cfg.LinkTTL),
+	}
+
+	if err := rec.notifier.Notify(ctx, notification); err != nil {
+		return fmt.Errorf("could not notify cart '%s': %w", cart.ID, err)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	rec.sweep(now)
+	rec.links[token] = restoreLink{cartID: cart.ID, expiresAt: notification.ExpiresAt}
+	rec.carts[cart.ID] = cartNotifications{count: rec.carts[cart.ID].count + 1, last: now}
+	rec.lastUser[cart.UserID] = now
+
+	return nil
+}
+
+// sweep removes the expired links and the users out of their cooldown, at
+// most once per recoverySweepInterval. It must be called with the lock
+// held.
+func (rec *recovery) sweep(now time.Time) {
+	if now.Sub(rec.lastSweep) < recoverySweepInterval {
+		return
+	}
+
+	for token, link := range rec.links {
+		if now.After(link.expiresAt) {
+			delete(rec.links, token)
+		}
+	}
+
+	for userID, last := range rec.lastUser {
+		if now.Sub(last) >= rec.cfg.Cooldown {
+			delete(rec.lastUser, userID)
+		}
+	}
+
+	rec.lastSweep = now
+}
+
+// lookupRestoreLink returns the ID of the Cart the link restores.
+func (svc *Service) lookupRestoreLink(token string) (string, error) {
+	if svc.recovery == nil {
+		return "", ErrRestoreNotFound
+	}
+
+	svc.recovery.mu.Lock()
+	defer svc.recovery.mu.Unlock()
+
+	link, found := svc.recovery.links[token]
+	if !found {
+		return "", ErrRestoreNotFound
+	}
+
+	if time.Now().After(link.expiresAt) {
+		delete(svc.recovery.links, token)
+		return "", ErrRestoreExpired
+	}
+
+	return link.cartID, nil
+}
+
+// useRestoreLink removes the link once used, returning ErrRestoreNotFound
+// if it was already used.
+func (svc *Service) useRestoreLink(token string) (restoreLink, error) {
+	svc.recovery.mu.Lock()
+	defer svc.recovery.mu.Unlock()
+
+	link, found := svc.recovery.links[token]
+	if !found {
+		return link, ErrRestoreNotFound
+	}
+
+	delete(svc.recovery.links, token)
+
+	return link, nil
+}
+
+// releaseRestoreLink makes a link used by a failed restore usable again.
+func (svc *Service) releaseRestoreLink(token string, link restoreLink) {
+	svc.recovery.mu.Lock()
+	defer svc.recovery.mu.Unlock()
+
+	svc.recovery.links[token] = link
+}
+
+// Restore assigns the Cart of a recovery link to the current session, e.g:
+// after the link was opened on another device. Links can only be used once,
+// and logged-in sessions can only restore their user's carts.
+//
+// Status codes:
+//   - 200: OK
+//   - 400: No session found for request
+//   - 404: No restore link or cart found
+//   - 410: Restore link expired
+//   - 500: unexpected error
+func (svc *Service) Restore(w http.ResponseWriter, req *http.Request) {
+	usrCtx, ok := svc.fetchCtxOrExit(w, req)
+	if !ok {
+		return
+	}
+
+	token := chi.URLParam(req, "token")
+
+	cartID, err := svc.lookupRestoreLink(token)
+	if errors.Is(err, ErrRestoreExpired) {
+		svc.json(svc.writeError(w, http.StatusGone, err))
+		return
+	}
+
+	if err != nil {
+		svc.json(svc.writeError(w, http.StatusNotFound, err))
+		return
+	}
+
+	cart, err := svc.store(req.Context()).LookupCart(cartID)
+	if err == nil && usrCtx.UserID != "" && usrCtx.UserID != cart.UserID {
+		err = ErrRestoreNotFound
+	}
+
+	if err == nil {
+		err = svc.restoreCart(req.Context(), token, cartID, usrCtx.SessionToken)
+	}
+
+	if err == nil {
+		cart, err = svc.store(req.Context()).LookupCart(cartID)
+	}
+
+	switch {
+	case errors.Is(err, ErrSessionNotFound):
+		svc.json(svc.writeError(w, http.StatusBadRequest, err))
+	case errors.Is(err, ErrCartNotFound), errors.Is(err, ErrRestoreNotFound):
+		svc.json(svc.writeError(w, http.StatusNotFound, err))
+	case err != nil:
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
+	default:
+		svc.json(writeResponse(w, http.StatusOK, RestoreCartResponse{Data: cart}))
+	}
+}
+
+// restoreCart uses the link to assign the Cart to the session.
+func (svc *Service) restoreCart(ctx context.Context, token, cartID, sessionToken string) error {
+	link, err := svc.useRestoreLink(token)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.assignCartToSession(ctx, cartID, sessionToken); err != nil {
+		svc.releaseRestoreLink(token, link)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package kaimono
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	mu            sync.Mutex
+	notifications []Notification
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, notification Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.notifications = append(n.notifications, notification)
+
+	return nil
+}
+
+func TestRecoverAbandoned(t *testing.T) {
+	mock := newMockBackend()
+	notifier := &recordingNotifier{}
+	cfg := RecoveryConfig{IdleAfter: time.Hour, Cooldown: time.Minute, RestoreURL: "https://shop/recover/"}
+
+	svc, err := NewService(mock, mock, mock, nil, WithRecovery(notifier, cfg))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	owned, err := svc.createUserCart(context.Background(), "test-user", "", ShoppingCart)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	owned.Items = []CartItem{{ID: "apple", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}, Discounts: []Discount{}}}
+	if err := svc.updateCart(context.Background(), &owned); err != nil {
+		t.Fatalf("could not update cart: %v", err)
+	}
+
+	// anonymous and empty carts, and wishlists, are never notified.
+	if _, err := svc.createCart(context.Background()); err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	wishlist, err := svc.createUserCart(context.Background(), "test-user", "later", WishlistCart)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	wishlist.Items = owned.Items
+	if err := svc.updateCart(context.Background(), &wishlist); err != nil {
+		t.Fatalf("could not update cart: %v", err)
+	}
+
+	later := time.Now().Add(2 * time.Hour)
+
+	report, err := svc.RecoverAbandoned(context.Background(), later)
+	if err != nil {
+		t.Fatalf("could not recover carts: %v", err)
+	}
+
+	if report != (RecoveryReport{Abandoned: 1, Notified: 1}) {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	notification := notifier.notifications[0]
+	if notification.Cart.ID != owned.ID || notification.RestoreURL != "https://shop/recover/"+notification.Token {
+		t.Fatalf("unexpected notification: %+v", notification)
+	}
+
+	// unchanged carts aren't notified again.
+	report, err = svc.RecoverAbandoned(context.Background(), later.Add(time.Hour))
+	if err != nil || report.Suppressed != 1 || report.Notified != 0 {
+		t.Fatalf("expected the cart to be suppressed, got %+v (%v)", report, err)
+	}
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	restored := RestoreCartResponse{}
+	url := srv.URL + "/cart/recover/" + notification.Token
+
+	if code := doJSONRequest(t, http.MethodPost, url, mock.sessions[1], nil, nil); code != http.StatusNotFound {
+		t.Fatalf("other users should not restore the cart, got code %d", code)
+	}
+
+	if code := doJSONRequest(t, http.MethodPost, url, mock.sessions[2], nil, &restored); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	found, err := mock.LookupCartForSession(mock.sessions[2])
+	if err != nil || found.ID != owned.ID {
+		t.Fatalf("cart was not restored to the session: %v", err)
+	}
+
+	if code := doJSONRequest(t, http.MethodPost, url, mock.sessions[2], nil, nil); code != http.StatusNotFound {
+		t.Fatalf("links should only be used once, got code %d", code)
+	}
+
+	if code := doJSONRequest(t, http.MethodPost, srv.URL+"/cart/recover/unknown", mock.sessions[2], nil, nil); code != http.StatusNotFound {
+		t.Fatalf("got code %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestRecoverySuppression(t *testing.T) {
+	now := time.Now()
+	rec := &recovery{
+		cfg:      RecoveryConfig{MaxNotifications: 2}.withDefaults(),
+		carts:    map[string]cartNotifications{},
+		lastUser: map[string]time.Time{},
+	}
+
+	cart := Cart{ID: "cart", UserID: "user", UpdatedAt: now}
+
+	tests := []struct {
+		label    string
+		sent     cartNotifications
+		lastUser time.Time
+		want     bool
+	}{
+		{label: "never notified", want: false},
+		{label: "updated since notified", sent: cartNotifications{count: 1, last: now.Add(-time.Hour)}, want: false},
+		{label: "unchanged since notified", sent: cartNotifications{count: 1, last: now}, want: true},
+		{label: "max notifications", sent: cartNotifications{count: 2, last: now.Add(-time.Hour)}, want: true},
+		{label: "user cooldown", lastUser: now.Add(-time.Hour), want: true},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			rec.carts[cart.ID] = c.sent
+			delete(rec.lastUser, cart.UserID)
+
+			if !c.lastUser.IsZero() {
+				rec.lastUser[cart.UserID] = c.lastUser
+			}
+
+			if got := rec.suppressed(cart, now); got != c.want {
+				t.Fatalf("got %t, want %t", got, c.want)
+			}
+		})
+	}
+}
+
+func TestRecoveryDisabled(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := svc.RecoverAbandoned(context.Background(), time.Now()); !errors.Is(err, ErrRecoveryDisabled) {
+		t.Fatalf("got %v, want %v", err, ErrRecoveryDisabled)
+	}
+}
+
+func TestRecoverySweep(t *testing.T) {
+	now := time.Now()
+	rec := &recovery{
+		cfg:      RecoveryConfig{Cooldown: time.Hour}.withDefaults(),
+		links:    map[string]restoreLink{"expired": {expiresAt: now.Add(-time.Second)}, "valid": {expiresAt: now.Add(time.Hour)}},
+		lastUser: map[string]time.Time{"cooled-down": now.Add(-time.Hour), "notified": now},
+	}
+
+	rec.sweep(now)
+
+	if _, found := rec.links["expired"]; found || len(rec.links) != 1 {
+		t.Fatalf("expected the expired link to be removed, got %+v", rec.links)
+	}
+
+	if _, found := rec.lastUser["cooled-down"]; found || len(rec.lastUser) != 1 {
+		t.Fatalf("expected the users out of their cooldown to be removed, got %+v", rec.lastUser)
+	}
+}
@@ -5,3 +5,10 @@ type Request[T any] struct {
 }
 
 type UpdateCartRequest = Request[Cart]
+
+type AssignCartRequest = Request[CartAssignment]
+
+// CartAssignment specifies the session a Cart should be assigned to.
+type CartAssignment struct {
+	SessionToken string `json:"session-token"`
+}
@@ -8,23 +8,14 @@ import (
 )
 
 func writeResponse[T any](w http.ResponseWriter, code int, payload T) error {
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(code)
-
-	if err := json.NewEncoder(w).Encode(payload); err != nil {
-		return fmt.Errorf("could not encode: %w", err)
-	}
-
-	return nil
+	return writeJSON(w, code, "application/json", payload)
 }
 
-func writeError(w http.ResponseWriter, code int, err error) error {
-	w.Header().Add("Content-Type", "application/json")
+func writeJSON(w http.ResponseWriter, code int, contentType string, payload any) error {
+	w.Header().Add("Content-Type", contentType)
 	w.WriteHeader(code)
 
-	resp := ErrorResponse{Data: nil, Error: err.Error()}
-
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
 		return fmt.Errorf("could not encode: %w", err)
 	}
 
@@ -43,9 +34,12 @@ func logIfError(l *slog.Logger, msg string, err error) {
 	l.Error(msg, "error", err)
 }
 
+// ErrorResponse is the error response, unless Problems are requested.
 type ErrorResponse struct {
-	Data  any    `json:"data"`
-	Error string `json:"error"`
+	Data    any          `json:"data"`
+	Error   string       `json:"error"`
+	Code    ErrorCode    `json:"code,omitempty"`
+	Details []FieldError `json:"details,omitempty"`
 }
 
 type Response[T any] struct {
@@ -0,0 +1,101 @@
+package kaimono
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type SaveItemResponse = Response[Cart]
+type RestoreItemResponse = Response[Cart]
+
+// SaveForLater moves an item of the current session's Cart to its saved
+// items, which don't count towards the Cart's totals.
+//
+// Status codes:
+//   - 200: OK
+//   - 400: No session found for request, or the resulting Cart is invalid
+//   - 403: Session can't edit its shared cart
+//   - 404: No cart found for session or no item found in the cart
+//   - 500: unexpected error
+func (svc *Service) SaveForLater(w http.ResponseWriter, req *http.Request) {
+	svc.mutateSavedItems(w, req, func(cart *Cart, itemID string) error {
+		return cart.SaveForLater(itemID)
+	})
+}
+
+// RestoreSaved moves a saved item back to the current session's Cart. If a
+// Catalog is configured, the item is re-priced.
+//
+// Status codes:
+//   - 200: OK
+//   - 400: No session found for request, or the resulting Cart is invalid
+//   - 403: Session can't edit its shared cart
+//   - 404: No cart found for session or no saved item found in the cart
+//   - 500: unexpected error
+func (svc *Service) RestoreSaved(w http.ResponseWriter, req *http.Request) {
+	svc.mutateSavedItems(w, req, svc.restoreSaved)
+}
+
+func (svc *Service) restoreSaved(cart *Cart, itemID string) error {
+	item, err := cart.TakeSaved(itemID)
+	if err != nil {
+		return err
+	}
+
+	if err := cart.AddItem(item); err != nil {
+		return err
+	}
+
+	if svc.catalog == nil {
+		return nil
+	}
+
+	price, err := svc.catalog.LookupPrice(itemID)
+	if err != nil {
+		return err
+	}
+
+	return cart.SetPrice(itemID, price)
+}
+
+// mutateSavedItems applies fn to the session's Cart with the item ID from
+// the URL and persists the result.
+func (svc *Service) mutateSavedItems(
+	w http.ResponseWriter, req *http.Request, fn func(cart *Cart, itemID string) error,
+) {
+	usrCtx, ok := svc.fetchCtxOrExit(w, req)
+	if !ok {
+		return
+	}
+
+	found, ok := svc.lookupAccessibleCartOrExit(req.Context(), w, usrCtx, EditPermission)
+	if !ok {
+		return
+	}
+
+	cart := found.Clone()
+
+	err := fn(&cart, chi.URLParam(req, "id"))
+	if errors.Is(err, ErrItemNotFound) {
+		svc.json(svc.writeError(w, http.StatusNotFound, err))
+		return
+	}
+
+	if err == nil {
+		err = svc.updateCart(req.Context(), &cart)
+	}
+
+	if errors.As(err, &ValidationError{}) {
+		svc.json(svc.writeError(w, http.StatusBadRequest, err))
+		return
+	}
+
+	if err != nil {
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
+		return
+	}
+
+	svc.json(writeResponse(w, http.StatusOK, SaveItemResponse{Data: cart}))
+}
@@ -0,0 +1,120 @@
+package kaimono
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testCatalog map[string]Price
+
+func (catalog testCatalog) LookupPrice(itemID string) (Price, error) {
+	price, found := catalog[itemID]
+	if !found {
+		return Price{}, ErrItemNotFound
+	}
+
+	return price, nil
+}
+
+func TestSaveForLater(t *testing.T) {
+	mock := newMockBackend()
+	catalog := testCatalog{"apple": {Currency: "EUR", Value: 2}}
+
+	svc, err := NewService(mock, mock, mock, nil, WithCatalog(catalog))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	base := srv.URL + "/cart"
+	session := mock.sessions[0]
+
+	cart, err := mock.CreateCartForSession(session)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	cart.Items = []CartItem{
+		{ID: "apple", Quantity: 2, Price: Price{Currency: "EUR", Value: 1}, Discounts: []Discount{}},
+		{ID: "pear", Quantity: 1, Price: Price{Currency: "EUR", Value: 3}, Discounts: []Discount{}},
+	}
+	putCart(t, base+"/", session, cart)
+
+	saved := SaveItemResponse{}
+	if code := doJSONRequest(t, http.MethodPost, base+"/items/apple/save", session, nil, &saved); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if len(saved.Data.Items) != 1 || len(saved.Data.Saved) != 1 || saved.Data.Saved[0].Quantity != 2 {
+		t.Fatalf("unexpected cart: %+v", saved.Data)
+	}
+
+	totals, err := saved.Data.Totals()
+	if err != nil || !closeTo(totals.Total, 3) {
+		t.Fatalf("saved items should not count towards the totals, got %+v (%v)", totals, err)
+	}
+
+	restored := RestoreItemResponse{}
+	if code := doJSONRequest(t, http.MethodPost, base+"/saved/apple/restore", session, nil, &restored); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	item, found := restored.Data.Item("apple")
+	if !found || item.Quantity != 2 || item.Price.Value != 2 || len(restored.Data.Saved) != 0 {
+		t.Fatalf("expected the item to be restored and re-priced, got %+v", restored.Data)
+	}
+
+	for _, path := range []string{"/items/kiwi/save", "/saved/pear/restore"} {
+		if code := doJSONRequest(t, http.MethodPost, base+path, session, nil, nil); code != http.StatusNotFound {
+			t.Fatalf("(%s) got code %d, want %d", path, code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestSavedItemsSurviveMigration(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	anonymous := mock.sessions[2]
+
+	previous, err := mock.CreateCartForSession(anonymous)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	previous.Saved = []CartItem{{ID: "apple", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}, Discounts: []Discount{}}}
+	if err := mock.UpdateCart(previous); err != nil {
+		t.Fatalf("could not update cart: %v", err)
+	}
+
+	target, err := svc.createUserCart(context.Background(), "test-user", "", ShoppingCart)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	if err := svc.assignCartToSession(context.Background(), target.ID, anonymous); err != nil {
+		t.Fatalf("could not assign cart: %v", err)
+	}
+
+	migrated, err := mock.LookupCartForSession(anonymous)
+	if err != nil {
+		t.Fatalf("could not lookup cart: %v", err)
+	}
+
+	if migrated.ID != target.ID || len(migrated.Saved) != 1 || migrated.Saved[0].ID != "apple" {
+		t.Fatalf("expected saved items to be carried over, got %+v", migrated)
+	}
+
+	left, err := mock.LookupCart(previous.ID)
+	if err != nil || len(left.Saved) != 0 {
+		t.Fatalf("expected saved items to be moved out of the previous cart, got %+v (%v)", left, err)
+	}
+}
@@ -1,38 +1,128 @@
 package kaimono
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var (
-	ErrCartNotFound    = errors.New("cart not found")
-	ErrSessionNotFound = errors.New("session not found")
-	ErrAlreadyExists   = errors.New("already exists")
-	ErrInvalidID       = errors.New("invalid ID")
+	ErrCartNotFound     = errors.New("cart not found")
+	ErrSessionNotFound  = errors.New("session not found")
+	ErrAlreadyExists    = errors.New("already exists")
+	ErrInvalidID        = errors.New("invalid ID")
+	ErrItemNotFound     = errors.New("item not found")
+	ErrInvalidQuantity  = errors.New("invalid quantity")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrNotSupported     = errors.New("not supported by the DB")
+	ErrUserRequired     = errors.New("a logged-in user is required")
+	ErrInvalidKind      = errors.New("invalid cart kind")
+	ErrInvalidQuery     = errors.New("invalid query")
+
+	// ErrInvalidCredentials is returned by UserContextFetchers finding
+	// malformed, forged or expired credentials.
+	ErrInvalidCredentials = errors.New("invalid credentials")
 )
 
+const defaultHeartbeatInterval = 15 * time.Second
+
 type Service struct {
-	authorizer    Authorizer
-	db            DB
-	usrCtxFetcher UserContextFetcher
-	logger        *slog.Logger
+	authorizer        Authorizer
+	db                DB
+	usrCtxFetcher     UserContextFetcher
+	logger            *slog.Logger
+	events            *eventBroker
+	live              *liveHub
+	shares            ShareStore
+	catalog           Catalog
+	jobs              *jobTracker
+	recovery          *recovery
+	analytics         *analytics
+	metrics           *Metrics
+	tracer            trace.Tracer
+	propagator        propagation.TextMapPropagator
+	problemDetails    bool
+	limits            CartLimits
+	validators        []CartValidator
+	idempotency       IdempotencyStore
+	idempotencyTTL    time.Duration
+	rateLimits        *rateLimits
+	stateless         *statelessCarts
+	heartbeatInterval time.Duration
+}
+
+// Option configures optional Service behaviour.
+type Option func(svc *Service)
+
+// WithHeartbeatInterval sets how often a heartbeat is sent on idle event
+// streams, defaults to 15 seconds.
+func WithHeartbeatInterval(d time.Duration) Option {
+	return func(svc *Service) {
+		svc.heartbeatInterval = d
+	}
+}
+
+// WithCatalog sets the Catalog used to re-price items.
+func WithCatalog(catalog Catalog) Option {
+	return func(svc *Service) {
+		svc.catalog = catalog
+	}
 }
 
-func NewService(db DB, usrCtxFetcher UserContextFetcher, authorizer Authorizer, logger *slog.Logger) (*Service, error) {
+func NewService(
+	db DB, usrCtxFetcher UserContextFetcher, authorizer Authorizer, logger *slog.Logger, opts ...Option,
+) (*Service, error) {
 	if logger == nil {
 		logger = slog.New(slog.NewJSONHandler(io.Discard, nil))
 	}
 
-	return &Service{
-		authorizer:    authorizer,
-		db:            db,
-		usrCtxFetcher: usrCtxFetcher,
-		logger:        logger,
-	}, nil
+	logger = slog.New(redactingHandler{logger.Handler()})
+
+	svc := &Service{
+		authorizer:        loggedAuthorizer{authorizer: authorizer, logger: logger},
+		db:                db,
+		usrCtxFetcher:     usrCtxFetcher,
+		logger:            logger,
+		events:            newEventBroker(),
+		live:              newLiveHub(),
+		shares:            newMemoryShareStore(),
+		jobs:              newJobTracker(),
+		heartbeatInterval: defaultHeartbeatInterval,
+		limits:            DefaultCartLimits,
+		idempotency:       newMemoryIdempotencyStore(),
+		idempotencyTTL:    defaultIdempotencyTTL,
+	}
+
+	for _, opt := range opts {
+		opt(svc)
+	}
+
+	// analytics count carts as abandoned as recovery does.
+	if svc.analytics != nil && svc.analytics.cfg.IdleAfter <= 0 {
+		svc.analytics.cfg.IdleAfter = defaultIdleAfter
+		if svc.recovery != nil {
+			svc.analytics.cfg.IdleAfter = svc.recovery.cfg.IdleAfter
+		}
+	}
+
+	if svc.stateless != nil {
+		if err := svc.stateless.init(svc.catalog); err != nil {
+			return nil, err
+		}
+
+		if svc.db == nil {
+			svc.db = unsupportedDB{}
+		}
+	}
+
+	return svc, nil
 }
 
 func (svc *Service) json(err error) {
@@ -67,6 +157,16 @@ const (
 	ReadOp   OperationType = "read"
 	UpdateOp OperationType = "update"
 	DeleteOp OperationType = "delete"
+	ListOp   OperationType = "list"
+
+	// BulkDeleteOp and BulkUpdateOp guard the admin jobs deleting or
+	// updating every Cart matching a filter.
+	BulkDeleteOp OperationType = "bulk-delete"
+	BulkUpdateOp OperationType = "bulk-update"
+
+	// TransferOp guards changing the owner (see Cart.UserID) of a Cart
+	// through the admin routes, on top of UpdateOp.
+	TransferOp OperationType = "transfer"
 )
 
 type DB interface {
@@ -111,11 +211,40 @@ type DB interface {
 	AssignCartToSession(cartID, sessionToken string) error
 }
 
+// CartQuerier is implemented by DBs able to search carts. It is needed for
+// listing carts on the AdminRouter.
+type CartQuerier interface {
+	// QueryCarts returns up to query.Limit carts matching the query (see
+	// CartQuery.Matches), or all of them if the limit isn't positive,
+	// ordered by ID and starting after query.Cursor. The returned page's
+	// NextCursor is empty on the last page.
+	QueryCarts(query CartQuery) (CartPage, error)
+}
+
+// Catalog provides the current price of items. It is optional, and used
+// to re-price items restored from the saved-for-later list.
+type Catalog interface {
+	// LookupPrice returns the current price of the item.
+	//
+	// If no item could be found, it will return ErrItemNotFound.
+	LookupPrice(itemID string) (Price, error)
+}
+
+// UserCartLister is implemented by DBs able to list the carts owned by a
+// user (see Cart.UserID). It is needed for listing a user's carts.
+type UserCartLister interface {
+	// ListCartsForUser returns the carts owned by the user, or an empty
+	// slice if there are none.
+	ListCartsForUser(userID string) ([]Cart, error)
+}
+
 // UserContextFetcher encapsulates functionality
 // for fetching session tokens and user IDs from a
 // request.
 // Returns ErrSessionNotFound if no session could be
-// found.
+// found, and ErrInvalidCredentials if the request's
+// credentials are malformed. See the session package
+// for implementations.
 type UserContextFetcher interface {
 	GetUserContext(req *http.Request) (UserContext, error)
 }
@@ -128,3 +257,49 @@ type UserContext struct {
 func (u UserContext) IsLoggedIn() bool {
 	return u.UserID != ""
 }
+
+// store returns the DB to use while handling ctx, logging its errors with
+// the request's logger and tracing its calls if tracing is enabled. Requests
+// with a stateless Cart use the DB holding it.
+func (svc *Service) store(ctx context.Context) DB {
+	db := svc.db
+	if requestDB, ok := ctx.Value(requestDBKey{}).(DB); ok {
+		db = requestDB
+	}
+	if svc.tracer != nil {
+		db = traceDB(ctx, svc.tracer, db)
+	}
+
+	return logDB(ctx, svc.loggerFor(ctx), db)
+}
+
+// decorateDB returns base, also implementing CartQuerier and UserCartLister
+// through querier and lister when db implements them, so decorators don't
+// hide the optional interfaces.
+func decorateDB(
+	db, base DB, querier func(CartQuerier) CartQuerier, lister func(UserCartLister) UserCartLister,
+) DB {
+	dbQuerier, canQuery := db.(CartQuerier)
+	dbLister, canList := db.(UserCartLister)
+
+	switch {
+	case canQuery && canList:
+		return struct {
+			DB
+			CartQuerier
+			UserCartLister
+		}{base, querier(dbQuerier), lister(dbLister)}
+	case canQuery:
+		return struct {
+			DB
+			CartQuerier
+		}{base, querier(dbQuerier)}
+	case canList:
+		return struct {
+			DB
+			UserCartLister
+		}{base, lister(dbLister)}
+	default:
+		return base
+	}
+}
@@ -1,9 +1,10 @@
 package kaimono
 
 import (
-	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
 
 	"github.com/google/uuid"
@@ -129,12 +130,13 @@ func (mock *mockBackend) DeleteCart(cartID string) error {
 		return ErrCartNotFound
 	}
 
-	// delete entry for session, if found
+	// delete the entries for sessions, shifting the ones after the cart.
 	for key, indx := range mock.data {
-		cart := mock.carts[indx]
-		if cart.ID == cartID {
+		switch {
+		case indx == index:
 			delete(mock.data, key)
-			break
+		case indx > index:
+			mock.data[key] = indx - 1
 		}
 	}
 
@@ -196,8 +198,71 @@ func (mock *mockBackend) LookupCartForSession(sessionToken string) (Cart, error)
 	return mock.carts[cartIndex], nil
 }
 
-func (mock *mockBackend) AssignCartToSession(string, string) error {
-	return errors.New("not implemented")
+func (mock *mockBackend) ListCartsForUser(userID string) ([]Cart, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	carts := []Cart{}
+
+	for _, cart := range mock.carts {
+		if cart.UserID == userID {
+			carts = append(carts, cart)
+		}
+	}
+
+	return carts, nil
+}
+
+func (mock *mockBackend) QueryCarts(query CartQuery) (CartPage, error) {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	carts := slices.Clone(mock.carts)
+	slices.SortFunc(carts, func(a, b Cart) int { return strings.Compare(a.ID, b.ID) })
+
+	page := CartPage{Carts: []Cart{}}
+
+	for _, cart := range carts {
+		if cart.ID <= query.Cursor || !query.Matches(cart) {
+			continue
+		}
+
+		if query.Limit > 0 && len(page.Carts) == query.Limit {
+			page.NextCursor = page.Carts[len(page.Carts)-1].ID
+			break
+		}
+
+		page.Carts = append(page.Carts, cart)
+	}
+
+	return page, nil
+}
+
+func (mock *mockBackend) AssignCartToSession(cartID, sessionToken string) error {
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+
+	found := false
+
+	for _, s := range mock.sessions {
+		if s == sessionToken {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return ErrSessionNotFound
+	}
+
+	for k, cart := range mock.carts {
+		if cart.ID == cartID {
+			mock.data[sessionToken] = k
+			return nil
+		}
+	}
+
+	return ErrCartNotFound
 }
 
 func (mock *mockBackend) AuthorizeUser(req *http.Request, op Operation, resourceID string) error {
@@ -214,5 +279,5 @@ func (mock *mockBackend) AuthorizeUser(req *http.Request, op Operation, resource
 }
 
 func removeAt[T any](arr []T, index int) []T {
-	return append(arr[:index-1], arr[index:]...)
+	return append(arr[:index], arr[index+1:]...)
 }
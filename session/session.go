@@ -0,0 +1,131 @@
+// Package session provides implementations of kaimono.UserContextFetcher,
+// reading the session from a cookie, a header, a bearer token or an
+// HMAC-signed token.
+//
+// All of them return kaimono.ErrSessionNotFound if the request carries no
+// credentials, and kaimono.ErrInvalidCredentials if they are malformed.
+package session
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+const (
+	DefaultCookieName = "kaimono-session"
+	DefaultHeaderName = "X-Session-Token"
+
+	authorizationHeader = "Authorization"
+	bearerScheme        = "Bearer"
+)
+
+// Cookie reads the session token from the cookie called Name, defaults to
+// DefaultCookieName. If UserHeader is set, the user ID is read from that
+// header, e.g: set by an upstream gateway.
+type Cookie struct {
+	Name       string
+	UserHeader string
+}
+
+func (f Cookie) GetUserContext(req *http.Request) (kaimono.UserContext, error) {
+	name := f.Name
+	if name == "" {
+		name = DefaultCookieName
+	}
+
+	cookie, err := req.Cookie(name)
+	if err != nil {
+		return kaimono.UserContext{}, kaimono.ErrSessionNotFound
+	}
+
+	return withUser(req, cookie.Value, f.UserHeader)
+}
+
+// Header reads the session token from the header called Name, defaults to
+// DefaultHeaderName. If UserHeader is set, the user ID is read from that
+// header.
+type Header struct {
+	Name       string
+	UserHeader string
+}
+
+func (f Header) GetUserContext(req *http.Request) (kaimono.UserContext, error) {
+	name := f.Name
+	if name == "" {
+		name = DefaultHeaderName
+	}
+
+	return withUser(req, req.Header.Get(name), f.UserHeader)
+}
+
+// Bearer reads the session token from an "Authorization: Bearer <token>"
+// header. If UserHeader is set, the user ID is read from that header.
+type Bearer struct {
+	UserHeader string
+}
+
+func (f Bearer) GetUserContext(req *http.Request) (kaimono.UserContext, error) {
+	token, err := bearerToken(req)
+	if err != nil {
+		return kaimono.UserContext{}, err
+	}
+
+	return withUser(req, token, f.UserHeader)
+}
+
+// bearerToken returns the token of the Authorization header, which must use
+// the Bearer scheme.
+func bearerToken(req *http.Request) (string, error) {
+	header := req.Header.Get(authorizationHeader)
+	if header == "" {
+		return "", kaimono.ErrSessionNotFound
+	}
+
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, bearerScheme) {
+		return "", fmt.Errorf("%w: expected a Bearer token", kaimono.ErrInvalidCredentials)
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("%w: empty Bearer token", kaimono.ErrInvalidCredentials)
+	}
+
+	return token, nil
+}
+
+func withUser(req *http.Request, sessionToken, userHeader string) (kaimono.UserContext, error) {
+	if sessionToken == "" {
+		return kaimono.UserContext{}, kaimono.ErrSessionNotFound
+	}
+
+	usrCtx := kaimono.UserContext{SessionToken: sessionToken}
+
+	if userHeader != "" {
+		usrCtx.UserID = req.Header.Get(userHeader)
+	}
+
+	return usrCtx, nil
+}
+
+// Chain tries each fetcher in order until one finds a session. A fetcher
+// finding malformed credentials stops the chain, so they aren't silently
+// ignored. Returns kaimono.ErrSessionNotFound if no fetcher found a session.
+type Chain []kaimono.UserContextFetcher
+
+func (c Chain) GetUserContext(req *http.Request) (kaimono.UserContext, error) {
+	for _, fetcher := range c {
+		usrCtx, err := fetcher.GetUserContext(req)
+		if errors.Is(err, kaimono.ErrSessionNotFound) {
+			continue
+		}
+
+		return usrCtx, err
+	}
+
+	return kaimono.UserContext{}, kaimono.ErrSessionNotFound
+}
@@ -0,0 +1,137 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+func TestFetchers(t *testing.T) {
+	key := []byte("secret")
+	now := time.Unix(1_700_000_000, 0)
+
+	sign := func(claims Claims) string {
+		token, err := Sign(key, claims)
+		if err != nil {
+			t.Fatalf("could not sign token: %v", err)
+		}
+
+		return token
+	}
+
+	valid := sign(Claims{UserID: "user", SessionToken: "session", ExpiresAt: now.Add(time.Hour).Unix()})
+	expired := sign(Claims{UserID: "user", SessionToken: "session", ExpiresAt: now.Add(-time.Hour).Unix()})
+	noSession := sign(Claims{UserID: "user", ExpiresAt: now.Add(time.Hour).Unix()})
+
+	forged, err := Sign([]byte("other"), Claims{SessionToken: "session", ExpiresAt: now.Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatalf("could not sign token: %v", err)
+	}
+
+	signed := Signed{Key: key, Now: func() time.Time { return now }}
+
+	tests := []struct {
+		label   string
+		fetcher kaimono.UserContextFetcher
+		headers map[string]string
+		cookie  string
+		want    kaimono.UserContext
+		err     error
+	}{
+		{"cookie", Cookie{}, nil, "session", kaimono.UserContext{SessionToken: "session"}, nil},
+		{
+			"cookie with user header", Cookie{UserHeader: "X-User"}, map[string]string{"X-User": "user"}, "session",
+			kaimono.UserContext{UserID: "user", SessionToken: "session"}, nil,
+		},
+		{"no cookie", Cookie{}, nil, "", kaimono.UserContext{}, kaimono.ErrSessionNotFound},
+		{
+			"header", Header{Name: "X-Session"}, map[string]string{"X-Session": "session"}, "",
+			kaimono.UserContext{SessionToken: "session"}, nil,
+		},
+		{"no header", Header{}, nil, "", kaimono.UserContext{}, kaimono.ErrSessionNotFound},
+		{
+			"bearer", Bearer{}, map[string]string{"Authorization": "Bearer session"}, "",
+			kaimono.UserContext{SessionToken: "session"}, nil,
+		},
+		{"no bearer", Bearer{}, nil, "", kaimono.UserContext{}, kaimono.ErrSessionNotFound},
+		{
+			"basic auth", Bearer{}, map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, "",
+			kaimono.UserContext{}, kaimono.ErrInvalidCredentials,
+		},
+		{
+			"empty bearer", Bearer{}, map[string]string{"Authorization": "Bearer "}, "",
+			kaimono.UserContext{}, kaimono.ErrInvalidCredentials,
+		},
+		{
+			"signed", signed, map[string]string{"Authorization": "Bearer " + valid}, "",
+			kaimono.UserContext{UserID: "user", SessionToken: "session"}, nil,
+		},
+		{
+			"signed cookie", Signed{Key: key, Cookie: "token", Now: signed.Now}, nil, valid,
+			kaimono.UserContext{UserID: "user", SessionToken: "session"}, nil,
+		},
+		{"no signed token", signed, nil, "", kaimono.UserContext{}, kaimono.ErrSessionNotFound},
+		{
+			"expired", signed, map[string]string{"Authorization": "Bearer " + expired}, "",
+			kaimono.UserContext{}, kaimono.ErrInvalidCredentials,
+		},
+		{
+			"leeway", Signed{Key: key, Leeway: 2 * time.Hour, Now: signed.Now}, map[string]string{"Authorization": "Bearer " + expired}, "",
+			kaimono.UserContext{UserID: "user", SessionToken: "session"}, nil,
+		},
+		{
+			"forged", signed, map[string]string{"Authorization": "Bearer " + forged}, "",
+			kaimono.UserContext{}, kaimono.ErrInvalidCredentials,
+		},
+		{
+			"malformed", signed, map[string]string{"Authorization": "Bearer not-a-token"}, "",
+			kaimono.UserContext{}, kaimono.ErrInvalidCredentials,
+		},
+		{
+			"missing sid", signed, map[string]string{"Authorization": "Bearer " + noSession}, "",
+			kaimono.UserContext{}, kaimono.ErrInvalidCredentials,
+		},
+		{"missing key", Signed{}, nil, "", kaimono.UserContext{}, ErrMissingKey},
+		{
+			"chain falls through", Chain{signed, Header{}}, map[string]string{DefaultHeaderName: "session"}, "",
+			kaimono.UserContext{SessionToken: "session"}, nil,
+		},
+		{
+			"chain stops at invalid credentials", Chain{signed, Header{}},
+			map[string]string{"Authorization": "Bearer " + expired, DefaultHeaderName: "session"}, "",
+			kaimono.UserContext{}, kaimono.ErrInvalidCredentials,
+		},
+		{"empty chain", Chain{}, nil, "", kaimono.UserContext{}, kaimono.ErrSessionNotFound},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for name, value := range c.headers {
+				req.Header.Set(name, value)
+			}
+
+			if c.cookie != "" {
+				name := DefaultCookieName
+				if f, ok := c.fetcher.(Signed); ok {
+					name = f.Cookie
+				}
+
+				req.AddCookie(&http.Cookie{Name: name, Value: c.cookie})
+			}
+
+			got, err := c.fetcher.GetUserContext(req)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("got error %v, want %v", err, c.err)
+			}
+
+			if got != c.want {
+				t.Fatalf("got %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
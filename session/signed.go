@@ -0,0 +1,172 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aalbacetef/kaimono"
+)
+
+const (
+	signingAlgorithm = "HS256"
+	tokenParts       = 3
+)
+
+var ErrMissingKey = errors.New("a signing key is required")
+
+// Claims are the claims of a signed token. The user ID is the standard
+// "sub" claim and the session token the "sid" claim, times are Unix
+// timestamps in seconds.
+type Claims struct {
+	UserID       string `json:"sub,omitempty"`
+	SessionToken string `json:"sid"`
+	ExpiresAt    int64  `json:"exp"`
+	NotBefore    int64  `json:"nbf,omitempty"`
+	IssuedAt     int64  `json:"iat,omitempty"`
+}
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+// Signed reads an HS256-signed JWT from an "Authorization: Bearer" header,
+// or from the cookie called Cookie if set. The token is verified with Key,
+// and must carry a session ("sid") and an expiry ("exp"). Leeway allows for
+// clock skew when checking the expiry.
+type Signed struct {
+	Key    []byte
+	Cookie string
+	Leeway time.Duration
+
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+func (f Signed) GetUserContext(req *http.Request) (kaimono.UserContext, error) {
+	if len(f.Key) == 0 {
+		return kaimono.UserContext{}, ErrMissingKey
+	}
+
+	token, err := f.token(req)
+	if err != nil {
+		return kaimono.UserContext{}, err
+	}
+
+	claims, err := Verify(f.Key, token)
+	if err != nil {
+		return kaimono.UserContext{}, err
+	}
+
+	now := time.Now()
+	if f.Now != nil {
+		now = f.Now()
+	}
+
+	if !now.Before(time.Unix(claims.ExpiresAt, 0).Add(f.Leeway)) {
+		return kaimono.UserContext{}, fmt.Errorf("%w: token expired", kaimono.ErrInvalidCredentials)
+	}
+
+	if claims.NotBefore != 0 && now.Add(f.Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return kaimono.UserContext{}, fmt.Errorf("%w: token not valid yet", kaimono.ErrInvalidCredentials)
+	}
+
+	return kaimono.UserContext{UserID: claims.UserID, SessionToken: claims.SessionToken}, nil
+}
+
+func (f Signed) token(req *http.Request) (string, error) {
+	if f.Cookie == "" {
+		return bearerToken(req)
+	}
+
+	cookie, err := req.Cookie(f.Cookie)
+	if err != nil || cookie.Value == "" {
+		return "", kaimono.ErrSessionNotFound
+	}
+
+	return cookie.Value, nil
+}
+
+// Sign returns the HS256-signed token holding the claims, e.g: to issue
+// tokens verified by Signed.
+func Sign(key []byte, claims Claims) (string, error) {
+	if len(key) == 0 {
+		return "", ErrMissingKey
+	}
+
+	header, err := json.Marshal(tokenHeader{Algorithm: signingAlgorithm, Type: "JWT"})
+	if err != nil {
+		return "", fmt.Errorf("could not encode header: %w", err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("could not encode claims: %w", err)
+	}
+
+	signed := encodeSegment(header) + "." + encodeSegment(payload)
+
+	return signed + "." + encodeSegment(signature(key, signed)), nil
+}
+
+// Verify checks the token's signature and returns its claims. It doesn't
+// check their validity, see Signed for that.
+func Verify(key []byte, token string) (Claims, error) {
+	claims := Claims{}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != tokenParts {
+		return claims, fmt.Errorf("%w: malformed token", kaimono.ErrInvalidCredentials)
+	}
+
+	sig, err := decodeSegment(parts[2])
+	if err != nil || !hmac.Equal(sig, signature(key, parts[0]+"."+parts[1])) {
+		return claims, fmt.Errorf("%w: invalid signature", kaimono.ErrInvalidCredentials)
+	}
+
+	header := tokenHeader{}
+	if err := decodeJSONSegment(parts[0], &header); err != nil || header.Algorithm != signingAlgorithm {
+		return claims, fmt.Errorf("%w: unsupported token algorithm", kaimono.ErrInvalidCredentials)
+	}
+
+	if err := decodeJSONSegment(parts[1], &claims); err != nil {
+		return claims, fmt.Errorf("%w: malformed claims: %w", kaimono.ErrInvalidCredentials, err)
+	}
+
+	if claims.SessionToken == "" || claims.ExpiresAt == 0 {
+		return claims, fmt.Errorf("%w: the sid and exp claims are required", kaimono.ErrInvalidCredentials)
+	}
+
+	return claims, nil
+}
+
+func signature(key []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signed))
+
+	return mac.Sum(nil)
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(segment)
+}
+
+func decodeJSONSegment(segment string, v any) error {
+	data, err := decodeSegment(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
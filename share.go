@@ -0,0 +1,569 @@
+package kaimono
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultShareTTL    = 7 * 24 * time.Hour
+	shareTokenBytes    = 32
+	shareSweepInterval = time.Minute
+)
+
+var (
+	ErrShareNotFound     = errors.New("share not found")
+	ErrShareExpired      = errors.New("share expired")
+	ErrReadOnly          = errors.New("cart is read-only for this session")
+	ErrInvalidPermission = errors.New("invalid permission")
+	ErrAccessRevoked     = errors.New("access to shared cart was revoked or expired")
+)
+
+type SharePermission string
+
+const (
+	ViewPermission SharePermission = "view"
+	EditPermission SharePermission = "edit"
+)
+
+// allows reports whether p grants the needed permission.
+func (p SharePermission) allows(needed SharePermission) bool {
+	return p == EditPermission || p == needed
+}
+
+// Share grants access to a Cart to anyone holding its token, until it
+// expires or is revoked.
+type Share struct {
+	Token      string          `json:"token"`
+	CartID     string          `json:"cart-id"`
+	Permission SharePermission `json:"permission"`
+	CreatedAt  time.Time       `json:"created-at"`
+	ExpiresAt  time.Time       `json:"expires-at"`
+}
+
+func (s Share) Expired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt)
+}
+
+// ShareStore persists shares and the sessions that were attached to a
+// Cart through them.
+type ShareStore interface {
+	CreateShare(share Share) error
+
+	// LookupShare returns ErrShareNotFound if the share doesn't exist or was
+	// revoked. Expired shares may be forgotten.
+	LookupShare(token string) (Share, error)
+
+	// RevokeShare returns ErrShareNotFound if the share doesn't exist.
+	RevokeShare(token string) error
+
+	ListShares(cartID string) ([]Share, error)
+
+	// GrantAccess records that the session was attached to the share's Cart.
+	GrantAccess(sessionToken string, share Share) error
+
+	// LookupGrant returns the token of the share through which the session
+	// was attached to the Cart, or ErrShareNotFound if it wasn't.
+	LookupGrant(sessionToken, cartID string) (string, error)
+}
+
+// WithShareStore sets the store used for shared carts, an in-memory store
+// is used otherwise.
+func WithShareStore(store ShareStore) Option {
+	return func(svc *Service) {
+		svc.shares = store
+	}
+}
+
+func newShareToken() (string, error) {
+	buf := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("could not generate token: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// checkAccess returns nil if the session has the needed permission on the
+// Cart. Sessions attached through a share have the share's permission for
+// as long as it is valid, every other session is considered an owner.
+func (svc *Service) checkAccess(sessionToken, cartID string, needed SharePermission) error {
+	perm, err := svc.sessionPermission(sessionToken, cartID)
+	if err != nil {
+		return err
+	}
+
+	if !perm.allows(needed) {
+		return ErrReadOnly
+	}
+
+	return nil
+}
+
+// checkOwner returns ErrReadOnly if the session was attached through a share.
+func (svc *Service) checkOwner(sessionToken, cartID string) error {
+	_, err := svc.shares.LookupGrant(sessionToken, cartID)
+	if errors.Is(err, ErrShareNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return ErrReadOnly
+}
+
+func (svc *Service) sessionPermission(sessionToken, cartID string) (SharePermission, error) {
+	token, err := svc.shares.LookupGrant(sessionToken, cartID)
+	if errors.Is(err, ErrShareNotFound) {
+		return EditPermission, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	share, err := svc.shares.LookupShare(token)
+	if errors.Is(err, ErrShareNotFound) {
+		return "", ErrAccessRevoked
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if share.Expired(time.Now()) {
+		return "", ErrAccessRevoked
+	}
+
+	return share.Permission, nil
+}
+
+// memoryShareStore is the default, in-memory ShareStore. Expired shares are
+// swept, but not the grants made through them, as sessions without a grant
+// own the Cart. Sessions hold a single Cart, so they have at most one grant.
+type memoryShareStore struct {
+	mu        sync.RWMutex
+	shares    map[string]Share
+	grants    map[string]grant
+	lastSweep time.Time
+}
+
+type grant struct {
+	cartID string
+	token  string
+}
+
+func newMemoryShareStore() *memoryShareStore {
+	return &memoryShareStore{
+		shares: make(map[string]Share),
+		grants: make(map[string]grant),
+	}
+}
+
+func (store *memoryShareStore) CreateShare(share Share) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.sweep(time.Now())
+
+	if _, found := store.shares[share.Token]; found {
+		return ErrAlreadyExists
+	}
+
+	store.shares[share.Token] = share
+
+	return nil
+}
+
+func (store *memoryShareStore) LookupShare(token string) (Share, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	share, found := store.shares[token]
+	if !found {
+		return Share{}, ErrShareNotFound
+	}
+
+	return share, nil
+}
+
+func (store *memoryShareStore) RevokeShare(token string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, found := store.shares[token]; !found {
+		return ErrShareNotFound
+	}
+
+	delete(store.shares, token)
+
+	return nil
+}
+
+func (store *memoryShareStore) ListShares(cartID string) ([]Share, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	shares := []Share{}
+
+	for _, share := range store.shares {
+		if share.CartID == cartID {
+			shares = append(shares, share)
+		}
+	}
+
+	return shares, nil
+}
+
+func (store *memoryShareStore) GrantAccess(sessionToken string, share Share) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.grants[sessionToken] = grant{cartID: share.CartID, token: share.Token}
+
+	return nil
+}
+
+func (store *memoryShareStore) LookupGrant(sessionToken, cartID string) (string, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	found, ok := store.grants[sessionToken]
+	if !ok || found.cartID != cartID {
+		return "", ErrShareNotFound
+	}
+
+	return found.token, nil
+}
+
+// sweep removes the expired shares, at most once per shareSweepInterval.
+func (store *memoryShareStore) sweep(now time.Time) {
+	if now.Sub(store.lastSweep) < shareSweepInterval {
+		return
+	}
+
+	for token, share := range store.shares {
+		if share.Expired(now) {
+			delete(store.shares, token)
+		}
+	}
+
+	store.lastSweep = now
+}
+
+// ShareRequest specifies the permission granted by a new share and how long
+// it lasts (e.g: "24h"), defaulting to 7 days.
+type ShareRequest struct {
+	Permission SharePermission `json:"permission"`
+	ExpiresIn  string          `json:"expires-in"`
+}
+
+// SharedCart is a Cart accessed through a share.
+type SharedCart struct {
+	Cart       Cart            `json:"cart"`
+	Permission SharePermission `json:"permission"`
+	ExpiresAt  time.Time       `json:"expires-at"`
+}
+
+type CreateShareRequest = Request[ShareRequest]
+type CreateShareResponse = Response[Share]
+type ListSharesResponse = Response[[]Share]
+type SharedCartResponse = Response[SharedCart]
+
+// writeShareError maps the errors returned while accessing shares.
+func (svc *Service) writeShareError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, ErrShareNotFound), errors.Is(err, ErrCartNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, ErrShareExpired):
+		code = http.StatusGone
+	case errors.Is(err, ErrReadOnly), errors.Is(err, ErrAccessRevoked):
+		code = http.StatusForbidden
+	case errors.Is(err, ErrSessionNotFound), errors.Is(err, ErrInvalidPermission):
+		code = http.StatusBadRequest
+	}
+
+	svc.json(svc.writeError(w, code, err))
+}
+
+// lookupAccessibleCartOrExit looks up the session's Cart and checks the
+// session has the needed permission on it, writing the error response if
+// either fails.
+func (svc *Service) lookupAccessibleCartOrExit(
+	ctx context.Context, w http.ResponseWriter, usrCtx UserContext, needed SharePermission,
+) (Cart, bool) {
+	cart, ok := svc.lookupSessionCartOrExit(ctx, w, usrCtx)
+	if !ok {
+		return cart, false
+	}
+
+	if err := svc.checkAccess(usrCtx.SessionToken, cart.ID, needed); err != nil {
+		svc.writeShareError(w, err)
+		return cart, false
+	}
+
+	return cart, true
+}
+
+// CreateShare creates a share for the current session's Cart. Sessions can't
+// grant more than their own permission.
+//
+// Status codes:
+//   - 201: Created successfully
+//   - 400: No session found for request or invalid request
+//   - 403: Session's permission is lower than the requested one
+//   - 404: No cart found for session
+//   - 500: unexpected error
+func (svc *Service) CreateShare(w http.ResponseWriter, req *http.Request) {
+	usrCtx, ok := svc.fetchCtxOrExit(w, req)
+	if !ok {
+		return
+	}
+
+	cart, ok := svc.lookupSessionCartOrExit(req.Context(), w, usrCtx)
+	if !ok {
+		return
+	}
+
+	payload := CreateShareRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+		svc.json(svc.writeError(w, http.StatusBadRequest, fmt.Errorf("could not decode request: %w", err)))
+		return
+	}
+
+	share, err := newShare(cart.ID, payload.Data)
+	if err != nil {
+		svc.writeShareError(w, err)
+		return
+	}
+
+	if err := svc.checkAccess(usrCtx.SessionToken, cart.ID, share.Permission); err != nil {
+		svc.writeShareError(w, err)
+		return
+	}
+
+	if err := svc.shares.CreateShare(share); err != nil {
+		svc.writeShareError(w, err)
+		return
+	}
+
+	svc.json(writeResponse(w, http.StatusCreated, CreateShareResponse{Data: share}))
+}
+
+func newShare(cartID string, payload ShareRequest) (Share, error) {
+	if payload.Permission != ViewPermission && payload.Permission != EditPermission {
+		return Share{}, fmt.Errorf("%w: '%s'", ErrInvalidPermission, payload.Permission)
+	}
+
+	ttl := defaultShareTTL
+
+	if payload.ExpiresIn != "" {
+		d, err := time.ParseDuration(payload.ExpiresIn)
+		if err != nil || d <= 0 {
+			return Share{}, fmt.Errorf("%w: invalid expiry '%s'", ErrInvalidPermission, payload.ExpiresIn)
+		}
+
+		ttl = d
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		return Share{}, err
+	}
+
+	now := time.Now()
+
+	return Share{
+		Token:      token,
+		CartID:     cartID,
+		Permission: payload.Permission,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(ttl),
+	}, nil
+}
+
+// ListShares lists the shares of the current session's Cart.
+//
+// Status codes:
+//   - 200: OK
+//   - 400: No session found for request
+//   - 403: Session can't edit the cart
+//   - 404: No cart found for session
+//   - 500: unexpected error
+func (svc *Service) ListShares(w http.ResponseWriter, req *http.Request) {
+	usrCtx, ok := svc.fetchCtxOrExit(w, req)
+	if !ok {
+		return
+	}
+
+	cart, ok := svc.lookupSessionCartOrExit(req.Context(), w, usrCtx)
+	if !ok {
+		return
+	}
+
+	if err := svc.checkAccess(usrCtx.SessionToken, cart.ID, EditPermission); err != nil {
+		svc.writeShareError(w, err)
+		return
+	}
+
+	shares, err := svc.shares.ListShares(cart.ID)
+	if err != nil {
+		svc.writeShareError(w, err)
+		return
+	}
+
+	svc.json(writeResponse(w, http.StatusOK, ListSharesResponse{Data: shares}))
+}
+
+// RevokeShare revokes a share of the current session's Cart. Sessions
+// attached through it lose access to the Cart.
+//
+// Status codes:
+//   - 204: Revoked successfully
+//   - 400: No session found for request
+//   - 403: Session can't edit the cart
+//   - 404: No cart found for session or no share found for the cart
+//   - 500: unexpected error
+func (svc *Service) RevokeShare(w http.ResponseWriter, req *http.Request) {
+	usrCtx, ok := svc.fetchCtxOrExit(w, req)
+	if !ok {
+		return
+	}
+
+	cart, ok := svc.lookupSessionCartOrExit(req.Context(), w, usrCtx)
+	if !ok {
+		return
+	}
+
+	if err := svc.checkAccess(usrCtx.SessionToken, cart.ID, EditPermission); err != nil {
+		svc.writeShareError(w, err)
+		return
+	}
+
+	share, err := svc.shares.LookupShare(chi.URLParam(req, "token"))
+	if err == nil && share.CartID != cart.ID {
+		err = ErrShareNotFound
+	}
+
+	if err == nil {
+		err = svc.shares.RevokeShare(share.Token)
+	}
+
+	if err != nil {
+		svc.writeShareError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookupValidShare returns the share and its Cart, if the share hasn't expired.
+func (svc *Service) lookupValidShare(ctx context.Context, token string) (Share, Cart, error) {
+	share, err := svc.shares.LookupShare(token)
+	if err != nil {
+		return share, Cart{}, err
+	}
+
+	if share.Expired(time.Now()) {
+		return share, Cart{}, ErrShareExpired
+	}
+
+	cart, err := svc.store(ctx).LookupCart(share.CartID)
+
+	return share, cart, err
+}
+
+// GetShared returns the Cart the share grants access to. No session is needed.
+//
+// Status codes:
+//   - 200: OK
+//   - 404: No share or cart found
+//   - 410: Share expired
+//   - 500: unexpected error
+func (svc *Service) GetShared(w http.ResponseWriter, req *http.Request) {
+	share, cart, err := svc.lookupValidShare(req.Context(), chi.URLParam(req, "token"))
+	if err != nil {
+		svc.writeShareError(w, err)
+		return
+	}
+
+	resp := SharedCartResponse{
+		Data: SharedCart{Cart: cart, Permission: share.Permission, ExpiresAt: share.ExpiresAt},
+	}
+
+	svc.json(writeResponse(w, http.StatusOK, resp))
+}
+
+// AttachShared assigns the shared Cart to the current session, which is
+// then granted the share's permission on it.
+//
+// Status codes:
+//   - 200: Attached successfully
+//   - 400: No session found for request
+//   - 404: No share or cart found
+//   - 410: Share expired
+//   - 500: unexpected error
+func (svc *Service) AttachShared(w http.ResponseWriter, req *http.Request) {
+	usrCtx, ok := svc.fetchCtxOrExit(w, req)
+	if !ok {
+		return
+	}
+
+	share, cart, err := svc.lookupValidShare(req.Context(), chi.URLParam(req, "token"))
+	if err != nil {
+		svc.writeShareError(w, err)
+		return
+	}
+
+	if err := svc.attachShare(req.Context(), usrCtx.SessionToken, share); err != nil {
+		svc.writeShareError(w, err)
+		return
+	}
+
+	perm, err := svc.sessionPermission(usrCtx.SessionToken, cart.ID)
+	if err != nil {
+		svc.writeShareError(w, err)
+		return
+	}
+
+	resp := SharedCartResponse{
+		Data: SharedCart{Cart: cart, Permission: perm, ExpiresAt: share.ExpiresAt},
+	}
+
+	svc.json(writeResponse(w, http.StatusOK, resp))
+}
+
+// attachShare assigns the share's Cart to the session, carrying over the
+// items it saved for later. Owners attaching their own Cart keep their
+// access.
+func (svc *Service) attachShare(ctx context.Context, sessionToken string, share Share) error {
+	current, err := svc.store(ctx).LookupCartForSession(sessionToken)
+	if err != nil && !errors.Is(err, ErrCartNotFound) {
+		return err
+	}
+
+	if err == nil && current.ID == share.CartID {
+		if ownerErr := svc.checkOwner(sessionToken, share.CartID); ownerErr == nil {
+			return nil
+		}
+	}
+
+	if err := svc.assignCartToSession(ctx, share.CartID, sessionToken); err != nil {
+		return err
+	}
+
+	return svc.shares.GrantAccess(sessionToken, share)
+}
@@ -0,0 +1,298 @@
+package kaimono
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func doJSONRequest(t *testing.T, method, url, sessionToken string, body any, out any) int {
+	t.Helper()
+
+	var reader *bytes.Reader
+
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("could not encode body: %v", err)
+		}
+
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatalf("could not make request: %v", err)
+	}
+
+	if sessionToken != "" {
+		setTestCookie(req, sessionToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("could not do request: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode < http.StatusBadRequest {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func newShareTestServer(t *testing.T) (*mockBackend, Cart, string) {
+	t.Helper()
+
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	cart, err := mock.CreateCartForSession(mock.sessions[0])
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	return mock, cart, srv.URL + "/cart"
+}
+
+func TestShareLinks(t *testing.T) {
+	mock, cart, base := newShareTestServer(t)
+	owner, guest := mock.sessions[0], mock.sessions[2]
+
+	created := CreateShareResponse{}
+	req := CreateShareRequest{Data: ShareRequest{Permission: ViewPermission, ExpiresIn: "1h"}}
+
+	if code := doJSONRequest(t, http.MethodPost, base+"/shares", owner, req, &created); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	share := created.Data
+	if share.CartID != cart.ID || share.Token == "" || share.ExpiresAt.Sub(share.CreatedAt) != time.Hour {
+		t.Fatalf("unexpected share: %+v", share)
+	}
+
+	shared := SharedCartResponse{}
+	if code := doJSONRequest(t, http.MethodGet, base+"/shared/"+share.Token, "", nil, &shared); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if shared.Data.Cart.ID != cart.ID || shared.Data.Permission != ViewPermission {
+		t.Fatalf("unexpected shared cart: %+v", shared.Data)
+	}
+
+	if code := doJSONRequest(t, http.MethodPost, base+"/shared/"+share.Token+"/attach", guest, nil, &shared); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if code := doJSONRequest(t, http.MethodGet, base+"/", guest, nil, nil); code != http.StatusOK {
+		t.Fatalf("viewer should read the cart, got code %d", code)
+	}
+
+	updated := cart.Clone()
+	updated.Items = append(updated.Items, CartItem{ID: "apple", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}})
+
+	tests := []struct {
+		label    string
+		method   string
+		url      string
+		body     any
+		wantCode int
+	}{
+		{
+			label:    "viewers can't update the cart",
+			method:   http.MethodPut,
+			url:      base + "/",
+			body:     UpdateCartRequest{Data: updated},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			label:    "viewers can't delete the cart",
+			method:   http.MethodDelete,
+			url:      base + "/",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			label:    "viewers can't grant edit access",
+			method:   http.MethodPost,
+			url:      base + "/shares",
+			body:     CreateShareRequest{Data: ShareRequest{Permission: EditPermission}},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			label:    "viewers can't list shares",
+			method:   http.MethodGet,
+			url:      base + "/shares",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			if code := doJSONRequest(t, c.method, c.url, guest, c.body, nil); code != c.wantCode {
+				t.Fatalf("got code %d, want %d", code, c.wantCode)
+			}
+		})
+	}
+
+	if code := doJSONRequest(t, http.MethodDelete, base+"/shares/"+share.Token, owner, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("got code %d, want %d", code, http.StatusNoContent)
+	}
+
+	if code := doJSONRequest(t, http.MethodGet, base+"/", guest, nil, nil); code != http.StatusForbidden {
+		t.Fatalf("revoked viewers should be rejected, got code %d", code)
+	}
+
+	if code := doJSONRequest(t, http.MethodGet, base+"/shared/"+share.Token, "", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("revoked shares should not be found, got code %d", code)
+	}
+}
+
+func TestShareEditPermission(t *testing.T) {
+	mock, cart, base := newShareTestServer(t)
+	owner, guest := mock.sessions[0], mock.sessions[2]
+
+	created := CreateShareResponse{}
+	req := CreateShareRequest{Data: ShareRequest{Permission: EditPermission}}
+
+	if code := doJSONRequest(t, http.MethodPost, base+"/shares", owner, req, &created); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	if code := doJSONRequest(t, http.MethodPost, base+"/shared/"+created.Data.Token+"/attach", guest, nil, nil); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	updated := cart.Clone()
+	updated.Items = append(updated.Items, CartItem{ID: "apple", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}})
+
+	if code := doJSONRequest(t, http.MethodPut, base+"/", guest, UpdateCartRequest{Data: updated}, nil); code != http.StatusOK {
+		t.Fatalf("editors should update the cart, got code %d", code)
+	}
+
+	if code := doJSONRequest(t, http.MethodDelete, base+"/", guest, nil, nil); code != http.StatusForbidden {
+		t.Fatalf("only owners should delete the cart, got code %d", code)
+	}
+
+	// owners attaching their own cart keep full access.
+	if code := doJSONRequest(t, http.MethodPost, base+"/shared/"+created.Data.Token+"/attach", owner, nil, nil); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if code := doJSONRequest(t, http.MethodDelete, base+"/", owner, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("got code %d, want %d", code, http.StatusNoContent)
+	}
+}
+
+func TestShareValidation(t *testing.T) {
+	mock, _, base := newShareTestServer(t)
+	owner := mock.sessions[0]
+
+	tests := []struct {
+		label    string
+		req      ShareRequest
+		wantCode int
+	}{
+		{label: "unknown permission", req: ShareRequest{Permission: "admin"}, wantCode: http.StatusBadRequest},
+		{label: "invalid expiry", req: ShareRequest{Permission: ViewPermission, ExpiresIn: "soon"}, wantCode: http.StatusBadRequest},
+		{label: "negative expiry", req: ShareRequest{Permission: ViewPermission, ExpiresIn: "-1h"}, wantCode: http.StatusBadRequest},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			body := CreateShareRequest{Data: c.req}
+			if code := doJSONRequest(t, http.MethodPost, base+"/shares", owner, body, nil); code != c.wantCode {
+				t.Fatalf("got code %d, want %d", code, c.wantCode)
+			}
+		})
+	}
+
+	created := CreateShareResponse{}
+	body := CreateShareRequest{Data: ShareRequest{Permission: ViewPermission, ExpiresIn: "1ms"}}
+
+	if code := doJSONRequest(t, http.MethodPost, base+"/shares", owner, body, &created); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if code := doJSONRequest(t, http.MethodGet, base+"/shared/"+created.Data.Token, "", nil, nil); code != http.StatusGone {
+		t.Fatalf("got code %d, want %d", code, http.StatusGone)
+	}
+}
+
+func TestShareAttachKeepsSavedItems(t *testing.T) {
+	mock, cart, base := newShareTestServer(t)
+	owner, guest := mock.sessions[0], mock.sessions[2]
+
+	previous, err := mock.CreateCartForSession(guest)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	previous.Saved = []CartItem{{ID: "apple", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}, Discounts: []Discount{}}}
+	if err := mock.UpdateCart(previous); err != nil {
+		t.Fatalf("could not update cart: %v", err)
+	}
+
+	created := CreateShareResponse{}
+	req := CreateShareRequest{Data: ShareRequest{Permission: EditPermission}}
+
+	if code := doJSONRequest(t, http.MethodPost, base+"/shares", owner, req, &created); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	if code := doJSONRequest(t, http.MethodPost, base+"/shared/"+created.Data.Token+"/attach", guest, nil, nil); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	attached, err := mock.LookupCart(cart.ID)
+	if err != nil || len(attached.Saved) != 1 || attached.Saved[0].ID != "apple" {
+		t.Fatalf("expected the saved items to be carried over, got %+v (%v)", attached, err)
+	}
+}
+
+func TestMemoryShareStoreSweep(t *testing.T) {
+	store := newMemoryShareStore()
+	now := time.Now()
+
+	store.shares["expired"] = Share{Token: "expired", ExpiresAt: now}
+	store.shares["valid"] = Share{Token: "valid", ExpiresAt: now.Add(time.Hour)}
+
+	if err := store.GrantAccess("session", store.shares["expired"]); err != nil {
+		t.Fatalf("could not grant access: %v", err)
+	}
+
+	if err := store.CreateShare(Share{Token: "new"}); err != nil {
+		t.Fatalf("could not create share: %v", err)
+	}
+
+	if _, err := store.LookupShare("expired"); !errors.Is(err, ErrShareNotFound) {
+		t.Fatalf("expected the expired share to be swept, got %v", err)
+	}
+
+	if _, err := store.LookupShare("valid"); err != nil {
+		t.Fatalf("expected the valid share to be kept, got %v", err)
+	}
+
+	// the session would otherwise be considered the owner.
+	if _, err := store.LookupGrant("session", ""); err != nil {
+		t.Fatalf("expected the grant to be kept, got %v", err)
+	}
+}
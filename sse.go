@@ -0,0 +1,137 @@
+package kaimono
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// reconnectDelay is the retry delay suggested to SSE clients.
+const reconnectDelay = 3 * time.Second
+
+// Events streams the changes made to the current session's Cart as
+// Server-Sent Events. Each event's ID is the Cart version, prefixed by an
+// epoch which changes when the process restarts, and its data is the
+// CartEvent.
+//
+// On connect, the current Cart is sent as an "updated" event unless the
+// Last-Event-ID header matches the current version and epoch. The stream
+// ends after a "deleted" event.
+//
+// Status codes:
+//   - 200: OK, streaming
+//   - 400: No session found for request
+//   - 403: Session's access to a shared cart was revoked
+//   - 404: No cart found for session
+//   - 500: unexpected error
+func (svc *Service) Events(w http.ResponseWriter, req *http.Request) {
+	usrCtx, ok := svc.fetchCtxOrExit(w, req)
+	if !ok {
+		return
+	}
+
+	cart, ok := svc.lookupAccessibleCartOrExit(req.Context(), w, usrCtx, ViewPermission)
+	if !ok {
+		return
+	}
+
+	// subscribe, then read the version, then the Cart: changes made after
+	// subscribing are received, and the snapshot is at least as recent as
+	// its version, so no change is missed.
+	events, unsubscribe := svc.events.subscribe(cart.ID)
+	defer unsubscribe()
+
+	version := svc.events.version(cart.ID)
+
+	cart, err := svc.store(req.Context()).LookupCart(cart.ID)
+	if errors.Is(err, ErrCartNotFound) {
+		svc.json(svc.writeError(w, http.StatusNotFound, err))
+		return
+	}
+
+	if err != nil {
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	stream := sseStream{w: w, rc: http.NewResponseController(w), events: svc.events}
+
+	if err := stream.retry(reconnectDelay); err != nil {
+		return
+	}
+
+	if req.Header.Get("Last-Event-ID") != svc.events.eventID(version) {
+		snapshot := CartEvent{Type: CartUpdated, CartID: cart.ID, Version: version, Cart: cart, Time: time.Now()}
+		if err := stream.event(snapshot); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(svc.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-heartbeat.C:
+			if err := stream.comment("heartbeat"); err != nil {
+				return
+			}
+		case event := <-events:
+			if event.Version <= version && event.Type != CartDeleted {
+				continue
+			}
+
+			if err := stream.event(event); err != nil || event.Type == CartDeleted {
+				return
+			}
+
+			heartbeat.Reset(svc.heartbeatInterval)
+		}
+	}
+}
+
+// sseStream writes Server-Sent Events, flushing after each one.
+type sseStream struct {
+	w      io.Writer
+	rc     *http.ResponseController
+	events *eventBroker
+}
+
+func (s sseStream) event(event CartEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("could not encode event: %w", err)
+	}
+
+	return s.write("id: %s\nevent: %s\ndata: %s\n\n", s.events.eventID(event.Version), event.Type, data)
+}
+
+func (s sseStream) comment(msg string) error {
+	return s.write(": %s\n\n", msg)
+}
+
+func (s sseStream) retry(d time.Duration) error {
+	return s.write("retry: %d\n\n", d.Milliseconds())
+}
+
+func (s sseStream) write(format string, args ...any) error {
+	if _, err := fmt.Fprintf(s.w, format, args...); err != nil {
+		return fmt.Errorf("could not write event: %w", err)
+	}
+
+	if err := s.rc.Flush(); err != nil {
+		return fmt.Errorf("could not flush event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,305 @@
+package kaimono
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sseTestEvent struct {
+	id    string
+	name  string
+	event CartEvent
+}
+
+// readSSE reads the next event from the stream, skipping comments and
+// retry fields. It returns the number of heartbeats seen along the way.
+func readSSE(t *testing.T, scanner *bufio.Scanner) (sseTestEvent, int) {
+	t.Helper()
+
+	ev := sseTestEvent{}
+	heartbeats := 0
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == ": heartbeat":
+			heartbeats++
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.event); err != nil {
+				t.Fatalf("could not decode event: %v", err)
+			}
+		case line == "" && ev.name != "":
+			return ev, heartbeats
+		}
+	}
+
+	t.Fatalf("stream ended: %v", scanner.Err())
+
+	return ev, heartbeats
+}
+
+func openSSE(t *testing.T, ctx context.Context, url, sessionToken, lastEventID string) *bufio.Scanner {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("could not make request: %v", err)
+	}
+
+	setTestCookie(req, sessionToken)
+
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("could not connect: %v", err)
+	}
+
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	return bufio.NewScanner(resp.Body)
+}
+
+func putCart(t *testing.T, url, sessionToken string, cart Cart) {
+	t.Helper()
+
+	body, err := json.Marshal(UpdateCartRequest{Data: cart})
+	if err != nil {
+		t.Fatalf("could not encode cart: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("could not make request: %v", err)
+	}
+
+	setTestCookie(req, sessionToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("could not update cart: %v", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got code %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+func TestEventsStream(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil, WithHeartbeatInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	session := mock.sessions[0]
+
+	cart, err := mock.CreateCartForSession(session)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := openSSE(t, ctx, srv.URL+"/cart/events", session, "")
+
+	snapshot, _ := readSSE(t, stream)
+	if snapshot.name != string(CartUpdated) || snapshot.event.CartID != cart.ID || snapshot.id != svc.events.eventID(0) {
+		t.Fatalf("unexpected snapshot: %+v", snapshot)
+	}
+
+	cart.Items = append(cart.Items, CartItem{ID: "apple", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}})
+	putCart(t, srv.URL+"/cart/", session, cart)
+
+	update, _ := readSSE(t, stream)
+	if update.id != svc.events.eventID(1) || len(update.event.Cart.Items) != 1 {
+		t.Fatalf("unexpected update: %+v", update)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	cart.Items[0].Quantity = 2
+	putCart(t, srv.URL+"/cart/", session, cart)
+
+	update, heartbeats := readSSE(t, stream)
+	if heartbeats == 0 {
+		t.Fatalf("expected heartbeats on an idle stream")
+	}
+
+	if update.id != svc.events.eventID(2) {
+		t.Fatalf("unexpected update: %+v", update)
+	}
+
+	cancel()
+
+	// reconnecting with the latest ID skips the snapshot.
+	resumed := openSSE(t, context.Background(), srv.URL+"/cart/events", session, svc.events.eventID(2))
+
+	cart.Items[0].Quantity = 3
+	putCart(t, srv.URL+"/cart/", session, cart)
+
+	update, _ = readSSE(t, resumed)
+	if update.id != svc.events.eventID(3) || update.event.Cart.Items[0].Quantity != 3 {
+		t.Fatalf("expected the next update after reconnecting, got %+v", update)
+	}
+}
+
+func TestEventsAfterRestart(t *testing.T) {
+	mock := newMockBackend()
+	session := mock.sessions[0]
+
+	cart, err := mock.CreateCartForSession(session)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	before, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	cart.Items = []CartItem{{ID: "apple", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}}}
+	if err := before.updateCart(context.Background(), &cart); err != nil {
+		t.Fatalf("could not update cart: %v", err)
+	}
+
+	lastEventID := before.events.eventID(before.events.version(cart.ID))
+
+	// the restarted service's versions start over.
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	cart.Items[0].Quantity = 2
+	if err := svc.updateCart(context.Background(), &cart); err != nil {
+		t.Fatalf("could not update cart: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	snapshot, _ := readSSE(t, openSSE(t, ctx, srv.URL+"/cart/events", session, lastEventID))
+	if snapshot.id != svc.events.eventID(1) || snapshot.event.Cart.Items[0].Quantity != 2 {
+		t.Fatalf("expected a snapshot after the restart, got %+v", snapshot)
+	}
+}
+
+// lookupHookDB calls afterLookup once, after the first session lookup.
+type lookupHookDB struct {
+	DB
+
+	afterLookup func()
+}
+
+func (db *lookupHookDB) LookupCartForSession(sessionToken string) (Cart, error) {
+	cart, err := db.DB.LookupCartForSession(sessionToken)
+
+	if fn := db.afterLookup; fn != nil {
+		db.afterLookup = nil
+		fn()
+	}
+
+	return cart, err
+}
+
+func TestEventsChangeBeforeSubscribing(t *testing.T) {
+	mock := newMockBackend()
+	db := &lookupHookDB{DB: mock}
+
+	svc, err := NewService(db, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	session := mock.sessions[0]
+
+	cart, err := mock.CreateCartForSession(session)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	// the cart changes after the handler looked it up, before it subscribed.
+	db.afterLookup = func() {
+		updated := cart.Clone()
+		updated.Items = []CartItem{{ID: "apple", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}, Discounts: []Discount{}}}
+
+		if err := svc.updateCart(context.Background(), &updated); err != nil {
+			t.Errorf("could not update cart: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	snapshot, _ := readSSE(t, openSSE(t, ctx, srv.URL+"/cart/events", session, ""))
+	if snapshot.id != svc.events.eventID(1) || len(snapshot.event.Cart.Items) != 1 {
+		t.Fatalf("expected the snapshot to include the change, got %+v", snapshot)
+	}
+}
+
+func TestEventsUnsubscribeOnDisconnect(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	if _, err := mock.CreateCartForSession(mock.sessions[0]); err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := openSSE(t, ctx, srv.URL+"/cart/events", mock.sessions[0], "")
+	readSSE(t, stream)
+
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		svc.events.mu.Lock()
+		subs := len(svc.events.subs)
+		svc.events.mu.Unlock()
+
+		if subs == 0 {
+			return
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("subscriber was not removed after the client disconnected")
+}
@@ -12,11 +12,43 @@ import (
 func (svc *Service) Router(base string) *chi.Mux {
 	r := chi.NewRouter()
 
+	r.Use(svc.logRequests)
+	r.Use(svc.negotiateErrors)
+
+	if svc.tracer != nil {
+		r.Use(svc.traceRequests)
+	}
+
+	if svc.metrics != nil {
+		r.Use(svc.metrics.middleware(false))
+	}
+
+	if svc.stateless != nil {
+		r.Use(svc.withStatelessCart)
+	}
+
 	r.Route(base, func(r chi.Router) {
+		// applied per route, so the route is known when limiting.
+		r = r.With(svc.limitRequests)
+
 		r.Get("/", svc.Get)
-		r.Post("/", svc.Create)
+		r.With(svc.idempotent, svc.limitCartCreation).Post("/", svc.Create)
 		r.Put("/", svc.Update)
 		r.Delete("/", svc.Delete)
+		r.Get("/events", svc.Events)
+		r.Get("/live", svc.Live)
+		r.Post("/shares", svc.CreateShare)
+		r.Get("/shares", svc.ListShares)
+		r.Delete("/shares/{token}", svc.RevokeShare)
+		r.Get("/shared/{token}", svc.GetShared)
+		r.Post("/shared/{token}/attach", svc.AttachShared)
+		r.Get("/carts", svc.ListUserCarts)
+		r.With(svc.idempotent, svc.limitCartCreation).Post("/carts", svc.CreateUserCart)
+		r.Post("/carts/{id}/activate", svc.ActivateUserCart)
+		r.With(svc.idempotent).Post("/carts/move", svc.MoveItem)
+		r.With(svc.idempotent).Post("/items/{id}/save", svc.SaveForLater)
+		r.With(svc.idempotent).Post("/saved/{id}/restore", svc.RestoreSaved)
+		r.Post("/recover/{token}", svc.Restore)
 	})
 
 	return r
@@ -26,7 +58,15 @@ func (svc *Service) fetchCtxOrExit(w http.ResponseWriter, req *http.Request) (Us
 	usrCtx, err := svc.usrCtxFetcher.GetUserContext(req)
 	if errors.Is(err, ErrSessionNotFound) {
 		svc.json(
-			writeError(w, http.StatusBadRequest, ErrSessionNotFound),
+			svc.writeError(w, http.StatusBadRequest, ErrSessionNotFound),
+		)
+
+		return usrCtx, false
+	}
+
+	if errors.Is(err, ErrInvalidCredentials) {
+		svc.json(
+			svc.writeError(w, http.StatusUnauthorized, err),
 		)
 
 		return usrCtx, false
@@ -34,7 +74,7 @@ func (svc *Service) fetchCtxOrExit(w http.ResponseWriter, req *http.Request) (Us
 
 	if err != nil {
 		svc.json(
-			writeError(w, http.StatusInternalServerError, err),
+			svc.writeError(w, http.StatusInternalServerError, err),
 		)
 
 		return usrCtx, false
@@ -48,6 +88,7 @@ func (svc *Service) fetchCtxOrExit(w http.ResponseWriter, req *http.Request) (Us
 // Status codes:
 //   - 200: OK
 //   - 400: No session found for request
+//   - 403: Session's access to a shared cart was revoked
 //   - 404: No cart found for session
 //   - 500: unexpected error
 func (svc *Service) Get(w http.ResponseWriter, req *http.Request) {
@@ -56,18 +97,8 @@ func (svc *Service) Get(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	cart, err := svc.db.LookupCartForSession(usrCtx.SessionToken)
-	if errors.Is(err, ErrSessionNotFound) {
-		svc.json(writeError(w, http.StatusBadRequest, err))
-	}
-
-	if errors.Is(err, ErrCartNotFound) {
-		svc.json(writeError(w, http.StatusNotFound, err))
-		return
-	}
-
-	if err != nil {
-		svc.json(writeError(w, http.StatusInternalServerError, err))
+	cart, ok := svc.lookupAccessibleCartOrExit(req.Context(), w, usrCtx, ViewPermission)
+	if !ok {
 		return
 	}
 
@@ -87,14 +118,14 @@ func (svc *Service) Create(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	cart, err := svc.db.CreateCartForSession(usrCtx.SessionToken)
+	cart, err := svc.CreateSessionCart(req.Context(), usrCtx)
 	if errors.Is(err, ErrAlreadyExists) {
-		svc.json(writeError(w, http.StatusConflict, err))
+		svc.json(svc.writeError(w, http.StatusConflict, err))
 		return
 	}
 
 	if err != nil {
-		svc.json(writeError(w, http.StatusInternalServerError, err))
+		svc.json(svc.writeError(w, http.StatusInternalServerError, err))
 		return
 	}
 
@@ -103,12 +134,13 @@ func (svc *Service) Create(w http.ResponseWriter, req *http.Request) {
 }
 
 // Update will update the Cart for the current session. It will reject
-// the Cart if the ID suplied does not match.
+// the Cart if the ID suplied does not match, or if it fails validation.
 //
 // Status codes:
 //   - 200: Updated successfully
-//   - 400: No session found for request
-//   - 403: Cart ID is not the ID matching this session's Cart
+//   - 400: No session found for request, or the Cart is invalid
+//   - 403: Cart ID is not the ID matching this session's Cart, or the cart
+//     was shared with this session as read-only
 //   - 404: No cart found for this session
 //   - 500: unexpected error
 func (svc *Service) Update(w http.ResponseWriter, req *http.Request) {
@@ -117,36 +149,29 @@ func (svc *Service) Update(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	foundCart, err := svc.db.LookupCartForSession(usrCtx.SessionToken)
-	if errors.Is(err, ErrCartNotFound) {
-		svc.json(writeError(w, http.StatusNotFound, ErrCartNotFound))
-		return
-	}
-
 	payload := UpdateCartRequest{}
 	if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
-		svc.json(writeError(w, http.StatusBadRequest, fmt.Errorf("could not decode request: %w", err)))
+		svc.json(svc.writeError(w, http.StatusBadRequest, fmt.Errorf("could not decode request: %w", err)))
 		return
 	}
 
-	if payload.Data.ID != foundCart.ID {
-		svc.json(writeError(w, http.StatusForbidden, ErrInvalidID))
-	}
-
-	if err := svc.db.UpdateCart(payload.Data); err != nil {
-		svc.json(writeError(w, http.StatusInternalServerError, fmt.Errorf("update failed: %w", err)))
+	cart, err := svc.UpdateSessionCart(req.Context(), usrCtx, payload.Data)
+	if err != nil {
+		svc.writeMutationError(w, err)
 		return
 	}
 
-	svc.json(writeResponse(w, http.StatusOK, UpdateCartResponse{Data: payload.Data}))
+	svc.json(writeResponse(w, http.StatusOK, UpdateCartResponse{Data: cart}))
 }
 
 // Delete will delete the Cart for the current session. It will reject
-// the Cart if the ID suplied does not match the expected one.
+// the Cart if the ID suplied does not match the expected one. Sessions
+// the Cart was shared with can't delete it.
 //
 // Status codes:
 //   - 204: Deleted successfully
 //   - 400: No session found for request
+//   - 403: Cart was shared with this session
 //   - 404: No cart found for this session
 //   - 500: unexpected error
 func (svc *Service) Delete(w http.ResponseWriter, req *http.Request) {
@@ -155,21 +180,22 @@ func (svc *Service) Delete(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	foundCart, err := svc.db.LookupCartForSession(usrCtx.SessionToken)
-	if errors.Is(err, ErrCartNotFound) {
-		svc.json(writeError(w, http.StatusNotFound, ErrCartNotFound))
+	if err := svc.DeleteSessionCart(req.Context(), usrCtx); err != nil {
+		svc.writeMutationError(w, err)
 		return
 	}
 
-	if err != nil {
-		svc.json(writeError(w, http.StatusInternalServerError, err))
-		return
-	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	if err := svc.db.DeleteCart(foundCart.ID); err != nil {
-		svc.json(writeError(w, http.StatusInternalServerError, err))
-		return
+// writeMutationError writes the response for an error of the mutation API.
+func (svc *Service) writeMutationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.As(err, &ValidationError{}):
+		svc.json(svc.writeError(w, http.StatusBadRequest, err))
+	case errors.Is(err, ErrInvalidID):
+		svc.json(svc.writeError(w, http.StatusForbidden, err))
+	default:
+		svc.writeShareError(w, err)
 	}
-
-	w.WriteHeader(http.StatusNoContent)
 }
@@ -73,6 +73,31 @@ func TestStandardGet(t *testing.T) {
 	}
 }
 
+type invalidCredentialsFetcher struct{}
+
+func (invalidCredentialsFetcher) GetUserContext(*http.Request) (UserContext, error) {
+	return UserContext{}, ErrInvalidCredentials
+}
+
+func TestStandardGetInvalidCredentials(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, invalidCredentialsFetcher{}, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	svc.Get(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	result := w.Result()
+	defer result.Body.Close()
+
+	if result.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("got code %d, want %d", result.StatusCode, http.StatusUnauthorized)
+	}
+}
+
 func setTestCookie(req *http.Request, v string) {
 	cookie := http.Cookie{
 		Name:     testCookieName,
@@ -0,0 +1,476 @@
+package kaimono
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultStatelessCookie = "kaimono-cart"
+
+	defaultStatelessMaxSize = 4096
+	defaultStatelessMaxAge  = 30 * 24 * time.Hour
+
+	// maxStatelessCartBytes caps the decompressed size of a cart cookie.
+	maxStatelessCartBytes = 1 << 20
+	statelessFormat       = 1
+)
+
+var (
+	ErrMissingCartKey  = errors.New("stateless carts need at least one key")
+	ErrCatalogRequired = errors.New("stateless carts need a Catalog to re-validate prices")
+	ErrCartTooLarge    = errors.New("cart is too large to be stored in a cookie")
+	ErrTamperedCart    = errors.New("cart cookie could not be authenticated")
+)
+
+// StatelessConfig configures carts stored in a cookie instead of a DB.
+// Zero values use the defaults.
+type StatelessConfig struct {
+	// Keys encrypt and authenticate the cookies with AES-GCM, they should be
+	// 32 random bytes. The first key seals the cookies and every key is
+	// tried to open them, so keys are rotated by prepending a new one.
+	Keys [][]byte
+
+	// CookieName defaults to DefaultStatelessCookie.
+	CookieName string
+
+	// MaxSize is the maximum size of the cookie's value, defaults to 4096
+	// bytes. Updates making the Cart larger are rejected.
+	MaxSize int
+
+	// MaxAge is how long browsers keep the cookie, defaults to 30 days.
+	MaxAge time.Duration
+
+	// Insecure allows sending the cookie over plain HTTP, e.g: for local
+	// development.
+	Insecure bool
+}
+
+func (cfg StatelessConfig) withDefaults() StatelessConfig {
+	if cfg.CookieName == "" {
+		cfg.CookieName = DefaultStatelessCookie
+	}
+
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = defaultStatelessMaxSize
+	}
+
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = defaultStatelessMaxAge
+	}
+
+	return cfg
+}
+
+// WithStatelessCarts stores the session's Cart in a compressed, encrypted
+// and authenticated cookie, so no DB is needed: the DB passed to NewService
+// can be nil. It applies to the standard router, and the prices of the
+// items are re-validated against the Catalog (see WithCatalog), which is
+// required, on every request.
+//
+// Only the session's Cart is available, so user carts, shares and live
+// carts aren't supported.
+func WithStatelessCarts(cfg StatelessConfig) Option {
+	return func(svc *Service) {
+		svc.stateless = &statelessCarts{cfg: cfg.withDefaults()}
+	}
+}
+
+type statelessCarts struct {
+	cfg   StatelessConfig
+	aeads []cipher.AEAD
+}
+
+// init prepares the ciphers, once the options are applied.
+func (sc *statelessCarts) init(catalog Catalog) error {
+	if len(sc.cfg.Keys) == 0 {
+		return ErrMissingCartKey
+	}
+
+	if catalog == nil {
+		return ErrCatalogRequired
+	}
+
+	for _, key := range sc.cfg.Keys {
+		sum := sha256.Sum256(key)
+
+		block, err := aes.NewCipher(sum[:])
+		if err != nil {
+			return fmt.Errorf("could not create cipher: %w", err)
+		}
+
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return fmt.Errorf("could not create cipher: %w", err)
+		}
+
+		sc.aeads = append(sc.aeads, aead)
+	}
+
+	return nil
+}
+
+// seal compresses and encrypts the Cart with the first key.
+func (sc *statelessCarts) seal(cart Cart) (string, error) {
+	data, err := json.Marshal(cart)
+	if err != nil {
+		return "", fmt.Errorf("could not encode cart: %w", err)
+	}
+
+	buf := &bytes.Buffer{}
+
+	zw, err := flate.NewWriter(buf, flate.BestCompression)
+	if err != nil {
+		return "", fmt.Errorf("could not compress cart: %w", err)
+	}
+
+	if _, err := zw.Write(data); err != nil {
+		return "", fmt.Errorf("could not compress cart: %w", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("could not compress cart: %w", err)
+	}
+
+	aead := sc.aeads[0]
+	header := []byte{statelessFormat}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("could not generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(append(header, nonce...), nonce, buf.Bytes(), header)
+	value := base64.RawURLEncoding.EncodeToString(sealed)
+
+	if len(value) > sc.cfg.MaxSize {
+		return "", ValidationError{
+			Err:    ErrCartTooLarge,
+			Fields: []FieldError{{Field: "items", Message: "too many items to be stored"}},
+		}
+	}
+
+	return value, nil
+}
+
+// open authenticates the cookie's value with each key and returns its Cart.
+func (sc *statelessCarts) open(value string) (Cart, error) {
+	cart := Cart{}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(sealed) == 0 || sealed[0] != statelessFormat {
+		return cart, ErrTamperedCart
+	}
+
+	header, sealed := sealed[:1], sealed[1:]
+
+	for _, aead := range sc.aeads {
+		if len(sealed) < aead.NonceSize() {
+			break
+		}
+
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+		data, err := aead.Open(nil, nonce, ciphertext, header)
+		if err != nil {
+			continue
+		}
+
+		zr := flate.NewReader(bytes.NewReader(data))
+		defer zr.Close()
+
+		if err := json.NewDecoder(io.LimitReader(zr, maxStatelessCartBytes)).Decode(&cart); err != nil {
+			return cart, fmt.Errorf("%w: %w", ErrTamperedCart, err)
+		}
+
+		return cart, nil
+	}
+
+	return cart, ErrTamperedCart
+}
+
+func (sc *statelessCarts) cookie(value string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     sc.cfg.CookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(sc.cfg.MaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   !sc.cfg.Insecure,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+
+	return cookie
+}
+
+type requestDBKey struct{}
+
+// withStatelessCart serves the request with the Cart of its cookie as DB,
+// re-validating its prices against the Catalog, and sets the cookie if the
+// Cart changed.
+func (svc *Service) withStatelessCart(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		db := &cookieDB{carts: svc.stateless}
+
+		if cookie, err := req.Cookie(svc.stateless.cfg.CookieName); err == nil {
+			if err := db.load(cookie.Value); err != nil {
+				svc.loggerFor(req.Context()).Warn("discarding cart cookie", "error", err)
+			}
+		}
+
+		if err := svc.revalidateCookieCart(db); err != nil {
+			svc.json(svc.writeError(w, http.StatusInternalServerError, err))
+			return
+		}
+
+		cw := &cartCookieWriter{ResponseWriter: w, db: db}
+
+		next.ServeHTTP(cw, req.WithContext(context.WithValue(req.Context(), requestDBKey{}, DB(db))))
+		cw.setCookie()
+	})
+}
+
+// revalidateCookieCart re-prices the items of the Cart, removing the ones
+// no longer in the Catalog.
+func (svc *Service) revalidateCookieCart(db *cookieDB) error {
+	if db.cart == nil {
+		return nil
+	}
+
+	cart := db.cart.Clone()
+
+	unknown, changed, err := svc.repriceItems(&cart)
+	if err != nil {
+		return err
+	}
+
+	if !changed && len(unknown) == 0 {
+		return nil
+	}
+
+	for _, itemID := range unknown {
+		if err := cart.RemoveItem(itemID); err != nil {
+			return err
+		}
+	}
+
+	// the cart may not fit in the cookie anymore with the new prices, it's
+	// still served re-priced but the cookie is kept until the next update.
+	err = db.UpdateCart(cart)
+	if errors.Is(err, ErrCartTooLarge) {
+		db.cart = &cart
+		return nil
+	}
+
+	return err
+}
+
+// repriceItems sets the Catalog's price on the Cart's items, returning the
+// IDs of the items missing from it and whether any price changed.
+func (svc *Service) repriceItems(cart *Cart) ([]string, bool, error) {
+	unknown := []string{}
+	changed := false
+
+	for k, item := range cart.Items {
+		price, err := svc.catalog.LookupPrice(item.ID)
+		if errors.Is(err, ErrItemNotFound) {
+			unknown = append(unknown, item.ID)
+			continue
+		}
+
+		if err != nil {
+			return nil, false, fmt.Errorf("could not look up price: %w", err)
+		}
+
+		changed = changed || item.Price != price
+		cart.Items[k].Price = price
+	}
+
+	return unknown, changed, nil
+}
+
+// repriceStatelessCart re-prices the Cart before it's stored, rejecting
+// items missing from the Catalog.
+func (svc *Service) repriceStatelessCart(cart *Cart) error {
+	unknown, _, err := svc.repriceItems(cart)
+	if err != nil {
+		return err
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	fields := []FieldError{}
+
+	for k, item := range cart.Items {
+		if slices.Contains(unknown, item.ID) {
+			fields = append(fields, FieldError{Field: fmt.Sprintf("items[%d].id", k), Message: "is not in the catalog"})
+		}
+	}
+
+	return ValidationError{Err: ErrInvalidCart, Fields: fields}
+}
+
+// cartCookieWriter sets the cart cookie before the response is written.
+type cartCookieWriter struct {
+	http.ResponseWriter
+
+	db   *cookieDB
+	done bool
+}
+
+func (cw *cartCookieWriter) setCookie() {
+	if cw.done {
+		return
+	}
+
+	cw.done = true
+
+	if cw.db.dirty {
+		http.SetCookie(cw.ResponseWriter, cw.db.carts.cookie(cw.db.value))
+	}
+}
+
+func (cw *cartCookieWriter) WriteHeader(code int) {
+	cw.setCookie()
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cartCookieWriter) Write(data []byte) (int, error) {
+	cw.setCookie()
+	return cw.ResponseWriter.Write(data)
+}
+
+func (cw *cartCookieWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// cookieDB is the DB of a single request, holding the Cart of its cookie.
+// The session token is ignored: the Cart belongs to whoever holds the
+// cookie.
+type cookieDB struct {
+	carts *statelessCarts
+	cart  *Cart
+
+	// created is the ID of the Cart being created, until it's stored.
+	created string
+
+	// dirty is set once the cookie must be set to value, or removed if
+	// value is empty.
+	dirty bool
+	value string
+}
+
+func (db *cookieDB) load(value string) error {
+	cart, err := db.carts.open(value)
+	if err != nil {
+		db.dirty = true
+		return err
+	}
+
+	db.cart = &cart
+
+	return nil
+}
+
+func (db *cookieDB) matches(cartID string) bool {
+	return db.cart != nil && db.cart.ID == cartID
+}
+
+func (db *cookieDB) CreateCartForSession(string) (Cart, error) {
+	if db.cart != nil {
+		return Cart{}, ErrAlreadyExists
+	}
+
+	cart := Cart{
+		ID:        uuid.New().String(),
+		Items:     []CartItem{},
+		Discounts: []Discount{},
+	}
+
+	db.created = cart.ID
+
+	return cart, nil
+}
+
+func (db *cookieDB) CreateCart() (Cart, error) {
+	return Cart{}, ErrNotSupported
+}
+
+func (db *cookieDB) DeleteCart(cartID string) error {
+	if !db.matches(cartID) {
+		return ErrCartNotFound
+	}
+
+	db.cart, db.dirty, db.value = nil, true, ""
+
+	return nil
+}
+
+func (db *cookieDB) UpdateCart(cart Cart) error {
+	if !db.matches(cart.ID) && (db.created == "" || cart.ID != db.created) {
+		return ErrCartNotFound
+	}
+
+	value, err := db.carts.seal(cart)
+	if err != nil {
+		return err
+	}
+
+	stored := cart.Clone()
+	db.cart, db.created, db.dirty, db.value = &stored, "", true, value
+
+	return nil
+}
+
+func (db *cookieDB) LookupCart(cartID string) (Cart, error) {
+	if !db.matches(cartID) {
+		return Cart{}, ErrCartNotFound
+	}
+
+	return db.cart.Clone(), nil
+}
+
+func (db *cookieDB) LookupCartForSession(string) (Cart, error) {
+	if db.cart == nil {
+		return Cart{}, ErrCartNotFound
+	}
+
+	return db.cart.Clone(), nil
+}
+
+func (db *cookieDB) AssignCartToSession(string, string) error {
+	return ErrNotSupported
+}
+
+// unsupportedDB is used outside of the standard router when carts are
+// stateless and no DB was given.
+type unsupportedDB struct{}
+
+func (unsupportedDB) CreateCartForSession(string) (Cart, error) { return Cart{}, ErrNotSupported }
+func (unsupportedDB) CreateCart() (Cart, error)                 { return Cart{}, ErrNotSupported }
+func (unsupportedDB) DeleteCart(string) error                   { return ErrNotSupported }
+func (unsupportedDB) UpdateCart(Cart) error                     { return ErrNotSupported }
+func (unsupportedDB) LookupCart(string) (Cart, error)           { return Cart{}, ErrNotSupported }
+func (unsupportedDB) LookupCartForSession(string) (Cart, error) { return Cart{}, ErrNotSupported }
+func (unsupportedDB) AssignCartToSession(string, string) error  { return ErrNotSupported }
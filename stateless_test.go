@@ -0,0 +1,398 @@
+package kaimono
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var (
+	testCartKey    = []byte("0123456789abcdef0123456789abcdef")
+	testCartOldKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+type statelessClient struct {
+	t       *testing.T
+	client  *http.Client
+	base    string
+	graphql string
+	session string
+}
+
+func newStatelessClient(t *testing.T, catalog Catalog, cfg StatelessConfig) *statelessClient {
+	t.Helper()
+
+	mock := newMockBackend()
+
+	cfg.Insecure = true
+
+	svc, err := NewService(nil, mock, mock, nil, WithCatalog(catalog), WithStatelessCarts(cfg))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	handler, err := svc.GraphQLHandler()
+	if err != nil {
+		t.Fatalf("could not create handler: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/cart/", svc.Router("/cart"))
+	mux.Handle("/graphql", handler)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("could not create cookie jar: %v", err)
+	}
+
+	return &statelessClient{
+		t:       t,
+		client:  &http.Client{Jar: jar},
+		base:    srv.URL + "/cart/",
+		graphql: srv.URL + "/graphql",
+		session: mock.sessions[0],
+	}
+}
+
+func (c *statelessClient) do(method string, body, out any) int {
+	c.t.Helper()
+
+	return c.doURL(method, c.base, body, out)
+}
+
+func (c *statelessClient) doURL(method, url string, body, out any) int {
+	c.t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.t.Fatalf("could not encode body: %v", err)
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(data))
+	if err != nil {
+		c.t.Fatalf("could not make request: %v", err)
+	}
+
+	setTestCookie(req, c.session)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.t.Fatalf("could not do request: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if out != nil && resp.StatusCode < http.StatusBadRequest {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			c.t.Fatalf("could not decode response: %v", err)
+		}
+	}
+
+	return resp.StatusCode
+}
+
+func (c *statelessClient) cartCookie() string {
+	u, _ := url.Parse(c.base)
+
+	for _, cookie := range c.client.Jar.Cookies(u) {
+		if cookie.Name == DefaultStatelessCookie {
+			return cookie.Value
+		}
+	}
+
+	return ""
+}
+
+func (c *statelessClient) setCartCookie(value string) {
+	u, _ := url.Parse(c.base)
+	c.client.Jar.SetCookies(u, []*http.Cookie{{Name: DefaultStatelessCookie, Value: value, Path: "/"}})
+}
+
+func TestStatelessCarts(t *testing.T) {
+	catalog := testCatalog{
+		"apple": {Currency: "EUR", Value: 2},
+		"pear":  {Currency: "EUR", Value: 3},
+	}
+	c := newStatelessClient(t, catalog, StatelessConfig{Keys: [][]byte{testCartKey}})
+
+	if code := c.do(http.MethodGet, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("got code %d, want %d", code, http.StatusNotFound)
+	}
+
+	created := CreateCartResponse{}
+	if code := c.do(http.MethodPost, nil, &created); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	if c.cartCookie() == "" {
+		t.Fatalf("expected the cart cookie to be set")
+	}
+
+	if code := c.do(http.MethodPost, nil, nil); code != http.StatusConflict {
+		t.Fatalf("got code %d, want %d", code, http.StatusConflict)
+	}
+
+	cart := created.Data
+	cart.Items = []CartItem{
+		{ID: "apple", Quantity: 2, Price: Price{Currency: "EUR", Value: 0.01}, Discounts: []Discount{}},
+		{ID: "pear", Quantity: 1, Price: Price{Currency: "EUR", Value: 0.01}, Discounts: []Discount{}},
+	}
+
+	if code := c.do(http.MethodPut, UpdateCartRequest{Data: cart}, nil); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	got := GetCartResponse{}
+	if code := c.do(http.MethodGet, nil, &got); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if item, _ := got.Data.Item("apple"); got.Data.ID != cart.ID || item.Price.Value != 2 {
+		t.Fatalf("expected the cart to be stored re-priced, got %+v", got.Data)
+	}
+
+	// prices are re-validated on every request.
+	catalog["apple"] = Price{Currency: "EUR", Value: 5}
+	delete(catalog, "pear")
+
+	if code := c.do(http.MethodGet, nil, &got); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if item, _ := got.Data.Item("apple"); len(got.Data.Items) != 1 || item.Price.Value != 5 {
+		t.Fatalf("expected the cart to follow the catalog, got %+v", got.Data)
+	}
+
+	cart.Items = append(cart.Items, CartItem{ID: "kiwi", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}})
+	if code := c.do(http.MethodPut, UpdateCartRequest{Data: cart}, nil); code != http.StatusBadRequest {
+		t.Fatalf("items missing from the catalog: got code %d, want %d", code, http.StatusBadRequest)
+	}
+
+	if code := c.do(http.MethodDelete, nil, nil); code != http.StatusNoContent {
+		t.Fatalf("got code %d, want %d", code, http.StatusNoContent)
+	}
+
+	if c.cartCookie() != "" {
+		t.Fatalf("expected the cart cookie to be removed")
+	}
+}
+
+func TestStatelessCartTampering(t *testing.T) {
+	c := newStatelessClient(t, testCatalog{}, StatelessConfig{Keys: [][]byte{testCartKey}})
+
+	if code := c.do(http.MethodPost, nil, nil); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	value := []byte(c.cartCookie())
+	value[len(value)/2] ^= 1
+
+	tests := []struct {
+		label string
+		value string
+	}{
+		{"flipped bit", string(value)},
+		{"not base64", "not a cart!"},
+		{"truncated", string(value[:8])},
+	}
+
+	for _, tc := range tests {
+		c.setCartCookie(tc.value)
+
+		if code := c.do(http.MethodGet, nil, nil); code != http.StatusNotFound {
+			t.Fatalf("(%s) got code %d, want %d", tc.label, code, http.StatusNotFound)
+		}
+
+		if c.cartCookie() != "" {
+			t.Fatalf("(%s) expected the tampered cookie to be removed", tc.label)
+		}
+	}
+}
+
+func TestStatelessCartKeyRotation(t *testing.T) {
+	old := &statelessCarts{cfg: StatelessConfig{Keys: [][]byte{testCartOldKey}}.withDefaults()}
+	if err := old.init(testCatalog{}); err != nil {
+		t.Fatalf("could not init: %v", err)
+	}
+
+	cart := mkEmptyTestCart()
+
+	value, err := old.seal(cart)
+	if err != nil {
+		t.Fatalf("could not seal: %v", err)
+	}
+
+	rotated := newStatelessClient(t, testCatalog{}, StatelessConfig{Keys: [][]byte{testCartKey, testCartOldKey}})
+	rotated.setCartCookie(value)
+
+	got := GetCartResponse{}
+	if code := rotated.do(http.MethodGet, nil, &got); code != http.StatusOK || got.Data.ID != cart.ID {
+		t.Fatalf("expected the previous key to open the cart, got code %d and %+v", code, got.Data)
+	}
+
+	removed := newStatelessClient(t, testCatalog{}, StatelessConfig{Keys: [][]byte{testCartKey}})
+	removed.setCartCookie(value)
+
+	if code := removed.do(http.MethodGet, nil, nil); code != http.StatusNotFound {
+		t.Fatalf("got code %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestStatelessCartTooLarge(t *testing.T) {
+	catalog := testCatalog{}
+	cart := CreateCartResponse{}
+	c := newStatelessClient(t, catalog, StatelessConfig{Keys: [][]byte{testCartKey}, MaxSize: 512})
+
+	if code := c.do(http.MethodPost, nil, &cart); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	for k := range 50 {
+		itemID := strings.Repeat("x", k+1)
+		catalog[itemID] = Price{Currency: "EUR", Value: float64(k + 1)}
+		cart.Data.Items = append(cart.Data.Items, CartItem{ID: itemID, Quantity: 1, Discounts: []Discount{}})
+	}
+
+	if code := c.do(http.MethodPut, UpdateCartRequest{Data: cart.Data}, nil); code != http.StatusBadRequest {
+		t.Fatalf("got code %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestStatelessCartsConfig(t *testing.T) {
+	mock := newMockBackend()
+
+	tests := []struct {
+		label string
+		opts  []Option
+		want  error
+	}{
+		{"no keys", []Option{WithCatalog(testCatalog{}), WithStatelessCarts(StatelessConfig{})}, ErrMissingCartKey},
+		{"no catalog", []Option{WithStatelessCarts(StatelessConfig{Keys: [][]byte{testCartKey}})}, ErrCatalogRequired},
+	}
+
+	for _, tc := range tests {
+		if _, err := NewService(nil, mock, mock, nil, tc.opts...); !errors.Is(err, tc.want) {
+			t.Fatalf("(%s) got %v, want %v", tc.label, err, tc.want)
+		}
+	}
+}
+
+func TestStatelessCartIdempotentCreate(t *testing.T) {
+	c := newStatelessClient(t, testCatalog{}, StatelessConfig{Keys: [][]byte{testCartKey}})
+
+	create := func(client *http.Client) (*http.Response, CreateCartResponse) {
+		req, err := http.NewRequest(http.MethodPost, c.base, nil)
+		if err != nil {
+			t.Fatalf("could not make request: %v", err)
+		}
+
+		setTestCookie(req, c.session)
+		req.Header.Set(IdempotencyKeyHeader, "create-cart")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("could not do request: %v", err)
+		}
+
+		defer resp.Body.Close()
+
+		created := CreateCartResponse{}
+		if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+			t.Fatalf("could not decode response: %v", err)
+		}
+
+		return resp, created
+	}
+
+	// the first response is lost, so its cookie is never stored.
+	_, first := create(http.DefaultClient)
+
+	resp, replayed := create(c.client)
+	if resp.Header.Get(IdempotentReplayedHeader) != "true" || replayed.Data.ID != first.Data.ID {
+		t.Fatalf("expected the creation to be replayed, got %+v", replayed.Data)
+	}
+
+	got := GetCartResponse{}
+	if code := c.do(http.MethodGet, nil, &got); code != http.StatusOK || got.Data.ID != first.Data.ID {
+		t.Fatalf("expected the replay to set the cart cookie, got code %d and %+v", code, got.Data)
+	}
+}
+
+func TestStatelessCartReplayKeepsCookie(t *testing.T) {
+	catalog := testCatalog{"apple": {Currency: "EUR", Value: 2}}
+	c := newStatelessClient(t, catalog, StatelessConfig{Keys: [][]byte{testCartKey}})
+
+	create := func() int {
+		req, err := http.NewRequest(http.MethodPost, c.base, nil)
+		if err != nil {
+			t.Fatalf("could not make request: %v", err)
+		}
+
+		setTestCookie(req, c.session)
+		req.Header.Set(IdempotencyKeyHeader, "create-cart")
+
+		resp, err := c.client.Do(req)
+		if err != nil {
+			t.Fatalf("could not do request: %v", err)
+		}
+
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	if code := create(); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	got := GetCartResponse{}
+	if code := c.do(http.MethodGet, nil, &got); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	cart := got.Data
+	cart.Items = []CartItem{{ID: "apple", Quantity: 1, Price: Price{Currency: "EUR", Value: 2}, Discounts: []Discount{}}}
+
+	if code := c.do(http.MethodPut, UpdateCartRequest{Data: cart}, nil); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if code := create(); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	if code := c.do(http.MethodGet, nil, &got); code != http.StatusOK || len(got.Data.Items) != 1 {
+		t.Fatalf("expected the replay to keep the updated cart, got code %d and %+v", code, got.Data)
+	}
+}
+
+func TestStatelessCartGraphQL(t *testing.T) {
+	c := newStatelessClient(t, testCatalog{"apple": {Currency: "EUR", Value: 2}}, StatelessConfig{Keys: [][]byte{testCartKey}})
+
+	if code := c.do(http.MethodPost, nil, nil); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	query := graphqlRequest{Query: `mutation {
+		addItem(id: "apple", quantity: 2, price: {currency: "EUR", value: 2}) { totals { total } }
+	}`}
+
+	resp := graphqlTestResponse{}
+	if code := c.doURL(http.MethodPost, c.graphql, query, &resp); code != http.StatusOK || len(resp.Errors) > 0 {
+		t.Fatalf("got code %d and errors %+v", code, resp.Errors)
+	}
+
+	got := GetCartResponse{}
+	if code := c.do(http.MethodGet, nil, &got); code != http.StatusOK || len(got.Data.Items) != 1 {
+		t.Fatalf("expected the mutation to be kept in the cookie, got code %d and %+v", code, got.Data)
+	}
+}
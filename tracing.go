@@ -0,0 +1,267 @@
+package kaimono
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/aalbacetef/kaimono"
+
+// Span attributes set by kaimono, on top of the HTTP semantic conventions.
+const (
+	cartIDKey         = attribute.Key("kaimono.cart.id")
+	sessionPresentKey = attribute.Key("kaimono.session.present")
+	userPresentKey    = attribute.Key("kaimono.user.present")
+	operationKey      = attribute.Key("kaimono.operation")
+	resourceKey       = attribute.Key("kaimono.resource")
+)
+
+// TracingConfig configures the spans recorded by the Service.
+type TracingConfig struct {
+	// Provider creates the Service's tracer, defaults to the global
+	// TracerProvider.
+	Provider trace.TracerProvider
+
+	// Propagator extracts the trace context of incoming requests, defaults
+	// to W3C Trace Context and Baggage.
+	Propagator propagation.TextMapPropagator
+}
+
+func (cfg TracingConfig) withDefaults() TracingConfig {
+	if cfg.Provider == nil {
+		cfg.Provider = otel.GetTracerProvider()
+	}
+
+	if cfg.Propagator == nil {
+		cfg.Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	}
+
+	return cfg
+}
+
+// WithTracing records a span for every request handled by the Service's
+// routers, with child spans for the UserContextFetcher, the Authorizer and
+// every DB call.
+func WithTracing(cfg TracingConfig) Option {
+	return func(svc *Service) {
+		cfg = cfg.withDefaults()
+
+		svc.tracer = cfg.Provider.Tracer(tracerName)
+		svc.propagator = cfg.Propagator
+		svc.usrCtxFetcher = tracedFetcher{fetcher: svc.usrCtxFetcher, tracer: svc.tracer}
+		svc.authorizer = tracedAuthorizer{authorizer: svc.authorizer, tracer: svc.tracer}
+	}
+}
+
+// traceRequests starts the request's span, as a child of the incoming
+// trace context if any. The span is named after the route once matched.
+func (svc *Service) traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := svc.propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+		ctx, span := svc.tracer.Start(
+			ctx, req.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(req.Method)),
+		)
+
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+
+		next.ServeHTTP(rec, req.WithContext(ctx))
+
+		if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+			span.SetName(req.Method + " " + rctx.RoutePattern())
+			span.SetAttributes(semconv.HTTPRoute(rctx.RoutePattern()))
+		}
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.code))
+
+		if rec.code >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, strconv.Itoa(rec.code))
+		}
+	})
+}
+
+// endSpan records the error, if any, and ends the span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+type tracedFetcher struct {
+	fetcher UserContextFetcher
+	tracer  trace.Tracer
+}
+
+func (f tracedFetcher) GetUserContext(req *http.Request) (UserContext, error) {
+	_, span := f.tracer.Start(req.Context(), "UserContextFetcher.GetUserContext")
+
+	usrCtx, err := f.fetcher.GetUserContext(req)
+
+	span.SetAttributes(
+		sessionPresentKey.Bool(usrCtx.SessionToken != ""),
+		userPresentKey.Bool(usrCtx.UserID != ""),
+	)
+	endSpan(span, err)
+
+	return usrCtx, err
+}
+
+type tracedAuthorizer struct {
+	authorizer Authorizer
+	tracer     trace.Tracer
+}
+
+func (a tracedAuthorizer) AuthorizeUser(req *http.Request, op Operation, resourceID string) error {
+	_, span := a.tracer.Start(
+		req.Context(), "Authorizer.AuthorizeUser",
+		trace.WithAttributes(
+			operationKey.String(string(op.Type)),
+			resourceKey.String(op.Resource),
+			cartIDKey.String(resourceID),
+		),
+	)
+
+	err := a.authorizer.AuthorizeUser(req, op, resourceID)
+	endSpan(span, err)
+
+	return err
+}
+
+// traceDB returns the DB recording a span, child of the span in ctx, for
+// every call.
+func traceDB(ctx context.Context, tracer trace.Tracer, db DB) DB {
+	traced := tracedDB{db: db, ctx: ctx, tracer: tracer}
+
+	return decorateDB(
+		db, traced,
+		func(querier CartQuerier) CartQuerier { return tracedQuerier{querier, traced} },
+		func(lister UserCartLister) UserCartLister { return tracedLister{lister, traced} },
+	)
+}
+
+// tracedDB holds the context of the call it was created for, since the DB
+// methods don't take one.
+type tracedDB struct {
+	db     DB
+	ctx    context.Context
+	tracer trace.Tracer
+}
+
+func (t tracedDB) start(method string, attrs ...attribute.KeyValue) trace.Span {
+	_, span := t.tracer.Start(
+		t.ctx, "DB."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	return span
+}
+
+func (t tracedDB) CreateCartForSession(sessionToken string) (Cart, error) {
+	span := t.start("CreateCartForSession", sessionPresentKey.Bool(sessionToken != ""))
+	cart, err := t.db.CreateCartForSession(sessionToken)
+
+	span.SetAttributes(cartIDKey.String(cart.ID))
+	endSpan(span, err)
+
+	return cart, err
+}
+
+func (t tracedDB) CreateCart() (Cart, error) {
+	span := t.start("CreateCart")
+	cart, err := t.db.CreateCart()
+
+	span.SetAttributes(cartIDKey.String(cart.ID))
+	endSpan(span, err)
+
+	return cart, err
+}
+
+func (t tracedDB) DeleteCart(cartID string) error {
+	span := t.start("DeleteCart", cartIDKey.String(cartID))
+	err := t.db.DeleteCart(cartID)
+
+	endSpan(span, err)
+
+	return err
+}
+
+func (t tracedDB) UpdateCart(cart Cart) error {
+	span := t.start("UpdateCart", cartIDKey.String(cart.ID))
+	err := t.db.UpdateCart(cart)
+
+	endSpan(span, err)
+
+	return err
+}
+
+func (t tracedDB) LookupCart(cartID string) (Cart, error) {
+	span := t.start("LookupCart", cartIDKey.String(cartID))
+	cart, err := t.db.LookupCart(cartID)
+
+	endSpan(span, err)
+
+	return cart, err
+}
+
+func (t tracedDB) LookupCartForSession(sessionToken string) (Cart, error) {
+	span := t.start("LookupCartForSession", sessionPresentKey.Bool(sessionToken != ""))
+	cart, err := t.db.LookupCartForSession(sessionToken)
+
+	span.SetAttributes(cartIDKey.String(cart.ID))
+	endSpan(span, err)
+
+	return cart, err
+}
+
+func (t tracedDB) AssignCartToSession(cartID, sessionToken string) error {
+	span := t.start("AssignCartToSession", cartIDKey.String(cartID), sessionPresentKey.Bool(sessionToken != ""))
+	err := t.db.AssignCartToSession(cartID, sessionToken)
+
+	endSpan(span, err)
+
+	return err
+}
+
+type tracedQuerier struct {
+	db     CartQuerier
+	traced tracedDB
+}
+
+func (t tracedQuerier) QueryCarts(query CartQuery) (CartPage, error) {
+	span := t.traced.start("QueryCarts")
+	page, err := t.db.QueryCarts(query)
+
+	endSpan(span, err)
+
+	return page, err
+}
+
+type tracedLister struct {
+	db     UserCartLister
+	traced tracedDB
+}
+
+func (t tracedLister) ListCartsForUser(userID string) ([]Cart, error) {
+	span := t.traced.start("ListCartsForUser", userPresentKey.Bool(userID != ""))
+	carts, err := t.db.ListCartsForUser(userID)
+
+	endSpan(span, err)
+
+	return carts, err
+}
@@ -0,0 +1,173 @@
+package kaimono
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTracingTestServer(t *testing.T) (*mockBackend, *tracetest.InMemoryExporter, *httptest.Server) {
+	t.Helper()
+
+	mock := newMockBackend()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	svc, err := NewService(mock, mock, mock, nil, WithTracing(TracingConfig{Provider: provider}))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/cart/", svc.Router("/cart"))
+	mux.Handle("/admin/", svc.AdminRouter("/admin"))
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return mock, exporter, srv
+}
+
+func findSpan(spans tracetest.SpanStubs, name string) (tracetest.SpanStub, bool) {
+	for _, span := range spans {
+		if span.Name == name {
+			return span, true
+		}
+	}
+
+	return tracetest.SpanStub{}, false
+}
+
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, attr := range span.Attributes {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+
+	return attribute.Value{}, false
+}
+
+func TestTracingPropagation(t *testing.T) {
+	mock, exporter, srv := newTracingTestServer(t)
+
+	const (
+		traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentID = "00f067aa0ba902b7"
+	)
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/cart/", nil)
+	if err != nil {
+		t.Fatalf("could not make request: %v", err)
+	}
+
+	setTestCookie(req, mock.sessions[0])
+	req.Header.Set("Traceparent", "00-"+traceID+"-"+parentID+"-01")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("could not do request: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	spans := exporter.GetSpans()
+
+	handler, found := findSpan(spans, "POST /cart")
+	if !found {
+		t.Fatalf("no handler span in %d spans", len(spans))
+	}
+
+	if got := handler.SpanContext.TraceID().String(); got != traceID {
+		t.Fatalf("got trace ID %s, want %s", got, traceID)
+	}
+
+	if got := handler.Parent.SpanID().String(); got != parentID {
+		t.Fatalf("got parent span ID %s, want %s", got, parentID)
+	}
+
+	for _, name := range []string{"UserContextFetcher.GetUserContext", "DB.CreateCartForSession", "DB.UpdateCart"} {
+		span, found := findSpan(spans, name)
+		if !found {
+			t.Fatalf("no %s span", name)
+		}
+
+		if span.Parent.SpanID() != handler.SpanContext.SpanID() {
+			t.Fatalf("%s is not a child of the handler span", name)
+		}
+	}
+
+	fetch, _ := findSpan(spans, "UserContextFetcher.GetUserContext")
+	if present, _ := spanAttribute(fetch, sessionPresentKey); !present.AsBool() {
+		t.Fatalf("expected the session to be present")
+	}
+
+	create, _ := findSpan(spans, "DB.CreateCartForSession")
+	if cartID, _ := spanAttribute(create, cartIDKey); cartID.AsString() == "" {
+		t.Fatalf("expected the cart ID to be set")
+	}
+}
+
+func TestTracingErrors(t *testing.T) {
+	mock, exporter, srv := newTracingTestServer(t)
+
+	if code := doJSONRequest(t, http.MethodGet, srv.URL+"/admin/missing", mock.sessions[1], nil, nil); code != http.StatusNotFound {
+		t.Fatalf("got code %d, want %d", code, http.StatusNotFound)
+	}
+
+	if code := doJSONRequest(t, http.MethodDelete, srv.URL+"/admin/missing", mock.sessions[0], nil, nil); code != http.StatusForbidden {
+		t.Fatalf("got code %d, want %d", code, http.StatusForbidden)
+	}
+
+	spans := exporter.GetSpans()
+
+	tests := []struct {
+		name      string
+		wantError bool
+	}{
+		{"DB.LookupCart", true},
+		{"GET /admin/{id}", false},
+	}
+
+	for _, c := range tests {
+		span, found := findSpan(spans, c.name)
+		if !found {
+			t.Fatalf("no %s span", c.name)
+		}
+
+		if got := span.Status.Code == codes.Error; got != c.wantError {
+			t.Fatalf("%s: got error %v, want %v", c.name, got, c.wantError)
+		}
+	}
+
+	authorized := 0
+
+	for _, span := range spans {
+		if span.Name != "Authorizer.AuthorizeUser" {
+			continue
+		}
+
+		authorized++
+
+		if cartID, _ := spanAttribute(span, cartIDKey); cartID.AsString() != "missing" {
+			t.Fatalf("got cart ID %q, want %q", cartID.AsString(), "missing")
+		}
+	}
+
+	if authorized != 2 {
+		t.Fatalf("got %d authorizer spans, want 2", authorized)
+	}
+
+	lookup, _ := findSpan(spans, "DB.LookupCart")
+	if len(lookup.Events) == 0 {
+		t.Fatalf("expected the error to be recorded")
+	}
+}
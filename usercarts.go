@@ -0,0 +1,305 @@
+package kaimono
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// NewUserCart specifies the name and kind of a new user-owned Cart.
+type NewUserCart struct {
+	Name string   `json:"name"`
+	Kind CartKind `json:"kind"`
+}
+
+// UserCarts lists the carts owned by a user and the ID of the one active
+// for the current session.
+type UserCarts struct {
+	Active string `json:"active"`
+	Carts  []Cart `json:"carts"`
+}
+
+// ItemMove moves quantity units of an item between two carts, moving the
+// whole item if quantity is 0. An empty From or To is the session's Cart.
+type ItemMove struct {
+	ItemID   string `json:"item-id"`
+	Quantity int    `json:"quantity"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+// ItemMoveResult holds both carts after moving an item.
+type ItemMoveResult struct {
+	From Cart `json:"from"`
+	To   Cart `json:"to"`
+}
+
+type CreateUserCartRequest = Request[NewUserCart]
+type MoveItemRequest = Request[ItemMove]
+type ListUserCartsResponse = Response[UserCarts]
+type CreateUserCartResponse = Response[Cart]
+type ActivateUserCartResponse = Response[Cart]
+type MoveItemResponse = Response[ItemMoveResult]
+
+// writeUserCartError maps the errors returned while managing a user's carts.
+func (svc *Service) writeUserCartError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, ErrSessionNotFound), errors.Is(err, ErrInvalidKind),
+		errors.Is(err, ErrInvalidQuantity), errors.Is(err, ErrCurrencyMismatch),
+		errors.As(err, &ValidationError{}):
+		code = http.StatusBadRequest
+	case errors.Is(err, ErrUserRequired):
+		code = http.StatusUnauthorized
+	case errors.Is(err, ErrReadOnly), errors.Is(err, ErrAccessRevoked):
+		code = http.StatusForbidden
+	case errors.Is(err, ErrCartNotFound), errors.Is(err, ErrItemNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, ErrNotSupported):
+		code = http.StatusNotImplemented
+	}
+
+	svc.json(svc.writeError(w, code, err))
+}
+
+// fetchUserCtxOrExit fetches the UserContext, requiring a logged-in user.
+func (svc *Service) fetchUserCtxOrExit(w http.ResponseWriter, req *http.Request) (UserContext, bool) {
+	usrCtx, ok := svc.fetchCtxOrExit(w, req)
+	if !ok {
+		return usrCtx, false
+	}
+
+	if usrCtx.UserID == "" {
+		svc.writeUserCartError(w, ErrUserRequired)
+		return usrCtx, false
+	}
+
+	return usrCtx, true
+}
+
+// lookupUserCart returns the Cart if it's owned by the user, or if it's the
+// session's Cart and the session can edit it. An empty ID is the session's
+// Cart. Carts owned by other users are reported as not found.
+func (svc *Service) lookupUserCart(ctx context.Context, usrCtx UserContext, cartID string) (Cart, error) {
+	sessionCart, err := svc.store(ctx).LookupCartForSession(usrCtx.SessionToken)
+	if err != nil && !errors.Is(err, ErrCartNotFound) {
+		return sessionCart, err
+	}
+
+	if err == nil && (cartID == "" || cartID == sessionCart.ID) {
+		return sessionCart, svc.checkAccess(usrCtx.SessionToken, sessionCart.ID, EditPermission)
+	}
+
+	if cartID == "" {
+		return Cart{}, ErrCartNotFound
+	}
+
+	cart, err := svc.store(ctx).LookupCart(cartID)
+	if err != nil {
+		return cart, err
+	}
+
+	if cart.UserID != usrCtx.UserID {
+		return Cart{}, ErrCartNotFound
+	}
+
+	return cart, nil
+}
+
+// ListUserCarts lists the carts owned by the current user.
+//
+// Status codes:
+//   - 200: OK
+//   - 400: No session found for request
+//   - 401: No user logged in
+//   - 500: unexpected error
+//   - 501: DB doesn't implement UserCartLister
+func (svc *Service) ListUserCarts(w http.ResponseWriter, req *http.Request) {
+	usrCtx, ok := svc.fetchUserCtxOrExit(w, req)
+	if !ok {
+		return
+	}
+
+	lister, ok := svc.store(req.Context()).(UserCartLister)
+	if !ok {
+		svc.writeUserCartError(w, ErrNotSupported)
+		return
+	}
+
+	carts, err := lister.ListCartsForUser(usrCtx.UserID)
+	if err != nil {
+		svc.writeUserCartError(w, err)
+		return
+	}
+
+	resp := ListUserCartsResponse{Data: UserCarts{Carts: carts}}
+
+	active, err := svc.store(req.Context()).LookupCartForSession(usrCtx.SessionToken)
+	if err == nil {
+		resp.Data.Active = active.ID
+	}
+
+	svc.json(writeResponse(w, http.StatusOK, resp))
+}
+
+// CreateUserCart creates a named Cart owned by the current user. The
+// session's active Cart is left unchanged.
+//
+// Status codes:
+//   - 201: Created successfully
+//   - 400: No session found for request or invalid request
+//   - 401: No user logged in
+//   - 500: unexpected error
+func (svc *Service) CreateUserCart(w http.ResponseWriter, req *http.Request) {
+	usrCtx, ok := svc.fetchUserCtxOrExit(w, req)
+	if !ok {
+		return
+	}
+
+	payload := CreateUserCartRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+		svc.json(svc.writeError(w, http.StatusBadRequest, fmt.Errorf("could not decode request: %w", err)))
+		return
+	}
+
+	kind := payload.Data.Kind
+	if kind == "" {
+		kind = ShoppingCart
+	}
+
+	if kind != ShoppingCart && kind != WishlistCart {
+		svc.writeUserCartError(w, fmt.Errorf("%w: '%s'", ErrInvalidKind, kind))
+		return
+	}
+
+	cart, err := svc.createUserCart(req.Context(), usrCtx.UserID, payload.Data.Name, kind)
+	if err != nil {
+		svc.writeUserCartError(w, err)
+		return
+	}
+
+	svc.json(writeResponse(w, http.StatusCreated, CreateUserCartResponse{Data: cart}))
+}
+
+// ActivateUserCart makes the user's Cart the current session's Cart. Items
+// saved for later in the previous Cart are moved to it.
+//
+// Status codes:
+//   - 200: OK
+//   - 400: No session found for request
+//   - 401: No user logged in
+//   - 404: No cart found for the user
+//   - 500: unexpected error
+func (svc *Service) ActivateUserCart(w http.ResponseWriter, req *http.Request) {
+	usrCtx, ok := svc.fetchUserCtxOrExit(w, req)
+	if !ok {
+		return
+	}
+
+	cart, err := svc.lookupUserCart(req.Context(), usrCtx, chi.URLParam(req, "id"))
+	if err != nil {
+		svc.writeUserCartError(w, err)
+		return
+	}
+
+	if err := svc.assignCartToSession(req.Context(), cart.ID, usrCtx.SessionToken); err != nil {
+		svc.writeUserCartError(w, err)
+		return
+	}
+
+	cart, err = svc.store(req.Context()).LookupCart(cart.ID)
+	if err != nil {
+		svc.writeUserCartError(w, err)
+		return
+	}
+
+	svc.json(writeResponse(w, http.StatusOK, ActivateUserCartResponse{Data: cart}))
+}
+
+// MoveItem moves an item between two of the user's carts, e.g: from the
+// active Cart to a wishlist.
+//
+// Status codes:
+//   - 200: OK
+//   - 400: No session found for request or invalid request
+//   - 401: No user logged in
+//   - 403: Session can't edit its shared cart
+//   - 404: No cart found for the user or no item found in the cart
+//   - 500: unexpected error
+func (svc *Service) MoveItem(w http.ResponseWriter, req *http.Request) {
+	usrCtx, ok := svc.fetchUserCtxOrExit(w, req)
+	if !ok {
+		return
+	}
+
+	payload := MoveItemRequest{}
+	if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
+		svc.json(svc.writeError(w, http.StatusBadRequest, fmt.Errorf("could not decode request: %w", err)))
+		return
+	}
+
+	move := payload.Data
+
+	from, err := svc.lookupUserCart(req.Context(), usrCtx, move.From)
+	if err != nil {
+		svc.writeUserCartError(w, err)
+		return
+	}
+
+	to, err := svc.lookupUserCart(req.Context(), usrCtx, move.To)
+	if err != nil {
+		svc.writeUserCartError(w, err)
+		return
+	}
+
+	if from.ID == to.ID {
+		svc.json(svc.writeError(w, http.StatusBadRequest, ErrInvalidID))
+		return
+	}
+
+	result, err := svc.moveItem(req.Context(), from.Clone(), to.Clone(), move.ItemID, move.Quantity)
+	if err != nil {
+		svc.writeUserCartError(w, err)
+		return
+	}
+
+	svc.json(writeResponse(w, http.StatusOK, MoveItemResponse{Data: result}))
+}
+
+// moveItem stores both carts or neither: they are validated before either
+// is stored, and to is restored if from can't be stored.
+func (svc *Service) moveItem(ctx context.Context, from, to Cart, itemID string, quantity int) (ItemMoveResult, error) {
+	original := to.Clone()
+
+	item, err := from.TakeItem(itemID, quantity)
+	if err != nil {
+		return ItemMoveResult{}, err
+	}
+
+	if err := to.AddItem(item); err != nil {
+		return ItemMoveResult{}, err
+	}
+
+	if err := errors.Join(svc.validateCart(from), svc.validateCart(to)); err != nil {
+		return ItemMoveResult{}, err
+	}
+
+	if err := svc.updateCart(ctx, &to); err != nil {
+		return ItemMoveResult{}, err
+	}
+
+	if err := svc.updateCart(ctx, &from); err != nil {
+		if restoreErr := svc.updateCart(ctx, &original); restoreErr != nil {
+			return ItemMoveResult{}, errors.Join(err, fmt.Errorf("could not restore cart: %w", restoreErr))
+		}
+
+		return ItemMoveResult{}, err
+	}
+
+	return ItemMoveResult{From: from, To: to}, nil
+}
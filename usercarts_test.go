@@ -0,0 +1,298 @@
+package kaimono
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserCarts(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	base := srv.URL + "/cart"
+	session := mock.sessions[0]
+
+	active := CreateCartResponse{}
+	if code := doJSONRequest(t, http.MethodPost, base+"/", session, nil, &active); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	if active.Data.UserID != "test-user" {
+		t.Fatalf("session cart should be owned by the user, got %q", active.Data.UserID)
+	}
+
+	cart := active.Data.Clone()
+	cart.Items = append(cart.Items, CartItem{ID: "apple", Quantity: 3, Price: Price{Currency: "EUR", Value: 1}, Discounts: []Discount{}})
+	putCart(t, base+"/", session, cart)
+
+	wishlist := CreateUserCartResponse{}
+	body := CreateUserCartRequest{Data: NewUserCart{Name: "later", Kind: WishlistCart}}
+
+	if code := doJSONRequest(t, http.MethodPost, base+"/carts", session, body, &wishlist); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	moved := MoveItemResponse{}
+	move := MoveItemRequest{Data: ItemMove{ItemID: "apple", Quantity: 2, To: wishlist.Data.ID}}
+
+	if code := doJSONRequest(t, http.MethodPost, base+"/carts/move", session, move, &moved); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if moved.Data.From.Items[0].Quantity != 1 || moved.Data.To.Items[0].Quantity != 2 {
+		t.Fatalf("unexpected move result: %+v", moved.Data)
+	}
+
+	listed := ListUserCartsResponse{}
+	if code := doJSONRequest(t, http.MethodGet, base+"/carts", session, nil, &listed); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if len(listed.Data.Carts) != 2 || listed.Data.Active != active.Data.ID {
+		t.Fatalf("unexpected listing: %+v", listed.Data)
+	}
+
+	url := base + "/carts/" + wishlist.Data.ID + "/activate"
+	if code := doJSONRequest(t, http.MethodPost, url, session, nil, nil); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	found, err := mock.LookupCartForSession(session)
+	if err != nil || found.ID != wishlist.Data.ID {
+		t.Fatalf("session cart was not switched: %v", err)
+	}
+}
+
+func TestUserCartsErrors(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	other, err := svc.createUserCart(context.Background(), "another-user", "theirs", ShoppingCart)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	// hides the optional UserCartLister implementation.
+	unsupported, err := NewService(struct{ DB }{mock}, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	tests := []struct {
+		label        string
+		svc          *Service
+		method       string
+		path         string
+		sessionToken string
+		body         any
+		wantCode     int
+	}{
+		{
+			label:        "anonymous sessions can't list carts",
+			svc:          svc,
+			method:       http.MethodGet,
+			path:         "/carts",
+			sessionToken: mock.sessions[2],
+			wantCode:     http.StatusUnauthorized,
+		},
+		{
+			label:        "listing requires support from the DB",
+			svc:          unsupported,
+			method:       http.MethodGet,
+			path:         "/carts",
+			sessionToken: mock.sessions[0],
+			wantCode:     http.StatusNotImplemented,
+		},
+		{
+			label:        "carts of other users can't be activated",
+			svc:          svc,
+			method:       http.MethodPost,
+			path:         "/carts/" + other.ID + "/activate",
+			sessionToken: mock.sessions[0],
+			wantCode:     http.StatusNotFound,
+		},
+		{
+			label:        "unknown cart kinds are rejected",
+			svc:          svc,
+			method:       http.MethodPost,
+			path:         "/carts",
+			sessionToken: mock.sessions[0],
+			body:         CreateUserCartRequest{Data: NewUserCart{Kind: "basket"}},
+			wantCode:     http.StatusBadRequest,
+		},
+		{
+			label:        "items can't be moved out of other users' carts",
+			svc:          svc,
+			method:       http.MethodPost,
+			path:         "/carts/move",
+			sessionToken: mock.sessions[0],
+			body:         MoveItemRequest{Data: ItemMove{ItemID: "apple", From: other.ID}},
+			wantCode:     http.StatusNotFound,
+		},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			srv := httptest.NewServer(c.svc.Router("/cart"))
+			defer srv.Close()
+
+			if code := doJSONRequest(t, c.method, srv.URL+"/cart"+c.path, c.sessionToken, c.body, nil); code != c.wantCode {
+				t.Fatalf("got code %d, want %d", code, c.wantCode)
+			}
+		})
+	}
+}
+
+// denyOperation allows every operation but one.
+type denyOperation OperationType
+
+func (denied denyOperation) AuthorizeUser(_ *http.Request, op Operation, resourceID string) error {
+	if op.Type == OperationType(denied) {
+		return NotAuthorizedError{Operation: op, ID: resourceID}
+	}
+
+	return nil
+}
+
+func TestAdminUpdateKeepsOwner(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, denyOperation(TransferOp), nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.AdminRouter("/admin"))
+	t.Cleanup(srv.Close)
+
+	cart, err := svc.createUserCart(context.Background(), "test-user", "later", WishlistCart)
+	if err != nil {
+		t.Fatalf("could not create cart: %v", err)
+	}
+
+	url := srv.URL + "/admin/" + cart.ID
+	update := Cart{ID: cart.ID, Items: []CartItem{{ID: "apple", Quantity: 1, Price: Price{Currency: "EUR", Value: 1}, Discounts: []Discount{}}}}
+
+	updated := UpdateCartResponse{}
+	if code := doJSONRequest(t, http.MethodPut, url, "", UpdateCartRequest{Data: update}, &updated); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	if updated.Data.UserID != "test-user" || updated.Data.Kind != WishlistCart || updated.Data.Name != "later" {
+		t.Fatalf("expected the owner, kind and name to be kept, got %+v", updated.Data)
+	}
+
+	carts, err := mock.ListCartsForUser("test-user")
+	if err != nil || len(carts) != 1 || len(carts[0].Items) != 1 {
+		t.Fatalf("expected the cart to still be listed for its owner, got (%+v, %v)", carts, err)
+	}
+
+	// the owner is set: it's unchanged, so no transfer is needed.
+	update.UserID = "test-user"
+	if code := doJSONRequest(t, http.MethodPut, url, "", UpdateCartRequest{Data: update}, nil); code != http.StatusOK {
+		t.Fatalf("got code %d, want %d", code, http.StatusOK)
+	}
+
+	update.UserID = "someone-else"
+	if code := doJSONRequest(t, http.MethodPut, url, "", UpdateCartRequest{Data: update}, nil); code != http.StatusForbidden {
+		t.Fatalf("transfer: got code %d, want %d", code, http.StatusForbidden)
+	}
+
+	if found, _ := mock.LookupCart(cart.ID); found.UserID != "test-user" {
+		t.Fatalf("expected the owner not to change, got %q", found.UserID)
+	}
+}
+
+var errTestUpdate = errors.New("update failed")
+
+// failUpdateDB fails the updates of one cart.
+type failUpdateDB struct {
+	DB
+
+	cartID string
+}
+
+func (db *failUpdateDB) UpdateCart(cart Cart) error {
+	if cart.ID == db.cartID {
+		return errTestUpdate
+	}
+
+	return db.DB.UpdateCart(cart)
+}
+
+func TestMoveItemIsAtomic(t *testing.T) {
+	// carts can't be emptied, so moving every unit of an item fails.
+	notEmpty := func(cart Cart) []FieldError {
+		if cart.Kind == ShoppingCart && len(cart.Items) == 0 {
+			return []FieldError{{Field: "items", Message: "must not be empty"}}
+		}
+
+		return nil
+	}
+
+	tests := []struct {
+		label    string
+		quantity int
+		failFrom bool
+		check    func(err error) bool
+	}{
+		{"from is invalid", 0, false, func(err error) bool { return errors.As(err, &ValidationError{}) }},
+		{"from can't be stored", 1, true, func(err error) bool { return errors.Is(err, errTestUpdate) }},
+	}
+
+	for _, c := range tests {
+		mock := newMockBackend()
+		db := &failUpdateDB{DB: mock}
+
+		svc, err := NewService(db, mock, mock, nil, WithCartValidator(notEmpty))
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+
+		ctx := context.Background()
+
+		from, err := svc.createUserCart(ctx, "test-user", "active", ShoppingCart)
+		if err != nil {
+			t.Fatalf("could not create cart: %v", err)
+		}
+
+		from.Items = []CartItem{{ID: "apple", Quantity: 2, Price: Price{Currency: "EUR", Value: 1}, Discounts: []Discount{}}}
+		if err := svc.updateCart(ctx, &from); err != nil {
+			t.Fatalf("could not update cart: %v", err)
+		}
+
+		to, err := svc.createUserCart(ctx, "test-user", "later", WishlistCart)
+		if err != nil {
+			t.Fatalf("could not create cart: %v", err)
+		}
+
+		if c.failFrom {
+			db.cartID = from.ID
+		}
+
+		if _, err := svc.moveItem(ctx, from.Clone(), to.Clone(), "apple", c.quantity); !c.check(err) {
+			t.Fatalf("(%s) unexpected error: %v", c.label, err)
+		}
+
+		storedFrom, _ := mock.LookupCart(from.ID)
+		storedTo, _ := mock.LookupCart(to.ID)
+
+		if len(storedTo.Items) != 0 || len(storedFrom.Items) != 1 || storedFrom.Items[0].Quantity != 2 {
+			t.Fatalf("(%s) expected neither cart to change, got %+v and %+v", c.label, storedFrom.Items, storedTo.Items)
+		}
+	}
+}
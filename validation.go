@@ -0,0 +1,192 @@
+package kaimono
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrInvalidCart = errors.New("invalid cart")
+
+// Default business limits, see CartLimits.
+const (
+	DefaultMaxItems    = 1000
+	DefaultMaxQuantity = 10000
+)
+
+// CartLimits are the business limits enforced on every Cart before it's
+// stored. Zero values disable the limit.
+type CartLimits struct {
+	// MaxItems is the maximum number of distinct items.
+	MaxItems int
+
+	// MaxQuantity is the maximum quantity of each item.
+	MaxQuantity int
+
+	// MaxValue is the maximum total of the Cart, after discounts.
+	MaxValue float64
+
+	// Currencies are the allowed currencies, any currency is allowed if
+	// empty.
+	Currencies []string
+}
+
+// DefaultCartLimits are the limits of Services created without
+// WithCartLimits.
+var DefaultCartLimits = CartLimits{
+	MaxItems:    DefaultMaxItems,
+	MaxQuantity: DefaultMaxQuantity,
+}
+
+// CartValidator is a custom validation rule, returning the Cart's
+// violations if any. Fields are named after their JSON path, e.g:
+// items[0].quantity.
+type CartValidator func(cart Cart) []FieldError
+
+// WithCartLimits replaces DefaultCartLimits.
+func WithCartLimits(limits CartLimits) Option {
+	return func(svc *Service) {
+		svc.limits = limits
+	}
+}
+
+// WithCartValidator adds a custom validator, run after the built-in rules
+// and the limits.
+func WithCartValidator(validator CartValidator) Option {
+	return func(svc *Service) {
+		svc.validators = append(svc.validators, validator)
+	}
+}
+
+// validateCart runs the built-in rules, the limits and the custom
+// validators, returning a ValidationError with every violation.
+func (svc *Service) validateCart(cart Cart) error {
+	fields := validateItems("items", cart.Items)
+	fields = append(fields, validateItems("saved", cart.Saved)...)
+	fields = append(fields, validateDiscounts("discounts", cart.Discounts)...)
+	fields = append(fields, svc.limits.check(cart)...)
+
+	for _, validator := range svc.validators {
+		fields = append(fields, validator(cart)...)
+	}
+
+	if len(fields) > 0 {
+		return ValidationError{Err: ErrInvalidCart, Fields: fields}
+	}
+
+	return nil
+}
+
+func validateItems(path string, items []CartItem) []FieldError {
+	fields := []FieldError{}
+	seen := map[string]int{}
+	currency := ""
+
+	for k, item := range items {
+		field := func(name string) string { return fmt.Sprintf("%s[%d].%s", path, k, name) }
+
+		switch previous, dup := seen[item.ID]; {
+		case item.ID == "":
+			fields = append(fields, FieldError{Field: field("id"), Message: "is required"})
+		case dup:
+			fields = append(fields, FieldError{Field: field("id"), Message: fmt.Sprintf("duplicates %s[%d]", path, previous)})
+		default:
+			seen[item.ID] = k
+		}
+
+		if item.Quantity <= 0 {
+			fields = append(fields, FieldError{Field: field("quantity"), Message: "must be positive"})
+		}
+
+		if item.Price.Value <= 0 {
+			fields = append(fields, FieldError{Field: field("price.value"), Message: "must be positive"})
+		}
+
+		switch {
+		case currency == "":
+			currency = item.Price.Currency
+		case item.Price.Currency != currency:
+			fields = append(fields, FieldError{Field: field("price.currency"), Message: "must match the other items' currency"})
+		}
+
+		fields = append(fields, validateDiscounts(field("discounts"), item.Discounts)...)
+	}
+
+	return fields
+}
+
+func validateDiscounts(path string, discounts []Discount) []FieldError {
+	fields := []FieldError{}
+	seen := map[string]bool{}
+
+	for k, discount := range discounts {
+		field := func(name string) string { return fmt.Sprintf("%s[%d].%s", path, k, name) }
+
+		switch {
+		case discount.ID == "":
+			fields = append(fields, FieldError{Field: field("id"), Message: "is required"})
+		case seen[discount.ID]:
+			fields = append(fields, FieldError{Field: field("id"), Message: "is duplicated"})
+		}
+
+		seen[discount.ID] = true
+
+		switch discount.Type {
+		case PercentageDiscount:
+			if discount.Value > percent {
+				fields = append(fields, FieldError{Field: field("value"), Message: "must be at most 100"})
+			}
+		case FixedAmountDiscount:
+		default:
+			fields = append(fields, FieldError{Field: field("type"), Message: fmt.Sprintf("'%s' is not a known discount type", discount.Type)})
+		}
+
+		if discount.Value < 0 {
+			fields = append(fields, FieldError{Field: field("value"), Message: "must not be negative"})
+		}
+	}
+
+	return fields
+}
+
+// check returns the limits the Cart exceeds. Saved items are limited too,
+// as they can be moved back into the Cart.
+func (limits CartLimits) check(cart Cart) []FieldError {
+	fields := limits.checkItems("items", cart.Items)
+	fields = append(fields, limits.checkItems("saved", cart.Saved)...)
+
+	if limits.MaxValue > 0 {
+		// carts with mixed currencies are already rejected by validateItems.
+		if totals, err := cart.Totals(); err == nil && totals.Total > limits.MaxValue {
+			fields = append(fields, FieldError{Field: "items", Message: fmt.Sprintf("must total at most %v", limits.MaxValue)})
+		}
+	}
+
+	return fields
+}
+
+func (limits CartLimits) checkItems(path string, items []CartItem) []FieldError {
+	fields := []FieldError{}
+
+	if limits.MaxItems > 0 && len(items) > limits.MaxItems {
+		fields = append(fields, FieldError{Field: path, Message: fmt.Sprintf("must have at most %d items", limits.MaxItems)})
+	}
+
+	for k, item := range items {
+		field := func(name string) string { return fmt.Sprintf("%s[%d].%s", path, k, name) }
+
+		if limits.MaxQuantity > 0 && item.Quantity > limits.MaxQuantity {
+			fields = append(fields, FieldError{Field: field("quantity"), Message: fmt.Sprintf("must be at most %d", limits.MaxQuantity)})
+		}
+
+		switch currency := item.Price.Currency; {
+		case len(limits.Currencies) == 0:
+		case currency == "":
+			fields = append(fields, FieldError{Field: field("price.currency"), Message: "is required"})
+		case !slices.Contains(limits.Currencies, currency):
+			fields = append(fields, FieldError{Field: field("price.currency"), Message: fmt.Sprintf("'%s' is not an allowed currency", currency)})
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,193 @@
+package kaimono
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestValidateCart(t *testing.T) {
+	eur := Price{Currency: "EUR", Value: 2}
+	valid := Cart{
+		ID:        "cart",
+		Items:     []CartItem{{ID: "apple", Quantity: 2, Price: eur}, {ID: "pear", Quantity: 1, Price: eur}},
+		Discounts: []Discount{{ID: "promo", Type: PercentageDiscount, Value: 10}},
+	}
+
+	withItems := func(items ...CartItem) Cart {
+		cart := valid.Clone()
+		cart.Items = items
+
+		return cart
+	}
+
+	withSaved := func(items ...CartItem) Cart {
+		cart := valid.Clone()
+		cart.Saved = items
+
+		return cart
+	}
+
+	withDiscounts := func(discounts ...Discount) Cart {
+		cart := valid.Clone()
+		cart.Discounts = discounts
+
+		return cart
+	}
+
+	noApples := func(cart Cart) []FieldError {
+		if _, found := cart.Item("apple"); found {
+			return []FieldError{{Field: "items", Message: "must not contain apples"}}
+		}
+
+		return nil
+	}
+
+	tests := []struct {
+		label      string
+		limits     CartLimits
+		validators []CartValidator
+		cart       Cart
+		want       []string
+	}{
+		{"valid cart", DefaultCartLimits, nil, valid, nil},
+		{
+			"invalid items", DefaultCartLimits, nil,
+			withItems(
+				CartItem{ID: "", Quantity: -1, Price: Price{Currency: "EUR"}},
+				CartItem{ID: "apple", Quantity: 1, Price: eur},
+				CartItem{ID: "apple", Quantity: 1, Price: Price{Currency: "USD", Value: 1}},
+			),
+			[]string{
+				"items[0].id", "items[0].quantity", "items[0].price.value",
+				"items[2].id", "items[2].price.currency",
+			},
+		},
+		{
+			"invalid discounts", DefaultCartLimits, nil,
+			withDiscounts(
+				Discount{ID: "", Type: FixedAmountDiscount, Value: 1},
+				Discount{ID: "promo", Type: "bogus", Value: -1},
+				Discount{ID: "promo", Type: PercentageDiscount, Value: 120},
+			),
+			[]string{
+				"discounts[0].id", "discounts[1].type", "discounts[1].value",
+				"discounts[2].id", "discounts[2].value",
+			},
+		},
+		{
+			"limits", CartLimits{MaxItems: 1, MaxQuantity: 1, MaxValue: 3, Currencies: []string{"USD"}}, nil,
+			valid,
+			[]string{
+				"items", "items[0].quantity", "items[0].price.currency", "items[1].price.currency", "items",
+			},
+		},
+		{
+			"limits on saved items", CartLimits{MaxItems: 1, MaxQuantity: 1, Currencies: []string{"EUR"}}, nil,
+			withSaved(CartItem{ID: "apple", Quantity: 2, Price: eur}, CartItem{ID: "pear", Quantity: 1, Price: eur}),
+			[]string{"items", "items[0].quantity", "saved", "saved[0].quantity"},
+		},
+		{
+			"empty currencies aren't allowed", CartLimits{Currencies: []string{"EUR"}}, nil,
+			withItems(CartItem{ID: "apple", Quantity: 1, Price: Price{Value: 1}}),
+			[]string{"items[0].price.currency"},
+		},
+		{"no limits", CartLimits{}, nil, withItems(CartItem{ID: "apple", Quantity: DefaultMaxQuantity + 1, Price: eur}), nil},
+		{"custom validator", DefaultCartLimits, []CartValidator{noApples}, valid, []string{"items"}},
+	}
+
+	for _, c := range tests {
+		t.Run(c.label, func(t *testing.T) {
+			svc := &Service{limits: c.limits, validators: c.validators}
+
+			err := svc.validateCart(c.cart)
+			if c.want == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			validationErr := ValidationError{}
+			if !errors.As(err, &validationErr) || !errors.Is(err, ErrInvalidCart) {
+				t.Fatalf("expected a ValidationError, got %v", err)
+			}
+
+			got := []string{}
+			for _, f := range validationErr.Fields {
+				got = append(got, f.Field)
+			}
+
+			if !slices.Equal(got, c.want) {
+				t.Fatalf("got fields %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestUpdateRejectsInvalidCart(t *testing.T) {
+	mock := newMockBackend()
+
+	svc, err := NewService(mock, mock, mock, nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	srv := httptest.NewServer(svc.Router("/cart"))
+	t.Cleanup(srv.Close)
+
+	user := mock.sessions[0]
+
+	created := CreateCartResponse{}
+	if code := doJSONRequest(t, http.MethodPost, srv.URL+"/cart/", user, nil, &created); code != http.StatusCreated {
+		t.Fatalf("got code %d, want %d", code, http.StatusCreated)
+	}
+
+	cart := created.Data
+	cart.Items = []CartItem{{ID: "apple", Quantity: -1, Price: Price{Currency: "EUR", Value: 0}}}
+
+	data, err := json.Marshal(UpdateCartRequest{Data: cart})
+	if err != nil {
+		t.Fatalf("could not encode body: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/cart/", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("could not make request: %v", err)
+	}
+
+	setTestCookie(req, user)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("could not do request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("got code %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	errResp := ErrorResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil {
+		t.Fatalf("could not decode response: %v", err)
+	}
+
+	if errResp.Code != CodeValidationFailed || len(errResp.Details) != 2 {
+		t.Fatalf("unexpected error: %+v", errResp)
+	}
+
+	stored, err := mock.LookupCart(cart.ID)
+	if err != nil {
+		t.Fatalf("could not look up cart: %v", err)
+	}
+
+	if len(stored.Items) != 0 {
+		t.Fatalf("the invalid cart was stored: %+v", stored)
+	}
+}